@@ -0,0 +1,252 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	// activeCredentialsPath is the storage prefix of the counts of live
+	// credentials of LDIF dynamic roles, which enforce the roles' quotas.
+	// Counts are updated along with the issued credential index and
+	// periodically reconciled with it. Both are replicated, and the paths
+	// that write them are forwarded from performance standbys and
+	// secondaries, so quotas apply across clusters.
+	activeCredentialsPath = "active-credentials/"
+
+	// Interval to reconcile the active credential counts with the index
+	activeCredentialsReconcileInterval = 10 * time.Minute
+)
+
+// activeCredentials counts the live credentials of a dynamic role, in total
+// and per requesting entity.
+type activeCredentials struct {
+	Total     int            `json:"total"`
+	PerEntity map[string]int `json:"per_entity,omitempty"`
+}
+
+// add adds n to the counts of the role and the entity, if any.
+func (a *activeCredentials) add(entityID string, n int) {
+	a.Total = max(a.Total+n, 0)
+	if entityID == "" {
+		return
+	}
+	if a.PerEntity == nil {
+		a.PerEntity = make(map[string]int)
+	}
+	if count := a.PerEntity[entityID] + n; count > 0 {
+		a.PerEntity[entityID] = count
+	} else {
+		delete(a.PerEntity, entityID)
+	}
+}
+
+// checkQuotas returns a quotaExceededError if issuing another credential to
+// the entity would exceed the quotas of the role. Requests without an entity
+// are only subject to max_active_credentials.
+func (a *activeCredentials) checkQuotas(dRole *dynamicRole, entityID string) error {
+	if dRole.MaxActiveCredentials > 0 && a.Total >= dRole.MaxActiveCredentials {
+		return &quotaExceededError{fmt.Sprintf("role %q has reached its max_active_credentials of %d, "+
+			"credentials can be issued again once existing leases are revoked", dRole.Name, dRole.MaxActiveCredentials)}
+	}
+	if dRole.MaxActivePerEntity > 0 && entityID != "" && a.PerEntity[entityID] >= dRole.MaxActivePerEntity {
+		return &quotaExceededError{fmt.Sprintf("entity %q has reached the max_active_per_entity of %d of role %q, "+
+			"credentials can be issued again once its existing leases are revoked", entityID, dRole.MaxActivePerEntity, dRole.Name)}
+	}
+	return nil
+}
+
+// quotaExceededError is returned when issuing a credential would exceed the
+// quotas of a role.
+type quotaExceededError struct {
+	msg string
+}
+
+func (e *quotaExceededError) Error() string {
+	return e.msg
+}
+
+func activeCredentialsStoragePath(roleName string) string {
+	return path.Join(activeCredentialsPath, roleName)
+}
+
+func retrieveActiveCredentials(ctx context.Context, s logical.Storage, roleName string) (*activeCredentials, error) {
+	entry, err := s.Get(ctx, activeCredentialsStoragePath(roleName))
+	if err != nil {
+		return nil, err
+	}
+	active := new(activeCredentials)
+	if entry == nil {
+		return active, nil
+	}
+	if err := entry.DecodeJSON(active); err != nil {
+		return nil, err
+	}
+	return active, nil
+}
+
+func storeActiveCredentials(ctx context.Context, s logical.Storage, roleName string, active *activeCredentials) error {
+	entry, err := logical.StorageEntryJSON(activeCredentialsStoragePath(roleName), active)
+	if err != nil {
+		return fmt.Errorf("unable to marshal storage entry: %w", err)
+	}
+	if err := s.Put(ctx, entry); err != nil {
+		return fmt.Errorf("failed to store active credential counts: %w", err)
+	}
+	return nil
+}
+
+// recordIssuedCredential stores the index record of a credential that is about
+// to be created and counts it as active, unless that exceeds the quotas of
+// the role.
+func (b *backend) recordIssuedCredential(ctx context.Context, s logical.Storage, dRole *dynamicRole, cred *issuedCredential) error {
+	b.activeCredentialsLock.Lock()
+	defer b.activeCredentialsLock.Unlock()
+
+	active, err := retrieveActiveCredentials(ctx, s, dRole.Name)
+	if err != nil {
+		return fmt.Errorf("failed to read active credential counts: %w", err)
+	}
+	if err := active.checkQuotas(dRole, cred.EntityID); err != nil {
+		return err
+	}
+
+	if err := storeIssuedCredential(ctx, s, dRole.Name, cred); err != nil {
+		return err
+	}
+	active.add(cred.EntityID, 1)
+	if err := storeActiveCredentials(ctx, s, dRole.Name, active); err != nil {
+		if derr := deleteIssuedCredential(ctx, s, dRole.Name, cred.Username); derr != nil {
+			b.Logger().Warn("failed to delete issued credential record", "role", dRole.Name, "username", cred.Username, "error", derr)
+		}
+		return err
+	}
+	return nil
+}
+
+// removeIssuedCredential deletes the index record of a credential whose
+// entries have been removed, and no longer counts it as active. Failures are
+// logged, since the removal itself succeeded and stale records and counts are
+// reported by sweeps and reconciled.
+func (b *backend) removeIssuedCredential(ctx context.Context, s logical.Storage, roleName, username string) {
+	b.activeCredentialsLock.Lock()
+	defer b.activeCredentialsLock.Unlock()
+
+	cred, err := retrieveIssuedCredential(ctx, s, roleName, username)
+	if err != nil {
+		b.Logger().Warn("failed to read issued credential record", "role", roleName, "username", username, "error", err)
+	}
+	if err := deleteIssuedCredential(ctx, s, roleName, username); err != nil {
+		b.Logger().Warn("failed to delete issued credential record", "role", roleName, "username", username, "error", err)
+		return
+	}
+	// Expired credentials are no longer counted once the counts have been
+	// reconciled. Those that are still counted because the counts haven't
+	// been reconciled since they expired are dropped by the next reconcile.
+	if cred == nil || !cred.live(time.Now()) {
+		return
+	}
+
+	active, err := retrieveActiveCredentials(ctx, s, roleName)
+	if err == nil {
+		active.add(cred.EntityID, -1)
+		err = storeActiveCredentials(ctx, s, roleName, active)
+	}
+	if err != nil {
+		b.Logger().Warn("failed to update active credential counts", "role", roleName, "error", err)
+	}
+}
+
+// reconcileActiveCredentials recounts the live credentials of a role from the
+// issued credential index. Counts drift if the plugin stops between updating
+// the index and the counts, and leases that expire without being revoked,
+// such as those of a mount that was force revoked, are only dropped here.
+func (b *backend) reconcileActiveCredentials(ctx context.Context, s logical.Storage, roleName string) error {
+	b.activeCredentialsLock.Lock()
+	defer b.activeCredentialsLock.Unlock()
+
+	creds, err := listIssuedCredentials(ctx, s, roleName)
+	if err != nil {
+		return fmt.Errorf("failed to list issued credentials: %w", err)
+	}
+	active := new(activeCredentials)
+	now := time.Now()
+	for _, cred := range creds {
+		if cred.live(now) {
+			active.add(cred.EntityID, 1)
+		}
+	}
+	return storeActiveCredentials(ctx, s, roleName, active)
+}
+
+// reconcileAllActiveCredentials reconciles the counts of all LDIF dynamic
+// roles.
+func (b *backend) reconcileAllActiveCredentials(ctx context.Context, s logical.Storage) {
+	roleNames, err := listDynamicRoleNames(ctx, s, "")
+	if err != nil {
+		b.Logger().Error("failed to list dynamic roles", "error", err)
+		return
+	}
+	for _, roleName := range roleNames {
+		if ctx.Err() != nil {
+			return
+		}
+		dRole, err := retrieveDynamicRole(ctx, s, roleName)
+		if err != nil {
+			b.Logger().Error("failed to read dynamic role", "role", roleName, "error", err)
+			continue
+		}
+		if dRole == nil || dRole.roleType() != dynamicRoleTypeLDIF {
+			continue
+		}
+		if err := b.reconcileActiveCredentials(ctx, s, roleName); err != nil {
+			b.Logger().Error("failed to reconcile active credentials", "role", roleName, "error", err)
+		}
+	}
+}
+
+// runActiveCredentialsReconcile periodically reconciles the active credential
+// counts until ctx is done.
+func (b *backend) runActiveCredentialsReconcile(ctx context.Context, s logical.Storage) {
+	tick := time.NewTicker(activeCredentialsReconcileInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			b.reconcileAllActiveCredentials(ctx, s)
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// listDynamicRoleNames returns the names of the dynamic roles under the given
+// prefix, including hierarchical roles.
+func listDynamicRoleNames(ctx context.Context, s logical.Storage, prefix string) ([]string, error) {
+	keys, err := s.List(ctx, dynamicRolePath+prefix)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, key := range keys {
+		if strings.HasSuffix(key, "/") {
+			nested, err := listDynamicRoleNames(ctx, s, prefix+key)
+			if err != nil {
+				return nil, err
+			}
+			names = append(names, nested...)
+			continue
+		}
+		names = append(names, prefix+key)
+	}
+	return names, nil
+}
@@ -0,0 +1,218 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActiveCredentials_checkQuotas(t *testing.T) {
+	dRole := &dynamicRole{Name: "hashicorp", MaxActiveCredentials: 3, MaxActivePerEntity: 2}
+	active := &activeCredentials{}
+	active.add("alice", 1)
+	active.add("alice", 1)
+	active.add("", 1)
+	require.Equal(t, &activeCredentials{Total: 3, PerEntity: map[string]int{"alice": 2}}, active)
+
+	var quotaErr *quotaExceededError
+	require.ErrorAs(t, active.checkQuotas(dRole, "bob"), &quotaErr)
+
+	active.add("", -1)
+	require.ErrorAs(t, active.checkQuotas(dRole, "alice"), &quotaErr)
+	require.NoError(t, active.checkQuotas(dRole, "bob"))
+	require.NoError(t, active.checkQuotas(dRole, ""))
+
+	active.add("alice", -3)
+	require.Equal(t, &activeCredentials{Total: 0, PerEntity: map[string]int{}}, active)
+	require.NoError(t, active.checkQuotas(&dynamicRole{Name: "unlimited"}, "alice"))
+}
+
+func TestDynamicCredsRead_quotas(t *testing.T) {
+	ctx := context.Background()
+	b, storage := getBackend(false)
+	defer b.Cleanup(ctx)
+	configureOpenLDAPMount(t, b, storage)
+
+	data := getTestDynamicRoleConfig("hashicorp")
+	data["max_active_credentials"] = 3
+	data["max_active_per_entity"] = 2
+	resp, err := createDynamicRoleWithData(t, b, storage, "hashicorp", data)
+	require.NoError(t, err)
+	require.False(t, resp != nil && resp.IsError(), "unexpected error response: %v", resp)
+
+	recorder := &executeRecordingClient{}
+	b.client = recorder
+
+	requestCreds := func(entityID string) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      dynamicCredPath + "hashicorp",
+			Storage:   storage,
+			EntityID:  entityID,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		return resp
+	}
+	revoke := func(secret *logical.Secret) {
+		t.Helper()
+		_, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.RevokeOperation,
+			Storage:   storage,
+			Secret:    secret,
+		})
+		require.NoError(t, err)
+	}
+
+	first := requestCreds("alice")
+	require.False(t, first.IsError(), "unexpected error response: %v", first)
+	require.False(t, requestCreds("alice").IsError())
+
+	// Entities are limited by max_active_per_entity.
+	executed := len(recorder.executed)
+	resp = requestCreds("alice")
+	require.True(t, resp.IsError())
+	require.Contains(t, resp.Error().Error(), "max_active_per_entity")
+	require.Len(t, recorder.executed, executed)
+
+	// The role is limited by max_active_credentials.
+	require.False(t, requestCreds("").IsError())
+	resp = requestCreds("bob")
+	require.True(t, resp.IsError())
+	require.Contains(t, resp.Error().Error(), "max_active_credentials")
+
+	// Revocation frees up quota.
+	revoke(first.Secret)
+	require.False(t, requestCreds("alice").IsError())
+
+	active, err := retrieveActiveCredentials(ctx, storage, "hashicorp")
+	require.NoError(t, err)
+	require.Equal(t, &activeCredentials{Total: 3, PerEntity: map[string]int{"alice": 2}}, active)
+}
+
+// readOnlyStorage fails writes outside of the local WAL prefix, as the
+// storage of a performance standby or secondary does.
+type readOnlyStorage struct {
+	logical.Storage
+}
+
+func (s *readOnlyStorage) Put(ctx context.Context, entry *logical.StorageEntry) error {
+	if !strings.HasPrefix(entry.Key, framework.WALPrefix) {
+		return logical.ErrReadOnly
+	}
+	return s.Storage.Put(ctx, entry)
+}
+
+func (s *readOnlyStorage) Delete(ctx context.Context, key string) error {
+	if !strings.HasPrefix(key, framework.WALPrefix) {
+		return logical.ErrReadOnly
+	}
+	return s.Storage.Delete(ctx, key)
+}
+
+func TestDynamicCredsRead_readOnly(t *testing.T) {
+	ctx := context.Background()
+	b, storage := getBackend(false)
+	defer b.Cleanup(ctx)
+	configureOpenLDAPMount(t, b, storage)
+
+	data := getTestDynamicRoleConfig("hashicorp")
+	data["max_active_credentials"] = 3
+	resp, err := createDynamicRoleWithData(t, b, storage, "hashicorp", data)
+	require.NoError(t, err)
+	require.False(t, resp != nil && resp.IsError(), "unexpected error response: %v", resp)
+
+	recorder := &executeRecordingClient{}
+	b.client = recorder
+
+	// The request fails with ErrReadOnly before any LDIF is executed, so
+	// that it can be forwarded to the primary.
+	_, err = b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      dynamicCredPath + "hashicorp",
+		Storage:   &readOnlyStorage{storage},
+	})
+	require.ErrorIs(t, err, logical.ErrReadOnly)
+	require.Empty(t, recorder.executed)
+
+	walIDs, err := framework.ListWAL(ctx, storage)
+	require.NoError(t, err)
+	require.Empty(t, walIDs)
+	active, err := retrieveActiveCredentials(ctx, storage, "hashicorp")
+	require.NoError(t, err)
+	require.Equal(t, &activeCredentials{}, active)
+}
+
+func TestReconcileActiveCredentials(t *testing.T) {
+	ctx := context.Background()
+	b, storage := getBackend(false)
+	defer b.Cleanup(ctx)
+	configureOpenLDAPMount(t, b, storage)
+
+	for _, name := range []string{"hashicorp", "org/hashicorp"} {
+		resp, err := createDynamicRoleWithData(t, b, storage, name, getTestDynamicRoleConfig(name))
+		require.NoError(t, err)
+		require.False(t, resp != nil && resp.IsError(), "unexpected error response: %v", resp)
+	}
+
+	now := time.Now()
+	for _, cred := range []*issuedCredential{
+		{Username: "v-alice-1", EntityID: "alice", ExpireTime: now.Add(time.Hour)},
+		{Username: "v-alice-2", EntityID: "alice", ExpireTime: now.Add(-time.Hour)},
+		{Username: "v-root", ExpireTime: now.Add(time.Hour)},
+	} {
+		require.NoError(t, storeIssuedCredential(ctx, storage, "org/hashicorp", cred))
+	}
+	require.NoError(t, storeActiveCredentials(ctx, storage, "hashicorp", &activeCredentials{Total: 7}))
+
+	b.reconcileAllActiveCredentials(ctx, storage)
+
+	active, err := retrieveActiveCredentials(ctx, storage, "org/hashicorp")
+	require.NoError(t, err)
+	require.Equal(t, &activeCredentials{Total: 2, PerEntity: map[string]int{"alice": 1}}, active)
+
+	active, err = retrieveActiveCredentials(ctx, storage, "hashicorp")
+	require.NoError(t, err)
+	require.Equal(t, &activeCredentials{}, active)
+}
+
+func TestRemoveIssuedCredential(t *testing.T) {
+	ctx := context.Background()
+	b, storage := getBackend(false)
+	defer b.Cleanup(ctx)
+	configureOpenLDAPMount(t, b, storage)
+
+	resp, err := createDynamicRoleWithData(t, b, storage, "hashicorp", getTestDynamicRoleConfig("hashicorp"))
+	require.NoError(t, err)
+	require.False(t, resp != nil && resp.IsError(), "unexpected error response: %v", resp)
+
+	now := time.Now()
+	for _, cred := range []*issuedCredential{
+		{Username: "v-alice-1", EntityID: "alice", ExpireTime: now.Add(time.Hour)},
+		{Username: "v-alice-2", EntityID: "alice", ExpireTime: now.Add(-time.Hour)},
+	} {
+		require.NoError(t, storeIssuedCredential(ctx, storage, "hashicorp", cred))
+	}
+	require.NoError(t, b.reconcileActiveCredentials(ctx, storage, "hashicorp"))
+
+	// The expired credential was not counted, so removing it leaves the
+	// count of the live one.
+	b.removeIssuedCredential(ctx, storage, "hashicorp", "v-alice-2")
+	active, err := retrieveActiveCredentials(ctx, storage, "hashicorp")
+	require.NoError(t, err)
+	require.Equal(t, &activeCredentials{Total: 1, PerEntity: map[string]int{"alice": 1}}, active)
+
+	b.removeIssuedCredential(ctx, storage, "hashicorp", "v-alice-1")
+	active, err = retrieveActiveCredentials(ctx, storage, "hashicorp")
+	require.NoError(t, err)
+	require.Equal(t, &activeCredentials{}, active)
+}
@@ -38,6 +38,8 @@ func Backend(client ldapClient) *backend {
 		roleLocks:         locksutil.CreateLocks(),
 		checkOutLocks:     locksutil.CreateLocks(),
 		managedUsers:      make(map[string]struct{}),
+
+		membershipRecordLocks: locksutil.CreateLocks(),
 	}
 
 	b.Backend = &framework.Backend{
@@ -49,6 +51,7 @@ func Backend(client ldapClient) *backend {
 			SealWrapStorage: []string{
 				configPath,
 				staticRolePath + "*",
+				dynamicCredsIndexPath + "*",
 			},
 		},
 		Paths: framework.PathAppend(
@@ -62,6 +65,13 @@ func Backend(client ldapClient) *backend {
 			b.pathSetCheckIn(),
 			b.pathSetCheckOut(),
 			b.pathSetStatus(),
+			b.pathDynamicRolePreview(),
+			b.pathDynamicRoleSweep(),
+			b.pathDynamicRoleCredentials(),
+			b.pathLDIFTemplates(),
+			b.pathDynamicCredsRotate(),
+			b.pathRevocationFailures(),
+			b.pathBundle(),
 
 			// These paths are more generic than the above. They must be
 			// appended last.
@@ -80,8 +90,10 @@ func Backend(client ldapClient) *backend {
 			dynamicSecretCreds(b),
 			checkoutSecretCreds(b),
 		},
-		Clean:       b.clean,
-		BackendType: logical.TypeLogical,
+		Clean:             b.clean,
+		BackendType:       logical.TypeLogical,
+		WALRollback:       b.walRollback,
+		WALRollbackMinAge: walRollbackMinAge,
 
 		RotateCredential: b.rotateRootCredential,
 	}
@@ -151,6 +163,22 @@ type backend struct {
 	// checkOutLocks are used for avoiding races when working with library sets
 	// in the check-in/check-out system.
 	checkOutLocks []*locksutil.LockEntry
+
+	// revocationLock serializes retries of failed dynamic credential
+	// deletions by the revocation queue and the revocation-failures paths.
+	revocationLock sync.Mutex
+
+	// activeCredentialsLock serializes updates of the issued credential index
+	// and the active credential counts that enforce role quotas.
+	activeCredentialsLock sync.Mutex
+
+	// ldifTemplateLock serializes writes of shared LDIF fragments and of the
+	// dynamic roles that include them.
+	ldifTemplateLock sync.Mutex
+
+	// membershipRecordLocks serialize grants and releases of the group
+	// memberships of membership role leases, per group and user.
+	membershipRecordLocks []*locksutil.LockEntry
 }
 
 // walkfunc type takes a storage path argument and returns true if a storage
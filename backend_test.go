@@ -13,7 +13,7 @@ import (
 
 	"github.com/hashicorp/vault/sdk/rotation"
 
-	"github.com/go-ldap/ldif"
+	"github.com/go-ldap/ldap/v3"
 	log "github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/vault-plugin-secrets-openldap/client"
 	"github.com/hashicorp/vault/sdk/helper/logging"
@@ -100,7 +100,9 @@ func testBackendConfig() *logical.BackendConfig {
 var _ ldapClient = (*fakeLdapClient)(nil)
 
 type fakeLdapClient struct {
-	throwErrs bool
+	throwErrs          bool
+	passwordExpiration time.Time
+	subschema          *client.Subschema
 }
 
 func (f *fakeLdapClient) UpdateUserPassword(_ *client.Config, _ string, _ string) error {
@@ -119,7 +121,35 @@ func (f *fakeLdapClient) UpdateDNPassword(_ *client.Config, _ string, _ string)
 	return err
 }
 
-func (f *fakeLdapClient) Execute(_ *client.Config, _ []*ldif.Entry, _ bool) error {
+func (f *fakeLdapClient) SearchDN(_ *client.Config, dn string) (*client.Entry, error) {
+	if f.throwErrs {
+		return nil, errors.New("forced error")
+	}
+	return client.NewEntry(&ldap.Entry{DN: dn}), nil
+}
+
+func (f *fakeLdapClient) SearchUser(_ *client.Config, username string) (*client.Entry, error) {
+	if f.throwErrs {
+		return nil, errors.New("forced error")
+	}
+	return client.NewEntry(&ldap.Entry{DN: "cn=" + username}), nil
+}
+
+func (f *fakeLdapClient) SearchSubtree(_ *client.Config, _, _ string, _ ...string) ([]*client.Entry, error) {
+	if f.throwErrs {
+		return nil, errors.New("forced error")
+	}
+	return nil, nil
+}
+
+func (f *fakeLdapClient) PasswordExpiration(_ *client.Config, _ string) (time.Time, error) {
+	if f.throwErrs {
+		return time.Time{}, errors.New("forced error")
+	}
+	return f.passwordExpiration, nil
+}
+
+func (f *fakeLdapClient) VerifyBind(_ *client.Config, _ string, _ string) error {
 	var err error
 	if f.throwErrs {
 		err = errors.New("forced error")
@@ -127,6 +157,43 @@ func (f *fakeLdapClient) Execute(_ *client.Config, _ []*ldif.Entry, _ bool) erro
 	return err
 }
 
+func (f *fakeLdapClient) Subschema(_ *client.Config) (*client.Subschema, error) {
+	if f.throwErrs {
+		return nil, errors.New("forced error")
+	}
+	return f.subschema, nil
+}
+
+func (f *fakeLdapClient) IsGroupMember(_ *client.Config, _, _, _ string) (bool, error) {
+	if f.throwErrs {
+		return false, errors.New("forced error")
+	}
+	return false, nil
+}
+
+func (f *fakeLdapClient) AddGroupMember(_ *client.Config, _, _, _ string) (bool, error) {
+	if f.throwErrs {
+		return false, errors.New("forced error")
+	}
+	return true, nil
+}
+
+func (f *fakeLdapClient) RemoveGroupMember(_ *client.Config, _, _, _ string) error {
+	var err error
+	if f.throwErrs {
+		err = errors.New("forced error")
+	}
+	return err
+}
+
+func (f *fakeLdapClient) Execute(_ *client.Config, _ []*client.LDIFEntry, _ bool) ([]client.ExecuteResult, error) {
+	var err error
+	if f.throwErrs {
+		err = errors.New("forced error")
+	}
+	return nil, err
+}
+
 // TestBackend_Events_Config tests that config operations emit the correct events
 func TestBackend_Events_Config(t *testing.T) {
 	// Create backend config with event sender
@@ -5,9 +5,9 @@ package openldap
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/go-ldap/ldap/v3"
-	"github.com/go-ldap/ldif"
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/vault/sdk/helper/ldaputil"
 
@@ -17,7 +17,16 @@ import (
 type ldapClient interface {
 	UpdateDNPassword(conf *client.Config, dn string, newPassword string) error
 	UpdateUserPassword(conf *client.Config, user, newPassword string) error
-	Execute(conf *client.Config, entries []*ldif.Entry, continueOnError bool) error
+	SearchDN(conf *client.Config, dn string) (*client.Entry, error)
+	SearchUser(conf *client.Config, username string) (*client.Entry, error)
+	SearchSubtree(conf *client.Config, baseDN, filter string, attributes ...string) ([]*client.Entry, error)
+	PasswordExpiration(conf *client.Config, dn string) (time.Time, error)
+	Execute(conf *client.Config, entries []*client.LDIFEntry, continueOnError bool) ([]client.ExecuteResult, error)
+	VerifyBind(conf *client.Config, dn, password string) error
+	Subschema(conf *client.Config) (*client.Subschema, error)
+	IsGroupMember(conf *client.Config, groupDN, attribute, value string) (bool, error)
+	AddGroupMember(conf *client.Config, groupDN, attribute, value string) (bool, error)
+	RemoveGroupMember(conf *client.Config, groupDN, attribute, value string) error
 }
 
 func NewClient(logger hclog.Logger) *Client {
@@ -34,6 +43,111 @@ type Client struct {
 
 // UpdateDNPassword updates the password for the object with the given DN.
 func (c *Client) UpdateDNPassword(conf *client.Config, dn string, newPassword string) error {
+	baseDN, scope, filters, err := dnSearchParams(conf, dn)
+	if err != nil {
+		return err
+	}
+
+	newValues, err := client.GetSchemaFieldRegistry(conf, newPassword)
+	if err != nil {
+		return fmt.Errorf("error updating password: %s", err)
+	}
+
+	return c.ldap.UpdatePassword(conf, baseDN, scope, newValues, filters)
+}
+
+// UpdateUserPassword updates the password for the object with the given username.
+func (c *Client) UpdateUserPassword(conf *client.Config, username string, newPassword string) error {
+	baseDN, scope, filters, err := userSearchParams(conf, username)
+	if err != nil {
+		return err
+	}
+
+	newValues, err := client.GetSchemaFieldRegistry(conf, newPassword)
+	if err != nil {
+		return fmt.Errorf("error updating password: %s", err)
+	}
+
+	return c.ldap.UpdatePassword(conf, baseDN, scope, newValues, filters)
+}
+
+// SearchDN returns the single object with the given DN. The search is
+// performed the same way as in UpdateDNPassword.
+func (c *Client) SearchDN(conf *client.Config, dn string) (*client.Entry, error) {
+	baseDN, scope, filters, err := dnSearchParams(conf, dn)
+	if err != nil {
+		return nil, err
+	}
+	return c.searchOne(conf, baseDN, scope, filters)
+}
+
+// SearchUser returns the single object with the given username. The search
+// is performed the same way as in UpdateUserPassword.
+func (c *Client) SearchUser(conf *client.Config, username string) (*client.Entry, error) {
+	baseDN, scope, filters, err := userSearchParams(conf, username)
+	if err != nil {
+		return nil, err
+	}
+	return c.searchOne(conf, baseDN, scope, filters)
+}
+
+// SearchSubtree returns the objects under baseDN that match the given LDAP
+// filter.
+func (c *Client) SearchSubtree(conf *client.Config, baseDN, filter string, attributes ...string) ([]*client.Entry, error) {
+	return c.ldap.SearchFilter(conf, baseDN, ldap.ScopeWholeSubtree, filter, attributes...)
+}
+
+// PasswordExpiration returns the time at which the password of the object
+// with the given DN expires. The DN must be the DN of the object, such as the
+// one returned by SearchDN or SearchUser.
+func (c *Client) PasswordExpiration(conf *client.Config, dn string) (time.Time, error) {
+	return c.ldap.PasswordExpiration(conf, dn)
+}
+
+// VerifyBind checks that the given DN can bind with the given password.
+func (c *Client) VerifyBind(conf *client.Config, dn, password string) error {
+	return c.ldap.VerifyBind(conf, dn, password)
+}
+
+// Subschema reads the object classes and attribute types the server publishes.
+func (c *Client) Subschema(conf *client.Config) (*client.Subschema, error) {
+	return c.ldap.Subschema(conf)
+}
+
+// IsGroupMember returns whether the group with the given DN lists value in
+// the given membership attribute.
+func (c *Client) IsGroupMember(conf *client.Config, groupDN, attribute, value string) (bool, error) {
+	return c.ldap.IsGroupMember(conf, groupDN, attribute, value)
+}
+
+// AddGroupMember adds value to the given membership attribute of the group
+// with the given DN. It returns false if the value was already present.
+func (c *Client) AddGroupMember(conf *client.Config, groupDN, attribute, value string) (bool, error) {
+	return c.ldap.AddGroupMember(conf, groupDN, attribute, value)
+}
+
+// RemoveGroupMember removes value from the given membership attribute of the
+// group with the given DN.
+func (c *Client) RemoveGroupMember(conf *client.Config, groupDN, attribute, value string) error {
+	return c.ldap.RemoveGroupMember(conf, groupDN, attribute, value)
+}
+
+func (c *Client) searchOne(conf *client.Config, baseDN string, scope int, filters map[*client.Field][]string) (*client.Entry, error) {
+	entries, err := c.ldap.Search(conf, baseDN, scope, filters)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) != 1 {
+		return nil, fmt.Errorf("expected one matching entry, but received %d", len(entries))
+	}
+	return entries[0], nil
+}
+
+// dnSearchParams returns the base DN, scope, and filters used to find the
+// object with the given DN. If the schema uses userPrincipalName with a
+// configured upndomain, a value that is not a DN is treated as a username and
+// searched for by its UPN under the userdn.
+func dnSearchParams(conf *client.Config, dn string) (string, int, map[*client.Field][]string, error) {
 	scope := ldap.ScopeBaseObject
 	filters := map[*client.Field][]string{
 		client.FieldRegistry.ObjectClass: {"*"},
@@ -45,26 +159,29 @@ func (c *Client) UpdateDNPassword(conf *client.Config, dn string, newPassword st
 	}
 	field := client.FieldRegistry.Parse(userAttr)
 	if field == nil {
-		return fmt.Errorf("unsupported userattr %q", userAttr)
+		return "", 0, nil, fmt.Errorf("unsupported userattr %q", userAttr)
 	}
 
-	if field == client.FieldRegistry.UserPrincipalName && conf.UPNDomain != "" {
+	// A username is found by its UPN, but a DN is searched for directly.
+	if field == client.FieldRegistry.UserPrincipalName && conf.UPNDomain != "" && !isDN(dn) {
 		scope = ldap.ScopeWholeSubtree
 		bindUser := fmt.Sprintf("%s@%s", ldaputil.EscapeLDAPValue(dn), conf.UPNDomain)
 		filters[field] = []string{bindUser}
 		dn = conf.UserDN
 	}
 
-	newValues, err := client.GetSchemaFieldRegistry(conf, newPassword)
-	if err != nil {
-		return fmt.Errorf("error updating password: %s", err)
-	}
+	return dn, scope, filters, nil
+}
 
-	return c.ldap.UpdatePassword(conf, dn, scope, newValues, filters)
+// isDN reports whether the value parses as a distinguished name.
+func isDN(value string) bool {
+	_, err := ldap.ParseDN(value)
+	return err == nil
 }
 
-// UpdateUserPassword updates the password for the object with the given username.
-func (c *Client) UpdateUserPassword(conf *client.Config, username string, newPassword string) error {
+// userSearchParams returns the base DN, scope, and filters used to find the
+// object with the given username by searching the subtree rooted at userdn.
+func userSearchParams(conf *client.Config, username string) (string, int, map[*client.Field][]string, error) {
 	userAttr := conf.UserAttr
 	if userAttr == "" {
 		userAttr = defaultUserAttr(conf.Schema)
@@ -72,21 +189,16 @@ func (c *Client) UpdateUserPassword(conf *client.Config, username string, newPas
 
 	field := client.FieldRegistry.Parse(userAttr)
 	if field == nil {
-		return fmt.Errorf("unsupported userattr %q", userAttr)
+		return "", 0, nil, fmt.Errorf("unsupported userattr %q", userAttr)
 	}
 
 	filters := map[*client.Field][]string{
 		field: {username},
 	}
 
-	newValues, err := client.GetSchemaFieldRegistry(conf, newPassword)
-	if err != nil {
-		return fmt.Errorf("error updating password: %s", err)
-	}
-
-	return c.ldap.UpdatePassword(conf, conf.UserDN, ldap.ScopeWholeSubtree, newValues, filters)
+	return conf.UserDN, ldap.ScopeWholeSubtree, filters, nil
 }
 
-func (c *Client) Execute(conf *client.Config, entries []*ldif.Entry, continueOnError bool) (err error) {
+func (c *Client) Execute(conf *client.Config, entries []*client.LDIFEntry, continueOnError bool) ([]client.ExecuteResult, error) {
 	return c.ldap.Execute(conf, entries, continueOnError)
 }
@@ -12,7 +12,6 @@ import (
 	"time"
 
 	"github.com/go-ldap/ldap/v3"
-	"github.com/go-ldap/ldif"
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/vault/sdk/helper/ldaputil"
@@ -26,6 +25,11 @@ type Config struct {
 
 	// CredentialType is used to customize the Schema. Currently only used for type racf.
 	CredentialType CredentialType `json:"credential_type"`
+
+	// DefaultPasswordPolicyDN is the DN of the ppolicy that applies to
+	// OpenLDAP entries without a pwdPolicySubentry, usually the
+	// olcPPolicyDefault of the overlay.
+	DefaultPasswordPolicyDN string `json:"default_password_policy_dn,omitempty"`
 }
 
 func New(logger hclog.Logger) Client {
@@ -55,11 +59,18 @@ type Client struct {
 }
 
 func (c *Client) Search(cfg *Config, baseDN string, scope int, filters map[*Field][]string) ([]*Entry, error) {
+	return c.SearchFilter(cfg, baseDN, scope, toString(filters))
+}
+
+// SearchFilter searches using a raw LDAP filter string, returning the given
+// attributes or all attributes if none are given.
+func (c *Client) SearchFilter(cfg *Config, baseDN string, scope int, filter string, attributes ...string) ([]*Entry, error) {
 	req := &ldap.SearchRequest{
-		BaseDN:    baseDN,
-		Scope:     scope,
-		Filter:    toString(filters),
-		SizeLimit: math.MaxInt32,
+		BaseDN:     baseDN,
+		Scope:      scope,
+		Filter:     filter,
+		Attributes: attributes,
+		SizeLimit:  math.MaxInt32,
 	}
 
 	conn, err := c.ldap.DialLDAP(cfg.ConfigEntry)
@@ -150,6 +161,21 @@ func toString(filters map[*Field][]string) string {
 	return "(&" + strings.Join(fieldEquals, "") + ")"
 }
 
+// VerifyBind checks that the given DN can bind with the given password,
+// without falling back to the last bind password.
+func (c *Client) VerifyBind(cfg *Config, dn, password string) error {
+	conn, err := c.ldap.DialLDAP(cfg.ConfigEntry)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	entry := *cfg.ConfigEntry
+	entry.BindDN = dn
+	entry.BindPassword = password
+	return bind(&Config{ConfigEntry: &entry}, conn)
+}
+
 func bind(cfg *Config, conn ldaputil.Connection) error {
 	if cfg.BindPassword == "" {
 		return errors.New("unable to bind due to lack of configured password")
@@ -206,23 +232,32 @@ func shouldTryLastPwd(lastPwd string, lastBindPasswordRotation time.Time) bool {
 	return lastBindPasswordRotation.Add(10 * time.Minute).After(time.Now())
 }
 
-func (c *Client) Execute(cfg *Config, entries []*ldif.Entry, continueOnFailure bool) (err error) {
+// modifyDNConn is implemented by connections that support ModifyDN requests,
+// which ldaputil.Connection doesn't include.
+type modifyDNConn interface {
+	ModifyDN(modifyDNRequest *ldap.ModifyDNRequest) error
+}
+
+// Execute runs the LDIF records in order and returns the result of each record
+// that was run. If continueOnFailure is false, records after the first that
+// fails are not run and have no result.
+func (c *Client) Execute(cfg *Config, entries []*LDIFEntry, continueOnFailure bool) (results []ExecuteResult, err error) {
 	if len(entries) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	conn, err := c.ldap.DialLDAP(cfg.ConfigEntry)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer conn.Close()
 
 	if err := bind(cfg, conn); err != nil {
-		return err
+		return nil, err
 	}
 
 	merr := new(multierror.Error)
-	for _, entry := range entries {
+	for i, entry := range entries {
 		if entry == nil {
 			// Skip entries that are nil since they don't indicate an error in execution. Since these entries
 			// are usually coming from an ldif parse, this should generally not happen so it's mainly to
@@ -240,19 +275,27 @@ func (c *Client) Execute(cfg *Config, entries []*ldif.Entry, continueOnFailure b
 			err = errorf("failed to run ModifyRequest: %w", conn.Modify(entry.Modify))
 		case entry.Del != nil:
 			err = errorf("failed to run DelRequest: %w", conn.Del(entry.Del))
+		case entry.ModifyDN != nil:
+			mconn, ok := conn.(modifyDNConn)
+			if !ok {
+				err = fmt.Errorf("failed to run ModifyDNRequest: connection does not support ModifyDN")
+				break
+			}
+			err = errorf("failed to run ModifyDNRequest: %w", mconn.ModifyDN(entry.ModifyDN))
 		default:
 			err = fmt.Errorf("unrecognized or missing LDIF command")
 		}
+		results = append(results, newExecuteResult(i, entry, err))
 
 		if err != nil {
 			if continueOnFailure {
 				merr = multierror.Append(merr, err)
 			} else {
-				return err
+				return results, err
 			}
 		}
 	}
-	return merr.ErrorOrNil()
+	return results, merr.ErrorOrNil()
 }
 
 func coerceToAddRequest(entry *ldap.Entry) *ldap.AddRequest {
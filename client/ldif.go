@@ -0,0 +1,361 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package client
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/go-ldap/ldif"
+)
+
+// LDIFEntry is a record of an LDIF file. Exactly one of its requests is set.
+// Entry is set for records without a changetype, which are added.
+type LDIFEntry struct {
+	Entry    *ldap.Entry
+	Add      *ldap.AddRequest
+	Del      *ldap.DelRequest
+	Modify   *ldap.ModifyRequest
+	ModifyDN *ldap.ModifyDNRequest
+}
+
+// DN returns the DN of the entry the record applies to.
+func (e *LDIFEntry) DN() string {
+	switch {
+	case e.Entry != nil:
+		return e.Entry.DN
+	case e.Add != nil:
+		return e.Add.DN
+	case e.Del != nil:
+		return e.Del.DN
+	case e.Modify != nil:
+		return e.Modify.DN
+	case e.ModifyDN != nil:
+		return e.ModifyDN.DN
+	}
+	return ""
+}
+
+// ResultDN returns the DN of the entry once the record has been applied, which
+// differs from DN for modrdn and moddn records.
+func (e *LDIFEntry) ResultDN() string {
+	if e.ModifyDN == nil {
+		return e.DN()
+	}
+	parent := e.ModifyDN.NewSuperior
+	if parent == "" {
+		parent = parentDN(e.ModifyDN.DN)
+	}
+	if parent == "" {
+		return e.ModifyDN.NewRDN
+	}
+	return e.ModifyDN.NewRDN + "," + parent
+}
+
+// parentDN returns the DN without its first RDN.
+func parentDN(dn string) string {
+	for i := 0; i < len(dn); i++ {
+		switch dn[i] {
+		case '\\':
+			i++
+		case ',':
+			return strings.TrimLeft(dn[i+1:], " ")
+		}
+	}
+	return ""
+}
+
+// ParseLDIF parses LDIF records. On top of the records that
+// github.com/go-ldap/ldif supports, it parses modrdn and moddn records, and
+// control lines with any OID and value, which are sent with the request of
+// their record:
+//
+//	control: <oid> [true|false] [: <value> | :: <base64 value>]
+func ParseLDIF(str string) ([]*LDIFEntry, error) {
+	var entries []*LDIFEntry
+	for i, record := range splitLDIFRecords(str) {
+		if i == 0 && strings.HasPrefix(record[0], "version:") {
+			record = record[1:]
+			if len(record) == 0 {
+				continue
+			}
+		}
+		entry, err := parseLDIFRecord(record)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", i+1, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// splitLDIFRecords splits LDIF into records of unfolded lines, without
+// comments.
+func splitLDIFRecords(str string) [][]string {
+	var records [][]string
+	var record []string
+	comment := false
+	for _, line := range strings.Split(str, "\n") {
+		line = strings.TrimRight(line, "\r")
+		switch {
+		case strings.TrimSpace(line) == "":
+			if len(record) > 0 {
+				records = append(records, record)
+			}
+			record = nil
+			comment = false
+		case line[0] == '#':
+			comment = true
+		case line[0] == ' ':
+			if !comment && len(record) > 0 {
+				record[len(record)-1] += line[1:]
+			}
+		default:
+			comment = false
+			record = append(record, line)
+		}
+	}
+	if len(record) > 0 {
+		records = append(records, record)
+	}
+	return records
+}
+
+func parseLDIFRecord(lines []string) (*LDIFEntry, error) {
+	var controls []ldap.Control
+	var changeType string
+	rest := make([]string, 0, len(lines))
+	for i, line := range lines {
+		attr, _, _ := strings.Cut(line, ":")
+		switch {
+		case strings.EqualFold(attr, "control"):
+			if i == 0 || changeType != "" || len(rest) > 1 {
+				return nil, fmt.Errorf("control lines must follow the dn line")
+			}
+			control, err := parseLDIFControl(line)
+			if err != nil {
+				return nil, err
+			}
+			controls = append(controls, control)
+			continue
+		case strings.EqualFold(attr, "changetype") && changeType == "":
+			_, value, err := parseLDIFLine(line)
+			if err != nil {
+				return nil, err
+			}
+			changeType = strings.ToLower(value)
+		}
+		rest = append(rest, line)
+	}
+
+	switch changeType {
+	case "modrdn", "moddn":
+		return parseModifyDNRecord(rest, controls)
+	case "":
+		if len(controls) > 0 {
+			return nil, fmt.Errorf("controls found without changetype")
+		}
+	}
+
+	parsed, err := ldif.Parse(strings.Join(rest, "\n"))
+	if err != nil {
+		return nil, err
+	}
+	if len(parsed.Entries) != 1 || parsed.Entries[0] == nil {
+		return nil, fmt.Errorf("expected a single record")
+	}
+	e := parsed.Entries[0]
+	entry := &LDIFEntry{
+		Entry:  e.Entry,
+		Add:    e.Add,
+		Del:    e.Del,
+		Modify: e.Modify,
+	}
+	switch {
+	case entry.Add != nil:
+		entry.Add.Controls = controls
+	case entry.Del != nil:
+		entry.Del.Controls = controls
+	case entry.Modify != nil:
+		entry.Modify.Controls = controls
+	}
+	return entry, nil
+}
+
+// parseModifyDNRecord parses a modrdn or moddn record, made of dn, changetype,
+// newrdn, deleteoldrdn and optionally newsuperior lines, in that order.
+func parseModifyDNRecord(lines []string, controls []ldap.Control) (*LDIFEntry, error) {
+	expected := []string{"dn", "changetype", "newrdn", "deleteoldrdn", "newsuperior"}
+	if len(lines) < len(expected)-1 || len(lines) > len(expected) {
+		return nil, fmt.Errorf("modrdn records must have newrdn, deleteoldrdn and optionally newsuperior lines")
+	}
+	values := make([]string, len(expected))
+	for i, line := range lines {
+		attr, value, err := parseLDIFLine(line)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.EqualFold(attr, expected[i]) {
+			return nil, fmt.Errorf("expected %s line, got %q", expected[i], attr)
+		}
+		values[i] = value
+	}
+
+	var deleteOldRDN bool
+	switch values[3] {
+	case "0":
+	case "1":
+		deleteOldRDN = true
+	default:
+		return nil, fmt.Errorf("deleteoldrdn must be 0 or 1, got %q", values[3])
+	}
+	if values[0] == "" || values[2] == "" {
+		return nil, fmt.Errorf("modrdn records must have a dn and a newrdn")
+	}
+
+	req := ldap.NewModifyDNWithControlsRequest(values[0], values[2], deleteOldRDN, values[4], controls)
+	return &LDIFEntry{ModifyDN: req}, nil
+}
+
+// parseLDIFControl parses a control line. Criticality defaults to false.
+func parseLDIFControl(line string) (ldap.Control, error) {
+	_, spec, _ := strings.Cut(line, ":")
+	spec, rawValue, hasValue := strings.Cut(spec, ":")
+
+	fields := strings.Fields(spec)
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, fmt.Errorf("invalid control line %q", line)
+	}
+	oid := fields[0]
+	if !validOID(oid) {
+		return nil, fmt.Errorf("invalid control OID %q", oid)
+	}
+	criticality := false
+	if len(fields) == 2 {
+		switch fields[1] {
+		case "true":
+			criticality = true
+		case "false":
+		default:
+			return nil, fmt.Errorf("invalid criticality %q of control %s", fields[1], oid)
+		}
+	}
+
+	var value string
+	if hasValue {
+		switch {
+		case strings.HasPrefix(rawValue, ":"):
+			decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(rawValue[1:]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid base64 value of control %s: %w", oid, err)
+			}
+			value = string(decoded)
+		case strings.HasPrefix(rawValue, "<"):
+			return nil, fmt.Errorf("URL values of control %s are not supported", oid)
+		default:
+			value = strings.TrimLeft(rawValue, " ")
+		}
+	}
+	return ldap.NewControlString(oid, criticality, value), nil
+}
+
+// parseLDIFLine parses an "attr: value" or "attr:: base64" line.
+func parseLDIFLine(line string) (attr, value string, err error) {
+	attr, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return "", "", fmt.Errorf("missing : in line %q", line)
+	}
+	if strings.HasPrefix(value, ":") {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value[1:]))
+		if err != nil {
+			return "", "", fmt.Errorf("invalid base64 value of %s: %w", attr, err)
+		}
+		return attr, string(decoded), nil
+	}
+	return attr, strings.TrimLeft(value, " "), nil
+}
+
+func validOID(oid string) bool {
+	if oid == "" || oid[0] == '.' || oid[len(oid)-1] == '.' || strings.Contains(oid, "..") {
+		return false
+	}
+	for _, r := range oid {
+		if r != '.' && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// Operation returns the LDIF changetype of the record.
+func (e *LDIFEntry) Operation() string {
+	switch {
+	case e.Entry != nil, e.Add != nil:
+		return "add"
+	case e.Del != nil:
+		return "delete"
+	case e.Modify != nil:
+		return "modify"
+	case e.ModifyDN != nil:
+		return "moddn"
+	}
+	return ""
+}
+
+// ExecuteResult is the result of running an LDIF record.
+type ExecuteResult struct {
+	// Index is the position of the record in the LDIF, starting at 0.
+	Index     int    `json:"index"`
+	DN        string `json:"dn"`
+	Operation string `json:"operation"`
+	Success   bool   `json:"success"`
+
+	// ResultCode is the LDAP result code of the operation. Failures that
+	// are not reported by the server, such as lost connections, have the
+	// code of the client error, or LDAPResultOther.
+	ResultCode uint16 `json:"result_code"`
+
+	// Message is the diagnostic message of a failure.
+	Message string `json:"message,omitempty"`
+}
+
+func newExecuteResult(index int, entry *LDIFEntry, err error) ExecuteResult {
+	result := ExecuteResult{
+		Index:      index,
+		DN:         entry.DN(),
+		Operation:  entry.Operation(),
+		Success:    err == nil,
+		ResultCode: ldap.LDAPResultSuccess,
+	}
+	if err == nil {
+		return result
+	}
+
+	result.ResultCode = ldap.LDAPResultOther
+	result.Message = err.Error()
+	var ldapErr *ldap.Error
+	if errors.As(err, &ldapErr) {
+		result.ResultCode = ldapErr.ResultCode
+		if ldapErr.Err != nil {
+			result.Message = ldapErr.Err.Error()
+		}
+	}
+	return result
+}
+
+// String describes the result, such as
+// "add cn=alice,dc=example: Insufficient Access Rights (50): access denied".
+func (r ExecuteResult) String() string {
+	if r.Success {
+		return fmt.Sprintf("%s %s: success", r.Operation, r.DN)
+	}
+	status := fmt.Sprintf("%s (%d)", ldap.LDAPResultCodeMap[r.ResultCode], r.ResultCode)
+	if r.Message == "" {
+		return fmt.Sprintf("%s %s: %s", r.Operation, r.DN, status)
+	}
+	return fmt.Sprintf("%s %s: %s: %s", r.Operation, r.DN, status, r.Message)
+}
@@ -0,0 +1,254 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package client
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault-plugin-secrets-openldap/ldapifc"
+	"github.com/hashicorp/vault/sdk/helper/ldaputil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLDIF(t *testing.T) {
+	entries, err := ParseLDIF(`version: 1
+
+# create the user
+dn: cn=alice,ou=users,dc=example,dc=com
+objectClass: person
+cn: alice
+
+dn: cn=alice,ou=users,dc=example,dc=com
+control: 1.2.840.113556.1.4.801 true:: MAMCAQQ=
+changetype: modify
+replace: description
+description: a long
+  description
+-
+
+dn: cn=alice,ou=users,dc=example,dc=com
+changetype: modrdn
+newrdn: cn=alice2
+deleteoldrdn: 1
+
+dn:: Y249YWxpY2UyLG91PXVzZXJzLGRjPWV4YW1wbGUsZGM9Y29t
+control: 2.16.840.1.113730.3.4.2
+changetype: moddn
+newrdn: cn=alice2
+deleteoldrdn: 0
+newsuperior: ou=disabled,dc=example,dc=com
+
+dn: cn=bob,ou=users,dc=example,dc=com
+control: 1.2.840.113556.1.4.805 false: value
+changetype: delete
+`)
+	require.NoError(t, err)
+	require.Len(t, entries, 5)
+
+	require.NotNil(t, entries[0].Entry)
+	require.Equal(t, "cn=alice,ou=users,dc=example,dc=com", entries[0].DN())
+
+	require.NotNil(t, entries[1].Modify)
+	require.Equal(t, []ldap.Control{ldap.NewControlString("1.2.840.113556.1.4.801", true, "0\x03\x02\x01\x04")}, entries[1].Modify.Controls)
+	require.Equal(t, []string{"a long description"}, entries[1].Modify.Changes[0].Modification.Vals)
+
+	require.Equal(t, ldap.NewModifyDNRequest("cn=alice,ou=users,dc=example,dc=com", "cn=alice2", true, ""), entries[2].ModifyDN)
+	require.Equal(t, "cn=alice2,ou=users,dc=example,dc=com", entries[2].ResultDN())
+
+	require.Equal(t, ldap.NewModifyDNWithControlsRequest("cn=alice2,ou=users,dc=example,dc=com", "cn=alice2", false,
+		"ou=disabled,dc=example,dc=com", []ldap.Control{ldap.NewControlString("2.16.840.1.113730.3.4.2", false, "")}), entries[3].ModifyDN)
+	require.Equal(t, "cn=alice2,ou=disabled,dc=example,dc=com", entries[3].ResultDN())
+
+	require.NotNil(t, entries[4].Del)
+	require.Equal(t, []ldap.Control{ldap.NewControlString("1.2.840.113556.1.4.805", false, "value")}, entries[4].Del.Controls)
+}
+
+func TestParseLDIF_invalid(t *testing.T) {
+	testCases := map[string]string{
+		"control without changetype": "dn: cn=alice,dc=example\ncontrol: 1.2.3\ncn: alice",
+		"control after changetype":   "dn: cn=alice,dc=example\nchangetype: delete\ncontrol: 1.2.3",
+		"invalid control oid":        "dn: cn=alice,dc=example\ncontrol: ldap.control\nchangetype: delete",
+		"invalid criticality":        "dn: cn=alice,dc=example\ncontrol: 1.2.3 yes\nchangetype: delete",
+		"invalid control value":      "dn: cn=alice,dc=example\ncontrol: 1.2.3:: !!\nchangetype: delete",
+		"url control value":          "dn: cn=alice,dc=example\ncontrol: 1.2.3:< file:///etc/passwd\nchangetype: delete",
+		"modrdn without newrdn":      "dn: cn=alice,dc=example\nchangetype: modrdn\ndeleteoldrdn: 1",
+		"modrdn out of order":        "dn: cn=alice,dc=example\nchangetype: modrdn\ndeleteoldrdn: 1\nnewrdn: cn=bob",
+		"invalid deleteoldrdn":       "dn: cn=alice,dc=example\nchangetype: modrdn\nnewrdn: cn=bob\ndeleteoldrdn: yes",
+		"unsupported changetype":     "dn: cn=alice,dc=example\nchangetype: rename",
+		"missing dn":                 "cn: alice\nobjectClass: person",
+	}
+	for name, rawLDIF := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseLDIF(rawLDIF)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestLDIFEntryResultDN(t *testing.T) {
+	testCases := map[string]struct {
+		req      *ldap.ModifyDNRequest
+		expected string
+	}{
+		"rename": {
+			req:      ldap.NewModifyDNRequest("cn=alice,dc=example", "cn=bob", true, ""),
+			expected: "cn=bob,dc=example",
+		},
+		"escaped comma": {
+			req:      ldap.NewModifyDNRequest(`cn=smith\, alice,dc=example`, "cn=bob", true, ""),
+			expected: "cn=bob,dc=example",
+		},
+		"move": {
+			req:      ldap.NewModifyDNRequest("cn=alice,dc=example", "cn=alice", true, "ou=disabled,dc=example"),
+			expected: "cn=alice,ou=disabled,dc=example",
+		},
+		"top level": {
+			req:      ldap.NewModifyDNRequest("dc=example", "dc=test", true, ""),
+			expected: "dc=test",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.expected, (&LDIFEntry{ModifyDN: tc.req}).ResultDN())
+		})
+	}
+}
+
+// modifyDNRecordingConn records modify DN requests and returns modifyDNErr from them.
+type modifyDNRecordingConn struct {
+	ldapifc.FakeLDAPConnection
+	modifyDNErr error
+	modifiedDNs []*ldap.ModifyDNRequest
+}
+
+func (m *modifyDNRecordingConn) ModifyDN(modifyDNRequest *ldap.ModifyDNRequest) error {
+	m.modifiedDNs = append(m.modifiedDNs, modifyDNRequest)
+	return m.modifyDNErr
+}
+
+// addOnlyConn is a connection that doesn't support ModifyDN.
+type addOnlyConn struct {
+	ldaputil.Connection
+}
+
+func (a *addOnlyConn) Bind(username, password string) error { return nil }
+
+func (a *addOnlyConn) Close() error { return nil }
+
+func TestExecute_modifyDN(t *testing.T) {
+	entries, err := ParseLDIF(`dn: cn=alice,ou=users,dc=example,dc=com
+changetype: modrdn
+newrdn: cn=alice2
+deleteoldrdn: 1
+
+dn: cn=bob,ou=users,dc=example,dc=com
+changetype: modrdn
+newrdn: cn=bob2
+deleteoldrdn: 1`)
+	require.NoError(t, err)
+
+	t.Run("executed", func(t *testing.T) {
+		conn := &modifyDNRecordingConn{}
+		c := &Client{ldap: &ldaputil.Client{
+			Logger: hclog.NewNullLogger(),
+			LDAP:   &ldapifc.FakeLDAPClient{ConnToReturn: conn},
+		}}
+		results, err := c.Execute(emptyConfig(), entries, false)
+		require.NoError(t, err)
+		require.Equal(t, []*ldap.ModifyDNRequest{entries[0].ModifyDN, entries[1].ModifyDN}, conn.modifiedDNs)
+		require.Equal(t, []ExecuteResult{
+			{Index: 0, DN: "cn=alice,ou=users,dc=example,dc=com", Operation: "moddn", Success: true},
+			{Index: 1, DN: "cn=bob,ou=users,dc=example,dc=com", Operation: "moddn", Success: true},
+		}, results)
+	})
+
+	t.Run("failure continues on error", func(t *testing.T) {
+		conn := &modifyDNRecordingConn{modifyDNErr: ldap.NewError(ldap.LDAPResultNoSuchObject, errors.New("no such object"))}
+		c := &Client{ldap: &ldaputil.Client{
+			Logger: hclog.NewNullLogger(),
+			LDAP:   &ldapifc.FakeLDAPClient{ConnToReturn: conn},
+		}}
+		results, err := c.Execute(emptyConfig(), entries, true)
+		require.ErrorContains(t, err, "failed to run ModifyDNRequest")
+		require.Len(t, conn.modifiedDNs, 2)
+		require.Len(t, results, 2)
+		require.False(t, results[1].Success)
+		require.Equal(t, uint16(ldap.LDAPResultNoSuchObject), results[1].ResultCode)
+		require.Equal(t, "no such object", results[1].Message)
+	})
+
+	t.Run("unsupported connection", func(t *testing.T) {
+		c := &Client{ldap: &ldaputil.Client{
+			Logger: hclog.NewNullLogger(),
+			LDAP:   &ldapifc.FakeLDAPClient{ConnToReturn: &addOnlyConn{}},
+		}}
+		results, err := c.Execute(emptyConfig(), entries, false)
+		require.ErrorContains(t, err, "connection does not support ModifyDN")
+		require.Len(t, results, 1)
+		require.Equal(t, uint16(ldap.LDAPResultOther), results[0].ResultCode)
+	})
+}
+
+// recordingConn records add and modify requests and fails those for failDN.
+type recordingConn struct {
+	ldapifc.FakeLDAPConnection
+	failDN string
+	dns    []string
+}
+
+func (r *recordingConn) run(dn string) error {
+	r.dns = append(r.dns, dn)
+	if dn == r.failDN {
+		return ldap.NewError(ldap.LDAPResultInsufficientAccessRights, errors.New("access denied"))
+	}
+	return nil
+}
+
+func (r *recordingConn) Add(request *ldap.AddRequest) error { return r.run(request.DN) }
+
+func (r *recordingConn) Modify(request *ldap.ModifyRequest) error { return r.run(request.DN) }
+
+func TestExecute_results(t *testing.T) {
+	entries, err := ParseLDIF(`dn: cn=alice,ou=users,dc=example,dc=com
+objectClass: person
+cn: alice
+
+dn: cn=admins,ou=groups,dc=example,dc=com
+changetype: modify
+add: member
+member: cn=alice,ou=users,dc=example,dc=com
+-
+
+dn: cn=auditors,ou=groups,dc=example,dc=com
+changetype: modify
+add: member
+member: cn=alice,ou=users,dc=example,dc=com
+-`)
+	require.NoError(t, err)
+
+	conn := &recordingConn{failDN: "cn=admins,ou=groups,dc=example,dc=com"}
+	c := &Client{ldap: &ldaputil.Client{
+		Logger: hclog.NewNullLogger(),
+		LDAP:   &ldapifc.FakeLDAPClient{ConnToReturn: conn},
+	}}
+	results, err := c.Execute(emptyConfig(), entries, false)
+	require.Error(t, err)
+	require.True(t, ldap.IsErrorWithCode(err, ldap.LDAPResultInsufficientAccessRights))
+	require.Equal(t, []string{"cn=alice,ou=users,dc=example,dc=com", "cn=admins,ou=groups,dc=example,dc=com"}, conn.dns)
+	require.Equal(t, []ExecuteResult{
+		{Index: 0, DN: "cn=alice,ou=users,dc=example,dc=com", Operation: "add", Success: true},
+		{
+			Index:      1,
+			DN:         "cn=admins,ou=groups,dc=example,dc=com",
+			Operation:  "modify",
+			ResultCode: ldap.LDAPResultInsufficientAccessRights,
+			Message:    "access denied",
+		},
+	}, results)
+	require.Equal(t, "add cn=alice,ou=users,dc=example,dc=com: success", results[0].String())
+	require.Equal(t, "modify cn=admins,ou=groups,dc=example,dc=com: Insufficient Access Rights (50): access denied", results[1].String())
+}
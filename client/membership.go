@@ -0,0 +1,102 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package client
+
+import (
+	"fmt"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/hashicorp/go-secure-stdlib/strutil"
+)
+
+// Attributes that list the members of a group. member and uniqueMember hold
+// the DN of each member, memberUid holds the uid of each member.
+const (
+	MemberAttributeMember       = "member"
+	MemberAttributeUniqueMember = "uniqueMember"
+	MemberAttributeMemberUID    = "memberUid"
+)
+
+// SupportedMemberAttributes returns the group membership attributes supported
+// by the plugin.
+func SupportedMemberAttributes() []string {
+	return []string{MemberAttributeMember, MemberAttributeUniqueMember, MemberAttributeMemberUID}
+}
+
+// ValidMemberAttribute checks if the group membership attribute is supported
+// by the plugin.
+func ValidMemberAttribute(attribute string) bool {
+	return strutil.StrListContains(SupportedMemberAttributes(), attribute)
+}
+
+// IsGroupMember returns whether the group with the given DN lists value in the
+// given membership attribute.
+func (c *Client) IsGroupMember(cfg *Config, groupDN, attribute, value string) (bool, error) {
+	conn, err := c.ldap.DialLDAP(cfg.ConfigEntry)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	if err := bind(cfg, conn); err != nil {
+		return false, err
+	}
+
+	result, err := conn.Search(&ldap.SearchRequest{
+		BaseDN:     groupDN,
+		Scope:      ldap.ScopeBaseObject,
+		Filter:     fmt.Sprintf("(%s=%s)", attribute, ldap.EscapeFilter(value)),
+		Attributes: []string{"1.1"},
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to search group %q: %w", groupDN, err)
+	}
+	return len(result.Entries) > 0, nil
+}
+
+// AddGroupMember adds value to the given membership attribute of the group
+// with the given DN. It returns false without an error if the value is
+// already present.
+func (c *Client) AddGroupMember(cfg *Config, groupDN, attribute, value string) (bool, error) {
+	modifyReq := ldap.NewModifyRequest(groupDN, nil)
+	modifyReq.Add(attribute, []string{value})
+
+	err := c.modifyGroup(cfg, modifyReq)
+	switch {
+	case err == nil:
+		return true, nil
+	// Active Directory reports an existing member as entryAlreadyExists.
+	case ldap.IsErrorAnyOf(err, ldap.LDAPResultAttributeOrValueExists, ldap.LDAPResultEntryAlreadyExists):
+		return false, nil
+	default:
+		return false, fmt.Errorf("failed to add member to group %q: %w", groupDN, err)
+	}
+}
+
+// RemoveGroupMember removes value from the given membership attribute of the
+// group with the given DN. It succeeds if the value is not present.
+func (c *Client) RemoveGroupMember(cfg *Config, groupDN, attribute, value string) error {
+	modifyReq := ldap.NewModifyRequest(groupDN, nil)
+	modifyReq.Delete(attribute, []string{value})
+
+	err := c.modifyGroup(cfg, modifyReq)
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchAttribute) {
+		return fmt.Errorf("failed to remove member from group %q: %w", groupDN, err)
+	}
+	return nil
+}
+
+func (c *Client) modifyGroup(cfg *Config, modifyReq *ldap.ModifyRequest) error {
+	conn, err := c.ldap.DialLDAP(cfg.ConfigEntry)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := bind(cfg, conn); err != nil {
+		return err
+	}
+
+	return conn.Modify(modifyReq)
+}
@@ -0,0 +1,116 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package client
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault-plugin-secrets-openldap/ldapifc"
+	"github.com/hashicorp/vault/sdk/helper/ldaputil"
+	"github.com/stretchr/testify/require"
+)
+
+// membershipConn records modify requests and returns modifyErr from them.
+type membershipConn struct {
+	ldapifc.FakeLDAPConnection
+	modifyErr error
+	modified  []*ldap.ModifyRequest
+}
+
+func (m *membershipConn) Modify(modifyRequest *ldap.ModifyRequest) error {
+	m.modified = append(m.modified, modifyRequest)
+	return m.modifyErr
+}
+
+func newMembershipClient(conn *membershipConn) *Client {
+	return &Client{ldap: &ldaputil.Client{
+		Logger: hclog.NewNullLogger(),
+		LDAP:   &ldapifc.FakeLDAPClient{ConnToReturn: conn},
+	}}
+}
+
+func TestAddGroupMember(t *testing.T) {
+	groupDN := "cn=prod-admins,ou=groups,dc=example,dc=com"
+	userDN := "uid=alice,ou=people,dc=example,dc=com"
+
+	testCases := map[string]struct {
+		modifyErr     error
+		expectedAdded bool
+		expectErr     bool
+	}{
+		"added": {
+			expectedAdded: true,
+		},
+		"already a member": {
+			modifyErr: ldap.NewError(ldap.LDAPResultAttributeOrValueExists, errors.New("exists")),
+		},
+		"already a member of an AD group": {
+			modifyErr: ldap.NewError(ldap.LDAPResultEntryAlreadyExists, errors.New("exists")),
+		},
+		"failure": {
+			modifyErr: ldap.NewError(ldap.LDAPResultInsufficientAccessRights, errors.New("denied")),
+			expectErr: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			conn := &membershipConn{modifyErr: tc.modifyErr}
+			added, err := newMembershipClient(conn).AddGroupMember(emptyConfig(), groupDN, MemberAttributeMember, userDN)
+			if tc.expectErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tc.expectedAdded, added)
+
+			require.Len(t, conn.modified, 1)
+			require.Equal(t, groupDN, conn.modified[0].DN)
+			require.Equal(t, uint(ldap.AddAttribute), conn.modified[0].Changes[0].Operation)
+			require.Equal(t, ldap.PartialAttribute{Type: "member", Vals: []string{userDN}}, conn.modified[0].Changes[0].Modification)
+		})
+	}
+}
+
+func TestRemoveGroupMember(t *testing.T) {
+	groupDN := "cn=prod-admins,ou=groups,dc=example,dc=com"
+
+	conn := &membershipConn{}
+	client := newMembershipClient(conn)
+	require.NoError(t, client.RemoveGroupMember(emptyConfig(), groupDN, MemberAttributeMemberUID, "alice"))
+	require.Equal(t, uint(ldap.DeleteAttribute), conn.modified[0].Changes[0].Operation)
+	require.Equal(t, ldap.PartialAttribute{Type: "memberUid", Vals: []string{"alice"}}, conn.modified[0].Changes[0].Modification)
+
+	conn.modifyErr = ldap.NewError(ldap.LDAPResultNoSuchAttribute, errors.New("not a member"))
+	require.NoError(t, client.RemoveGroupMember(emptyConfig(), groupDN, MemberAttributeMemberUID, "alice"))
+
+	conn.modifyErr = ldap.NewError(ldap.LDAPResultNoSuchObject, errors.New("no such group"))
+	require.Error(t, client.RemoveGroupMember(emptyConfig(), groupDN, MemberAttributeMemberUID, "alice"))
+}
+
+func TestIsGroupMember(t *testing.T) {
+	groupDN := "cn=prod-admins,ou=groups,dc=example,dc=com"
+	conn := &membershipConn{
+		FakeLDAPConnection: ldapifc.FakeLDAPConnection{
+			SearchRequestToExpect: &ldap.SearchRequest{
+				BaseDN: groupDN,
+				Scope:  ldap.ScopeBaseObject,
+				Filter: `(uniqueMember=uid=a\2a,dc=example,dc=com)`,
+			},
+			SearchResultToReturn: &ldap.SearchResult{Entries: []*ldap.Entry{{DN: groupDN}}},
+		},
+	}
+
+	isMember, err := newMembershipClient(conn).IsGroupMember(emptyConfig(), groupDN, MemberAttributeUniqueMember, "uid=a*,dc=example,dc=com")
+	require.NoError(t, err)
+	require.True(t, isMember)
+
+	conn.SearchResultToReturn = &ldap.SearchResult{}
+	isMember, err = newMembershipClient(conn).IsGroupMember(emptyConfig(), groupDN, MemberAttributeUniqueMember, "uid=a*,dc=example,dc=com")
+	require.NoError(t, err)
+	require.False(t, isMember)
+}
@@ -0,0 +1,222 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package client
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/hashicorp/vault/sdk/helper/ldaputil"
+)
+
+const (
+	// adUFDontExpirePassword is the userAccountControl flag that marks an AD
+	// account's password as never expiring.
+	adUFDontExpirePassword = 0x10000
+
+	// adTicksPerSecond is the number of 100-nanosecond intervals in a second,
+	// the unit used by AD for FILETIME and interval attributes.
+	adTicksPerSecond = 10000000
+
+	// adEpochOffsetSeconds is the number of seconds between the FILETIME
+	// epoch (January 1, 1601 UTC) and the Unix epoch.
+	adEpochOffsetSeconds = 11644473600
+)
+
+// PasswordExpiration returns the time at which the password of the entry with
+// the given DN expires according to the directory's effective password
+// policy. A zero time is returned if the password does not expire.
+//
+// For the AD schema, the maximum password age is read from the entry's
+// resultant fine-grained password settings object (PSO) if one applies,
+// otherwise from the domain's maxPwdAge. For the OpenLDAP schema, the
+// pwdMaxAge is read from the ppolicy referenced by the entry's
+// pwdPolicySubentry, or from the DefaultPasswordPolicyDN for entries that fall
+// under the overlay's default policy. The default policy is not visible over
+// LDAP, so without DefaultPasswordPolicyDN such entries never expire.
+func (c *Client) PasswordExpiration(cfg *Config, dn string) (time.Time, error) {
+	conn, err := c.ldap.DialLDAP(cfg.ConfigEntry)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer conn.Close()
+
+	if err := bind(cfg, conn); err != nil {
+		return time.Time{}, err
+	}
+
+	switch cfg.Schema {
+	case SchemaAD:
+		return adPasswordExpiration(conn, dn)
+	case SchemaOpenLDAP:
+		return ppolicyPasswordExpiration(conn, dn, cfg.DefaultPasswordPolicyDN)
+	default:
+		return time.Time{}, fmt.Errorf("password expiration is not supported for schema %s", cfg.Schema)
+	}
+}
+
+func adPasswordExpiration(conn ldaputil.Connection, dn string) (time.Time, error) {
+	user, err := readEntry(conn, dn, "pwdLastSet", "userAccountControl", "msDS-ResultantPSO")
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if uac := user.GetAttributeValue("userAccountControl"); uac != "" {
+		flags, err := strconv.ParseInt(uac, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid userAccountControl %q: %w", uac, err)
+		}
+		if flags&adUFDontExpirePassword != 0 {
+			return time.Time{}, nil
+		}
+	}
+
+	pwdLastSet, err := ParseFileTime(user.GetAttributeValue("pwdLastSet"))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid pwdLastSet: %w", err)
+	}
+
+	var maxAge time.Duration
+	if psoDN := user.GetAttributeValue("msDS-ResultantPSO"); psoDN != "" {
+		pso, err := readEntry(conn, psoDN, "msDS-MaximumPasswordAge")
+		if err != nil {
+			return time.Time{}, fmt.Errorf("unable to read password settings object: %w", err)
+		}
+		maxAge, err = ParseInterval(pso.GetAttributeValue("msDS-MaximumPasswordAge"))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid msDS-MaximumPasswordAge: %w", err)
+		}
+	} else {
+		rootDSE, err := readEntry(conn, "", "defaultNamingContext")
+		if err != nil {
+			return time.Time{}, fmt.Errorf("unable to read root DSE: %w", err)
+		}
+		domain, err := readEntry(conn, rootDSE.GetAttributeValue("defaultNamingContext"), "maxPwdAge")
+		if err != nil {
+			return time.Time{}, fmt.Errorf("unable to read domain password policy: %w", err)
+		}
+		maxAge, err = ParseInterval(domain.GetAttributeValue("maxPwdAge"))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid maxPwdAge: %w", err)
+		}
+	}
+
+	if maxAge == 0 {
+		return time.Time{}, nil
+	}
+	return pwdLastSet.Add(maxAge), nil
+}
+
+func ppolicyPasswordExpiration(conn ldaputil.Connection, dn, defaultPolicyDN string) (time.Time, error) {
+	user, err := readEntry(conn, dn, "pwdChangedTime", "pwdPolicySubentry")
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	policyDN := user.GetAttributeValue("pwdPolicySubentry")
+	if policyDN == "" {
+		policyDN = defaultPolicyDN
+	}
+	changed := user.GetAttributeValue("pwdChangedTime")
+	if policyDN == "" || changed == "" {
+		// Without a policy or a change time there is no expiration that can
+		// be determined over LDAP.
+		return time.Time{}, nil
+	}
+
+	pwdChangedTime, err := ParseGeneralizedTime(changed)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid pwdChangedTime: %w", err)
+	}
+
+	policy, err := readEntry(conn, policyDN, "pwdMaxAge")
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to read password policy: %w", err)
+	}
+	maxAgeRaw := policy.GetAttributeValue("pwdMaxAge")
+	if maxAgeRaw == "" {
+		return time.Time{}, nil
+	}
+	maxAge, err := strconv.ParseInt(maxAgeRaw, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid pwdMaxAge %q: %w", maxAgeRaw, err)
+	}
+	if maxAge <= 0 {
+		return time.Time{}, nil
+	}
+	return pwdChangedTime.Add(time.Duration(maxAge) * time.Second), nil
+}
+
+// readEntry reads the given attributes of the entry with the given DN.
+func readEntry(conn ldaputil.Connection, dn string, attributes ...string) (*ldap.Entry, error) {
+	result, err := conn.Search(&ldap.SearchRequest{
+		BaseDN:     dn,
+		Scope:      ldap.ScopeBaseObject,
+		Filter:     "(objectClass=*)",
+		Attributes: attributes,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search ldap server: %w", err)
+	}
+	if len(result.Entries) != 1 {
+		return nil, fmt.Errorf("expected one matching entry for %q, but received %d", dn, len(result.Entries))
+	}
+	return result.Entries[0], nil
+}
+
+// ParseFileTime parses an AD FILETIME value, the number of 100-nanosecond
+// intervals since January 1, 1601 UTC.
+func ParseFileTime(s string) (time.Time, error) {
+	ticks, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if ticks < 0 {
+		return time.Time{}, errors.New("negative FILETIME")
+	}
+	seconds := ticks/adTicksPerSecond - adEpochOffsetSeconds
+	nanos := (ticks % adTicksPerSecond) * 100
+	return time.Unix(seconds, nanos).UTC(), nil
+}
+
+// FormatFileTime formats the given time as an AD FILETIME value, such as used
+// by accountExpires.
+func FormatFileTime(t time.Time) string {
+	ticks := (t.Unix()+adEpochOffsetSeconds)*adTicksPerSecond + int64(t.Nanosecond()/100)
+	return strconv.FormatInt(ticks, 10)
+}
+
+// ParseInterval parses an AD interval value such as maxPwdAge, which is
+// stored as a negative number of 100-nanosecond intervals. A zero duration is
+// returned for intervals that represent "never".
+func ParseInterval(s string) (time.Duration, error) {
+	ticks, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if ticks == math.MinInt64 || ticks == 0 {
+		return 0, nil
+	}
+	if ticks > 0 {
+		return 0, fmt.Errorf("expected a negative interval, got %d", ticks)
+	}
+	ticks = -ticks
+	return time.Duration(ticks/adTicksPerSecond)*time.Second + time.Duration(ticks%adTicksPerSecond)*100, nil
+}
+
+// ParseGeneralizedTime parses an LDAP GeneralizedTime value such as
+// "20240101120000Z".
+func ParseGeneralizedTime(s string) (time.Time, error) {
+	for _, layout := range []string{"20060102150405Z0700", "20060102150405.999999999Z0700"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized generalized time %q", s)
+}
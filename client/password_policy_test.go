@@ -0,0 +1,172 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/hashicorp/vault-plugin-secrets-openldap/ldapifc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFileTime(t *testing.T) {
+	tests := map[string]struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		"epoch": {
+			value: "0",
+			want:  time.Date(1601, time.January, 1, 0, 0, 0, 0, time.UTC),
+		},
+		"unix epoch": {
+			value: "116444736000000000",
+			want:  time.Unix(0, 0).UTC(),
+		},
+		"recent": {
+			value: "133485408000000000",
+			want:  time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		},
+		"negative": {
+			value:   "-1",
+			wantErr: true,
+		},
+		"not a number": {
+			value:   "never",
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseFileTime(tc.value)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, tc.want.Equal(got), "expected %s, got %s", tc.want, got)
+		})
+	}
+}
+
+func TestParseInterval(t *testing.T) {
+	tests := map[string]struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		"42 days": {
+			value: "-36288000000000",
+			want:  42 * 24 * time.Hour,
+		},
+		"never": {
+			value: "-9223372036854775808",
+			want:  0,
+		},
+		"zero": {
+			value: "0",
+			want:  0,
+		},
+		"positive": {
+			value:   "36288000000000",
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseInterval(tc.value)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestParseGeneralizedTime(t *testing.T) {
+	got, err := ParseGeneralizedTime("20240101120000Z")
+	require.NoError(t, err)
+	require.True(t, time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC).Equal(got))
+
+	got, err = ParseGeneralizedTime("20240101120000.5Z")
+	require.NoError(t, err)
+	require.True(t, time.Date(2024, time.January, 1, 12, 0, 0, 500000000, time.UTC).Equal(got))
+
+	_, err = ParseGeneralizedTime("2024-01-01")
+	require.Error(t, err)
+}
+
+func TestFormatFileTime(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.Equal(t, "133485408000000000", FormatFileTime(ts))
+
+	// FormatFileTime is the inverse of ParseFileTime
+	parsed, err := ParseFileTime(FormatFileTime(ts.Add(1500 * time.Millisecond)))
+	require.NoError(t, err)
+	require.True(t, parsed.Equal(ts.Add(1500*time.Millisecond)))
+}
+
+// entryConn returns the entry whose DN matches the base of each search.
+type entryConn struct {
+	ldapifc.FakeLDAPConnection
+	entries map[string]*ldap.Entry
+}
+
+func (e *entryConn) Search(searchRequest *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	result := &ldap.SearchResult{}
+	if entry, ok := e.entries[searchRequest.BaseDN]; ok {
+		result.Entries = append(result.Entries, entry)
+	}
+	return result, nil
+}
+
+func TestPpolicyPasswordExpiration(t *testing.T) {
+	userDN := "uid=alice,ou=people,dc=example,dc=com"
+	changed := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	policies := map[string]*ldap.Entry{
+		"cn=default,ou=policies,dc=example,dc=com": ldap.NewEntry("cn=default,ou=policies,dc=example,dc=com",
+			map[string][]string{"pwdMaxAge": {"3600"}}),
+		"cn=admins,ou=policies,dc=example,dc=com": ldap.NewEntry("cn=admins,ou=policies,dc=example,dc=com",
+			map[string][]string{"pwdMaxAge": {"60"}}),
+	}
+
+	testCases := map[string]struct {
+		subentry        string
+		defaultPolicyDN string
+		expected        time.Time
+	}{
+		"policy subentry": {
+			subentry:        "cn=admins,ou=policies,dc=example,dc=com",
+			defaultPolicyDN: "cn=default,ou=policies,dc=example,dc=com",
+			expected:        changed.Add(time.Minute),
+		},
+		"default policy": {
+			defaultPolicyDN: "cn=default,ou=policies,dc=example,dc=com",
+			expected:        changed.Add(time.Hour),
+		},
+		"no policy": {},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			attributes := map[string][]string{"pwdChangedTime": {"20240101120000Z"}}
+			if tc.subentry != "" {
+				attributes["pwdPolicySubentry"] = []string{tc.subentry}
+			}
+			conn := &entryConn{entries: map[string]*ldap.Entry{userDN: ldap.NewEntry(userDN, attributes)}}
+			for dn, policy := range policies {
+				conn.entries[dn] = policy
+			}
+
+			expiresAt, err := ppolicyPasswordExpiration(conn, userDN, tc.defaultPolicyDN)
+			require.NoError(t, err)
+			require.True(t, tc.expected.Equal(expiresAt), "expected %s, got %s", tc.expected, expiresAt)
+		})
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package client
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Subschema holds the object class and attribute type names published by a
+// server's subschema entry.
+type Subschema struct {
+	objectClasses  map[string]struct{}
+	attributeTypes map[string]struct{}
+}
+
+// schemaNamesRegex matches the NAME field of an RFC 4512 object class or
+// attribute type description, which is either a single quoted name or a
+// parenthesized list of quoted names.
+var schemaNamesRegex = regexp.MustCompile(`\bNAME\s+(?:'([^']+)'|\(([^)]*)\))`)
+
+// ParseSubschema builds a Subschema from the objectClasses and attributeTypes
+// values of a subschema entry.
+func ParseSubschema(objectClassDefs, attributeTypeDefs []string) *Subschema {
+	return &Subschema{
+		objectClasses:  parseSchemaNames(objectClassDefs),
+		attributeTypes: parseSchemaNames(attributeTypeDefs),
+	}
+}
+
+func parseSchemaNames(defs []string) map[string]struct{} {
+	names := make(map[string]struct{})
+	for _, def := range defs {
+		match := schemaNamesRegex.FindStringSubmatch(def)
+		if match == nil {
+			continue
+		}
+		if match[1] != "" {
+			names[strings.ToLower(match[1])] = struct{}{}
+			continue
+		}
+		for _, name := range strings.Fields(match[2]) {
+			names[strings.ToLower(strings.Trim(name, "'"))] = struct{}{}
+		}
+	}
+	return names
+}
+
+// HasObjectClass returns whether the schema defines the given object class.
+func (s *Subschema) HasObjectClass(name string) bool {
+	_, ok := s.objectClasses[strings.ToLower(name)]
+	return ok
+}
+
+// HasAttributeType returns whether the schema defines the given attribute
+// type. Attribute options such as ";binary" are ignored.
+func (s *Subschema) HasAttributeType(name string) bool {
+	name, _, _ = strings.Cut(name, ";")
+	_, ok := s.attributeTypes[strings.ToLower(name)]
+	return ok
+}
+
+// Subschema reads the schema published by the subschema entry that the server's
+// root DSE refers to.
+func (c *Client) Subschema(cfg *Config) (*Subschema, error) {
+	conn, err := c.ldap.DialLDAP(cfg.ConfigEntry)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := bind(cfg, conn); err != nil {
+		return nil, err
+	}
+
+	rootDSE, err := readEntry(conn, "", "subschemaSubentry")
+	if err != nil {
+		return nil, fmt.Errorf("unable to read root DSE: %w", err)
+	}
+	subschemaDN := rootDSE.GetAttributeValue("subschemaSubentry")
+	if subschemaDN == "" {
+		return nil, fmt.Errorf("the server does not publish a subschemaSubentry")
+	}
+
+	subschema, err := readEntry(conn, subschemaDN, "objectClasses", "attributeTypes")
+	if err != nil {
+		return nil, fmt.Errorf("unable to read subschema: %w", err)
+	}
+	return ParseSubschema(subschema.GetAttributeValues("objectClasses"), subschema.GetAttributeValues("attributeTypes")), nil
+}
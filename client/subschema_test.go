@@ -0,0 +1,33 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSubschema(t *testing.T) {
+	schema := ParseSubschema(
+		[]string{
+			"( 2.5.6.6 NAME 'person' DESC 'RFC2256: a person' SUP top STRUCTURAL MUST ( sn $ cn ) )",
+			"( 2.16.840.1.113730.3.2.2 NAME 'inetOrgPerson' SUP organizationalPerson STRUCTURAL )",
+		},
+		[]string{
+			"( 2.5.4.3 NAME ( 'cn' 'commonName' ) DESC 'RFC4519: common name(s)' SUP name )",
+			"( 2.5.4.35 NAME 'userPassword' EQUALITY octetStringMatch )",
+			"( 1.2.3.4 DESC 'no name' )",
+		},
+	)
+
+	require.True(t, schema.HasObjectClass("person"))
+	require.True(t, schema.HasObjectClass("InetOrgPerson"))
+	require.False(t, schema.HasObjectClass("user"))
+
+	require.True(t, schema.HasAttributeType("cn"))
+	require.True(t, schema.HasAttributeType("commonName"))
+	require.True(t, schema.HasAttributeType("userPassword;binary"))
+	require.False(t, schema.HasAttributeType("sAMAccountName"))
+}
@@ -74,6 +74,51 @@ func Test_UpdateDNPassword_AD_UserPrincipalName(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+// UpdateDNPassword with a upndomain searches for a DN directly rather than
+// by its UPN.
+func Test_UpdateDNPassword_AD_UserPrincipalName_DN(t *testing.T) {
+	newPassword := "newpassword"
+	conn := &ldapifc.FakeLDAPConnection{
+		ModifyRequestToExpect: &ldap.ModifyRequest{
+			DN: "CN=Bob,CN=Users,DC=example,DC=net",
+		},
+		SearchRequestToExpect: &ldap.SearchRequest{
+			BaseDN: "CN=Bob,CN=Users,DC=example,DC=net",
+			Scope:  ldap.ScopeBaseObject,
+			Filter: "(objectClass=*)",
+		},
+		SearchResultToReturn: &ldap.SearchResult{
+			Entries: []*ldap.Entry{
+				{
+					DN: "CN=Bob,CN=Users,DC=example,DC=net",
+				},
+			},
+		},
+	}
+
+	c := GetTestClient(conn)
+	config := &client.Config{
+		ConfigEntry: &ldaputil.ConfigEntry{
+			Url:          "ldaps://ldap:386",
+			UserDN:       "cn=users",
+			UPNDomain:    "example.net",
+			UserAttr:     "userPrincipalName",
+			BindDN:       "username",
+			BindPassword: "password",
+		},
+		Schema: client.SchemaAD,
+	}
+
+	fields, err := client.GetSchemaFieldRegistry(config, newPassword)
+	assert.NoError(t, err)
+	for k, v := range fields {
+		conn.ModifyRequestToExpect.Replace(k.String(), v)
+	}
+
+	err = c.UpdateDNPassword(config, "CN=Bob,CN=Users,DC=example,DC=net", newPassword)
+	assert.NoError(t, err)
+}
+
 // Test_UpdateDNPassword_AD_UserPrincipalName_Missing_upndomain.
 func Test_UpdateDNPassword_AD_UserPrincipalName_Missing_upndomain(t *testing.T) {
 	newPassword := "newpassword"
@@ -0,0 +1,198 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// dynamicCredsIndexPath is the storage prefix of the index of credentials
+// issued by LDIF dynamic roles. Each role has a record per username under
+// dynamic-creds/<role>/, written before the creation LDIF runs and removed
+// once the credential's entries have been deleted. Records hold the password
+// of rotated credentials, so the prefix is seal wrapped.
+const dynamicCredsIndexPath = "dynamic-creds/"
+
+// issuedCredential is a record of the index of issued dynamic credentials.
+type issuedCredential struct {
+	Username           string    `json:"username"`
+	DistinguishedNames []string  `json:"distinguished_names"`
+	IssueTime          time.Time `json:"issue_time"`
+	ExpireTime         time.Time `json:"expire_time"`
+
+	// LeaseID is only known once Vault has passed the lease to the plugin,
+	// since lease IDs are assigned after the credential is issued.
+	LeaseID string `json:"lease_id,omitempty"`
+
+	// EntityID is the entity that requested the credential, if any.
+	EntityID string `json:"entity_id,omitempty"`
+
+	// TemplateData is the template data of the lease. The password is only
+	// set once the credential has been rotated, and supersedes the password
+	// in the lease's internal data.
+	TemplateData         *dynamicTemplateData `json:"template_data,omitempty"`
+	LastPasswordRotation time.Time            `json:"last_password_rotation"`
+}
+
+func (c *issuedCredential) toMap() map[string]interface{} {
+	m := map[string]interface{}{
+		"username":            c.Username,
+		"distinguished_names": c.DistinguishedNames,
+		"lease_id":            c.LeaseID,
+		"entity_id":           c.EntityID,
+		"issue_time":          c.IssueTime,
+		"expire_time":         c.ExpireTime,
+	}
+	if !c.LastPasswordRotation.IsZero() {
+		m["last_password_rotation"] = c.LastPasswordRotation
+	}
+	return m
+}
+
+// applyRotatedPassword updates template data decoded from a lease with the
+// password the credential was last rotated to, and returns whether the
+// credential has been rotated.
+func (c *issuedCredential) applyRotatedPassword(templateData *dynamicTemplateData) bool {
+	if c.TemplateData == nil || c.TemplateData.Password == "" {
+		return false
+	}
+	templateData.Password = c.TemplateData.Password
+	return true
+}
+
+// live returns whether the lease of the credential may still exist.
+func (c *issuedCredential) live(now time.Time) bool {
+	return c.ExpireTime.IsZero() || now.Before(c.ExpireTime)
+}
+
+func issuedCredentialPath(roleName, username string) string {
+	// Usernames are escaped so that they map to a single storage key.
+	return path.Join(dynamicCredsIndexPath, roleName, url.PathEscape(username))
+}
+
+func storeIssuedCredential(ctx context.Context, s logical.Storage, roleName string, cred *issuedCredential) error {
+	entry, err := logical.StorageEntryJSON(issuedCredentialPath(roleName, cred.Username), cred)
+	if err != nil {
+		return fmt.Errorf("unable to marshal storage entry: %w", err)
+	}
+	if err := s.Put(ctx, entry); err != nil {
+		return fmt.Errorf("failed to store issued credential: %w", err)
+	}
+	return nil
+}
+
+func retrieveIssuedCredential(ctx context.Context, s logical.Storage, roleName, username string) (*issuedCredential, error) {
+	entry, err := s.Get(ctx, issuedCredentialPath(roleName, username))
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	cred := new(issuedCredential)
+	if err := entry.DecodeJSON(cred); err != nil {
+		return nil, err
+	}
+	return cred, nil
+}
+
+func deleteIssuedCredential(ctx context.Context, s logical.Storage, roleName, username string) error {
+	return s.Delete(ctx, issuedCredentialPath(roleName, username))
+}
+
+// listIssuedCredentials returns the index records of the given role.
+func listIssuedCredentials(ctx context.Context, s logical.Storage, roleName string) ([]*issuedCredential, error) {
+	keys, err := s.List(ctx, path.Join(dynamicCredsIndexPath, roleName)+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	var creds []*issuedCredential
+	for _, key := range keys {
+		// Keys ending in a slash hold the records of roles nested under this
+		// role's name.
+		if strings.HasSuffix(key, "/") {
+			continue
+		}
+		username, err := url.PathUnescape(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid issued credential key %q: %w", key, err)
+		}
+		cred, err := retrieveIssuedCredential(ctx, s, roleName, username)
+		if err != nil {
+			return nil, err
+		}
+		if cred != nil {
+			creds = append(creds, cred)
+		}
+	}
+	return creds, nil
+}
+
+// findIssuedCredentialByLease returns the index record of the role with the
+// given lease ID, if the plugin has seen the lease.
+func findIssuedCredentialByLease(ctx context.Context, s logical.Storage, roleName, leaseID string) (*issuedCredential, error) {
+	creds, err := listIssuedCredentials(ctx, s, roleName)
+	if err != nil {
+		return nil, err
+	}
+	for _, cred := range creds {
+		if cred.LeaseID == leaseID {
+			return cred, nil
+		}
+	}
+	return nil, nil
+}
+
+// issuedCredentialLock returns the lock that serializes changes to the index
+// record of a credential, such as renewals and password rotations.
+func (b *backend) issuedCredentialLock(roleName, username string) *locksutil.LockEntry {
+	return locksutil.LockForKey(b.roleLocks, issuedCredentialPath(roleName, username))
+}
+
+// renewIssuedCredential records the expiration and ID of a renewed lease in
+// the index, and updates the lease's template data with a rotated password.
+// Leases issued before the index existed have no record.
+func (b *backend) renewIssuedCredential(ctx context.Context, req *logical.Request, dRole *dynamicRole) error {
+	templateData, err := decodeTemplateData(req.Secret.InternalData["template_data"])
+	if err != nil || templateData.Username == "" {
+		return nil
+	}
+
+	cred, err := retrieveIssuedCredential(ctx, req.Storage, dRole.Name, templateData.Username)
+	if err != nil {
+		return fmt.Errorf("failed to read issued credential record: %w", err)
+	}
+	if cred == nil {
+		return nil
+	}
+	if cred.applyRotatedPassword(&templateData) {
+		req.Secret.InternalData["template_data"] = templateData
+	}
+	if cred.LeaseID == "" {
+		cred.LeaseID = req.Secret.LeaseID
+	}
+	cred.ExpireTime = b.leaseExpiration(req.Secret.IssueTime, dRole)
+	return storeIssuedCredential(ctx, req.Storage, dRole.Name, cred)
+}
+
+// normalizeDN returns a form of the DN that can be compared with other
+// normalized DNs.
+func normalizeDN(dn string) string {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil {
+		return strings.ToLower(dn)
+	}
+	return strings.ToLower(parsed.String())
+}
@@ -0,0 +1,79 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssuedCredentialIndex(t *testing.T) {
+	ctx := context.Background()
+	b, storage := getBackend(false)
+	defer b.Cleanup(ctx)
+	configureOpenLDAPMount(t, b, storage)
+
+	for _, role := range []string{"org", "org/nested"} {
+		data := getTestDynamicRoleConfig(role)
+		data["default_ttl"] = "1h"
+		data["max_ttl"] = "2h"
+		resp, err := createDynamicRoleWithData(t, b, storage, role, data)
+		require.NoError(t, err)
+		require.False(t, resp != nil && resp.IsError())
+	}
+	b.client = &executeRecordingClient{}
+
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      dynamicCredPath + "org",
+		Storage:   storage,
+	})
+	require.NoError(t, err)
+	username := resp.Data["username"].(string)
+	_, err = b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      dynamicCredPath + "org/nested",
+		Storage:   storage,
+	})
+	require.NoError(t, err)
+
+	// Records of nested roles are not listed with the parent role.
+	creds, err := listIssuedCredentials(ctx, storage, "org")
+	require.NoError(t, err)
+	require.Len(t, creds, 1)
+	require.Equal(t, username, creds[0].Username)
+	require.Equal(t, resp.Data["distinguished_names"], creds[0].DistinguishedNames)
+	require.WithinDuration(t, time.Now().Add(time.Hour), creds[0].ExpireTime, time.Minute)
+
+	secret := resp.Secret
+	secret.IssueTime = time.Now().Add(-90 * time.Minute)
+	_, err = b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.RenewOperation,
+		Storage:   storage,
+		Secret:    secret,
+	})
+	require.NoError(t, err)
+	cred, err := retrieveIssuedCredential(ctx, storage, "org", username)
+	require.NoError(t, err)
+	require.WithinDuration(t, secret.IssueTime.Add(2*time.Hour), cred.ExpireTime, time.Second)
+
+	_, err = b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   storage,
+		Secret:    secret,
+	})
+	require.NoError(t, err)
+	creds, err = listIssuedCredentials(ctx, storage, "org")
+	require.NoError(t, err)
+	require.Empty(t, creds)
+}
+
+func TestNormalizeDN(t *testing.T) {
+	require.Equal(t, normalizeDN("CN=Alice,OU=Users,DC=example,DC=com"), normalizeDN("cn=alice, ou=users, dc=example, dc=com"))
+	require.NotEqual(t, normalizeDN("cn=alice,dc=example"), normalizeDN("cn=bob,dc=example"))
+}
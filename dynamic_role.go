@@ -12,6 +12,15 @@ import (
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
+const (
+	// dynamicRoleTypeLDIF roles create and delete accounts with LDIF. This is
+	// the default.
+	dynamicRoleTypeLDIF = "ldif"
+	// dynamicRoleTypeMembership roles add an existing user to groups for the
+	// duration of the lease.
+	dynamicRoleTypeMembership = "membership"
+)
+
 type dynamicRole struct {
 	// required fields
 	Name         string `json:"name"          mapstructure:"name"`
@@ -19,10 +28,47 @@ type dynamicRole struct {
 	DeletionLDIF string `json:"deletion_ldif" mapstructure:"deletion_ldif"`
 
 	// optional fields
-	RollbackLDIF     string        `json:"rollback_ldif"               mapstructure:"rollback_ldif,omitempty"`
-	UsernameTemplate string        `json:"username_template,omitempty" mapstructure:"username_template,omitempty"`
-	DefaultTTL       time.Duration `json:"default_ttl,omitempty"       mapstructure:"default_ttl,omitempty"`
-	MaxTTL           time.Duration `json:"max_ttl,omitempty"           mapstructure:"max_ttl,omitempty"`
+	Type                     string             `json:"type,omitempty"                       mapstructure:"type,omitempty"`
+	RollbackLDIF             string             `json:"rollback_ldif"                        mapstructure:"rollback_ldif,omitempty"`
+	RenewalLDIF              string             `json:"renewal_ldif,omitempty"               mapstructure:"renewal_ldif,omitempty"`
+	RotationLDIF             string             `json:"rotation_ldif,omitempty"              mapstructure:"rotation_ldif,omitempty"`
+	UsernameTemplate         string             `json:"username_template,omitempty"          mapstructure:"username_template,omitempty"`
+	UsernameCollisionRetries int                `json:"username_collision_retries,omitempty" mapstructure:"username_collision_retries,omitempty"`
+	DefaultTTL               time.Duration      `json:"default_ttl,omitempty"                mapstructure:"default_ttl,omitempty"`
+	MaxTTL                   time.Duration      `json:"max_ttl,omitempty"                    mapstructure:"max_ttl,omitempty"`
+	OrphanSearch             *orphanSearch      `json:"orphan_search,omitempty"              mapstructure:"orphan_search,omitempty"`
+	AllowedParameters        []allowedParameter `json:"allowed_parameters,omitempty"         mapstructure:"allowed_parameters,omitempty"`
+	MaxActiveCredentials     int                `json:"max_active_credentials,omitempty"     mapstructure:"max_active_credentials,omitempty"`
+	MaxActivePerEntity       int                `json:"max_active_per_entity,omitempty"      mapstructure:"max_active_per_entity,omitempty"`
+	VerifyCredentials        bool               `json:"verify_credentials,omitempty"         mapstructure:"verify_credentials,omitempty"`
+	VerifyBindAs             string             `json:"verify_bind_as,omitempty"             mapstructure:"verify_bind_as,omitempty"`
+	VerifyAllURLs            bool               `json:"verify_all_urls,omitempty"            mapstructure:"verify_all_urls,omitempty"`
+	VerifyTimeout            time.Duration      `json:"verify_timeout,omitempty"             mapstructure:"verify_timeout,omitempty"`
+	CredentialType           string             `json:"credential_type,omitempty"            mapstructure:"credential_type,omitempty"`
+	SSHKeyType               string             `json:"ssh_key_type,omitempty"               mapstructure:"ssh_key_type,omitempty"`
+	SSHKeyBits               int                `json:"ssh_key_bits,omitempty"               mapstructure:"ssh_key_bits,omitempty"`
+	GeneratePassword         bool               `json:"generate_password,omitempty"          mapstructure:"generate_password,omitempty"`
+	ResponseTemplate         map[string]string  `json:"response_template,omitempty"          mapstructure:"response_template,omitempty"`
+
+	// LDIFSources holds the LDIF fields as written for fields that include
+	// shared ldif-template fragments. The fields themselves hold the resolved
+	// LDIF that is executed.
+	LDIFSources map[string]string `json:"ldif_sources,omitempty" mapstructure:"-"`
+
+	// membership role fields
+	GroupDNs        []string `json:"group_dns,omitempty"        mapstructure:"group_dns,omitempty"`
+	MemberAttribute string   `json:"member_attribute,omitempty" mapstructure:"member_attribute,omitempty"`
+	UserDNTemplate  string   `json:"user_dn_template,omitempty" mapstructure:"user_dn_template,omitempty"`
+	AllowedUserDNs  []string `json:"allowed_user_dns,omitempty" mapstructure:"allowed_user_dns,omitempty"`
+}
+
+// roleType returns the type of the role, defaulting to LDIF for roles
+// written before role types were introduced.
+func (r *dynamicRole) roleType() string {
+	if r.Type == "" {
+		return dynamicRoleTypeLDIF
+	}
+	return r.Type
 }
 
 func retrieveDynamicRole(ctx context.Context, s logical.Storage, roleName string) (*dynamicRole, error) {
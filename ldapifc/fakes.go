@@ -81,3 +81,7 @@ func (f *FakeLDAPConnection) Add(request *ldap.AddRequest) error {
 func (f *FakeLDAPConnection) Del(request *ldap.DelRequest) error {
 	return nil
 }
+
+func (f *FakeLDAPConnection) ModifyDN(request *ldap.ModifyDNRequest) error {
+	return nil
+}
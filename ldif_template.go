@@ -0,0 +1,194 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// ldifTemplatePath is the storage prefix of the shared LDIF fragments that
+// dynamic role LDIF includes with {{template "name" .}}.
+const ldifTemplatePath = "ldif-template/"
+
+// maxLDIFTemplateDepth limits how deeply fragments can include each other.
+const maxLDIFTemplateDepth = 10
+
+// templateIncludeRegex matches {{template "name" arg}} actions, including
+// their trim markers.
+var templateIncludeRegex = regexp.MustCompile(`\{\{(- )?\s*template\s+"([^"]*)"\s*(.*?)\s*( -)?\}\}`)
+
+// ldifTemplate is a named fragment of LDIF shared by dynamic roles.
+type ldifTemplate struct {
+	Name     string `json:"name"`
+	Template string `json:"template"`
+}
+
+func retrieveLDIFTemplate(ctx context.Context, s logical.Storage, name string) (*ldifTemplate, error) {
+	entry, err := s.Get(ctx, path.Join(ldifTemplatePath, name))
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	t := new(ldifTemplate)
+	if err := entry.DecodeJSON(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func storeLDIFTemplate(ctx context.Context, s logical.Storage, t *ldifTemplate) error {
+	entry, err := logical.StorageEntryJSON(path.Join(ldifTemplatePath, t.Name), t)
+	if err != nil {
+		return fmt.Errorf("unable to marshal storage entry: %w", err)
+	}
+	if err := s.Put(ctx, entry); err != nil {
+		return fmt.Errorf("failed to store ldif template: %w", err)
+	}
+	return nil
+}
+
+// listLDIFTemplates returns the templates of all shared fragments by name.
+func listLDIFTemplates(ctx context.Context, s logical.Storage) (map[string]string, error) {
+	names, err := s.List(ctx, ldifTemplatePath)
+	if err != nil {
+		return nil, err
+	}
+	fragments := make(map[string]string, len(names))
+	for _, name := range names {
+		t, err := retrieveLDIFTemplate(ctx, s, name)
+		if err != nil {
+			return nil, err
+		}
+		if t != nil {
+			fragments[name] = t.Template
+		}
+	}
+	return fragments, nil
+}
+
+// resolveLDIFTemplates replaces the {{template "name" .}} includes of an LDIF
+// template with the shared fragments they name, recursively. Fragments are
+// inlined rather than passed to text/template as associated templates, so
+// that the resolved LDIF is self-contained and can be stored with leases and
+// shown on role reads. Trim markers of includes are applied to the
+// surrounding text as text/template would. The names of the fragments used are
+// returned sorted.
+func resolveLDIFTemplates(rawTemplate string, fragments map[string]string) (string, []string, error) {
+	used := make(map[string]bool)
+	resolved, err := resolveIncludes(rawTemplate, fragments, used, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	names := make([]string, 0, len(used))
+	for name := range used {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return resolved, names, nil
+}
+
+func resolveIncludes(rawTemplate string, fragments map[string]string, used map[string]bool, stack []string) (string, error) {
+	matches := templateIncludeRegex.FindAllStringSubmatchIndex(rawTemplate, -1)
+	if len(matches) == 0 {
+		return rawTemplate, nil
+	}
+	if len(stack) >= maxLDIFTemplateDepth {
+		return "", fmt.Errorf("ldif templates are nested more than %d levels deep: %s", maxLDIFTemplateDepth, strings.Join(stack, " -> "))
+	}
+
+	var sb strings.Builder
+	last := 0
+	trimNext := false
+	for _, m := range matches {
+		text := rawTemplate[last:m[0]]
+		if trimNext {
+			text = strings.TrimLeft(text, " \t\r\n")
+		}
+		if m[2] >= 0 {
+			text = strings.TrimRight(text, " \t\r\n")
+		}
+		sb.WriteString(text)
+
+		name := rawTemplate[m[4]:m[5]]
+		if arg := rawTemplate[m[6]:m[7]]; arg != "." {
+			return "", fmt.Errorf("ldif template %q must be included with {{template %q .}}", name, name)
+		}
+		fragment, ok := fragments[name]
+		if !ok {
+			return "", fmt.Errorf("unknown ldif template %q", name)
+		}
+		for _, parent := range stack {
+			if parent == name {
+				return "", fmt.Errorf("ldif template %q includes itself: %s -> %s", name, strings.Join(stack, " -> "), name)
+			}
+		}
+		resolved, err := resolveIncludes(fragment, fragments, used, append(stack, name))
+		if err != nil {
+			return "", err
+		}
+		used[name] = true
+		sb.WriteString(resolved)
+
+		last = m[1]
+		trimNext = m[8] >= 0
+	}
+	text := rawTemplate[last:]
+	if trimNext {
+		text = strings.TrimLeft(text, " \t\r\n")
+	}
+	sb.WriteString(text)
+	return sb.String(), nil
+}
+
+// ldifFields returns the LDIF fields of the role that can include shared
+// fragments, by field name.
+func (r *dynamicRole) ldifFields() map[string]*string {
+	return map[string]*string{
+		"creation_ldif": &r.CreationLDIF,
+		"deletion_ldif": &r.DeletionLDIF,
+		"rollback_ldif": &r.RollbackLDIF,
+		"renewal_ldif":  &r.RenewalLDIF,
+		"rotation_ldif": &r.RotationLDIF,
+	}
+}
+
+// resolveLDIF resolves the shared fragments included by the LDIF fields of the
+// role. The LDIF as written is kept in LDIFSources for fields that include
+// fragments, so that they can be resolved again when a fragment changes.
+// Fields that don't include fragments are used as written.
+func (r *dynamicRole) resolveLDIF(fragments map[string]string) error {
+	for field, value := range r.ldifFields() {
+		raw := *value
+		if source, ok := r.LDIFSources[field]; ok {
+			raw = source
+		}
+		resolved, used, err := resolveLDIFTemplates(raw, fragments)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", field, err)
+		}
+		*value = resolved
+		if len(used) == 0 {
+			delete(r.LDIFSources, field)
+			continue
+		}
+		if r.LDIFSources == nil {
+			r.LDIFSources = make(map[string]string)
+		}
+		r.LDIFSources[field] = raw
+	}
+	if len(r.LDIFSources) == 0 {
+		r.LDIFSources = nil
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveLDIFTemplates(t *testing.T) {
+	fragments := map[string]string{
+		"person": "objectClass: person\nobjectClass: top",
+		"groups": `memberOf: cn=dev,ou=groups,dc=hashicorp,dc=com
+{{template "audit" .}}`,
+		"audit": "description: issued by {{.RoleName}}",
+		"loop":  `{{template "cycle" .}}`,
+		"cycle": `{{template "loop" .}}`,
+	}
+
+	testCases := map[string]struct {
+		template     string
+		expected     string
+		expectedUsed []string
+		expectErr    bool
+	}{
+		"no includes": {
+			template: "dn: cn={{.Username}},ou=users,dc=hashicorp,dc=com",
+			expected: "dn: cn={{.Username}},ou=users,dc=hashicorp,dc=com",
+		},
+		"nested includes": {
+			template: `dn: cn={{.Username}},ou=users,dc=hashicorp,dc=com
+{{template "person" .}}
+{{template "groups" .}}`,
+			expected: `dn: cn={{.Username}},ou=users,dc=hashicorp,dc=com
+objectClass: person
+objectClass: top
+memberOf: cn=dev,ou=groups,dc=hashicorp,dc=com
+description: issued by {{.RoleName}}`,
+			expectedUsed: []string{"audit", "groups", "person"},
+		},
+		"trim markers": {
+			template:     "cn: test\n\n{{- template \"audit\" . -}}\n\nsn: test",
+			expected:     "cn: testdescription: issued by {{.RoleName}}sn: test",
+			expectedUsed: []string{"audit"},
+		},
+		"unknown fragment": {
+			template:  `{{template "missing" .}}`,
+			expectErr: true,
+		},
+		"other data": {
+			template:  `{{template "person" .Params}}`,
+			expectErr: true,
+		},
+		"cycle": {
+			template:  `{{template "loop" .}}`,
+			expectErr: true,
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			resolved, used, err := resolveLDIFTemplates(test.template, fragments)
+			if test.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, resolved)
+			require.ElementsMatch(t, test.expectedUsed, used)
+		})
+	}
+}
+
+func TestDynamicRole_resolveLDIF(t *testing.T) {
+	fragments := map[string]string{"person": "objectClass: person"}
+	dRole := &dynamicRole{
+		CreationLDIF: "dn: cn={{.Username}},dc=hashicorp,dc=com\n{{template \"person\" .}}",
+		DeletionLDIF: "dn: cn={{.Username}},dc=hashicorp,dc=com\nchangetype: delete",
+	}
+	require.NoError(t, dRole.resolveLDIF(fragments))
+	require.Equal(t, "dn: cn={{.Username}},dc=hashicorp,dc=com\nobjectClass: person", dRole.CreationLDIF)
+	require.Equal(t, map[string]string{"creation_ldif": "dn: cn={{.Username}},dc=hashicorp,dc=com\n{{template \"person\" .}}"}, dRole.LDIFSources)
+
+	// The sources are resolved again with changed fragments.
+	fragments["person"] = "objectClass: inetOrgPerson"
+	require.NoError(t, dRole.resolveLDIF(fragments))
+	require.Equal(t, "dn: cn={{.Username}},dc=hashicorp,dc=com\nobjectClass: inetOrgPerson", dRole.CreationLDIF)
+
+	delete(fragments, "person")
+	require.Error(t, dRole.resolveLDIF(fragments))
+}
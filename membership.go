@@ -0,0 +1,410 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/go-secure-stdlib/strutil"
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault-plugin-secrets-openldap/client"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/mitchellh/mapstructure"
+)
+
+// membershipRecordsPath is the storage prefix of the membership records of
+// membership roles, keyed by a hash of the group DN and member value.
+const membershipRecordsPath = "membership-records/"
+
+// membershipGrant records the group memberships a membership role lease
+// depends on. Leases written before grants had an ID only list the groups the
+// user was added to, and those are removed on revocation.
+type membershipGrant struct {
+	// ID identifies the grant in the membership records of its groups.
+	ID              string   `json:"id,omitempty" mapstructure:"id"`
+	UserDN          string   `json:"user_dn" mapstructure:"user_dn"`
+	MemberAttribute string   `json:"member_attribute" mapstructure:"member_attribute"`
+	MemberValue     string   `json:"member_value" mapstructure:"member_value"`
+	GroupDNs        []string `json:"group_dns" mapstructure:"group_dns"`
+}
+
+// membershipRecord tracks the grants that depend on the membership of a user
+// in a group, so that the membership is only removed once the last of them
+// is revoked. Records are deleted along with their last grant.
+type membershipRecord struct {
+	GroupDN     string   `json:"group_dn"`
+	MemberValue string   `json:"member_value"`
+	GrantIDs    []string `json:"grant_ids"`
+
+	// PreExisting is set if the user was a member before it was granted by
+	// the plugin, in which case the membership is never removed.
+	PreExisting bool `json:"pre_existing,omitempty"`
+}
+
+func membershipRecordPath(groupDN, memberValue string) string {
+	sum := sha256.Sum256([]byte(normalizeDN(groupDN) + "\x00" + memberValue))
+	return membershipRecordsPath + hex.EncodeToString(sum[:])
+}
+
+func retrieveMembershipRecord(ctx context.Context, s logical.Storage, groupDN, memberValue string) (*membershipRecord, error) {
+	entry, err := s.Get(ctx, membershipRecordPath(groupDN, memberValue))
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+	record := new(membershipRecord)
+	if err := entry.DecodeJSON(record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func storeMembershipRecord(ctx context.Context, s logical.Storage, record *membershipRecord) error {
+	entry, err := logical.StorageEntryJSON(membershipRecordPath(record.GroupDN, record.MemberValue), record)
+	if err != nil {
+		return fmt.Errorf("unable to marshal storage entry: %w", err)
+	}
+	if err := s.Put(ctx, entry); err != nil {
+		return fmt.Errorf("failed to store membership record: %w", err)
+	}
+	return nil
+}
+
+// membershipLocks returns the locks of the membership records of a grant.
+func (b *backend) membershipLocks(grant membershipGrant) []*locksutil.LockEntry {
+	keys := make([]string, 0, len(grant.GroupDNs))
+	for _, groupDN := range grant.GroupDNs {
+		keys = append(keys, membershipRecordPath(groupDN, grant.MemberValue))
+	}
+	return locksutil.LocksForKeys(b.membershipRecordLocks, keys)
+}
+
+// renderUserDN renders the user_dn_template of a membership role.
+func renderUserDN(dRole *dynamicRole, templateData dynamicTemplateData) (string, error) {
+	userDN, err := applyTemplate(dRole.UserDNTemplate, templateData)
+	if err != nil {
+		return "", err
+	}
+	if _, err := ldap.ParseDN(userDN); err != nil {
+		return "", fmt.Errorf("rendered DN %q is invalid: %w", userDN, err)
+	}
+	return userDN, nil
+}
+
+// pathMembershipCredsRead adds an existing user to the groups of a membership
+// role for the duration of the lease.
+func (b *backend) pathMembershipCredsRead(ctx context.Context, req *logical.Request, data *framework.FieldData, dRole *dynamicRole, config *config) (*logical.Response, error) {
+	entity, err := b.requestEntity(req)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	exp := now.Add(dRole.DefaultTTL)
+	templateData := dynamicTemplateData{
+		DisplayName:           req.DisplayName,
+		RoleName:              dRole.Name,
+		IssueTime:             now.Format(time.RFC3339),
+		IssueTimeSeconds:      now.Unix(),
+		ExpirationTime:        exp.Format(time.RFC3339),
+		ExpirationTimeSeconds: exp.Unix(),
+		Entity:                entity,
+	}
+
+	var userDN string
+	if raw, ok := data.GetOk("user_dn"); ok {
+		userDN = raw.(string)
+	}
+	switch {
+	case dRole.UserDNTemplate != "" && userDN != "":
+		return logical.ErrorResponse("user_dn cannot be supplied for roles with a user_dn_template"), nil
+	case dRole.UserDNTemplate != "":
+		userDN, err = renderUserDN(dRole, templateData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render user_dn_template: %w", err)
+		}
+	case userDN == "":
+		return logical.ErrorResponse("missing user_dn"), nil
+	default:
+		if _, err := ldap.ParseDN(userDN); err != nil {
+			return logical.ErrorResponse("invalid user_dn: %s", err), nil
+		}
+		if !allowedUserDN(dRole, userDN) {
+			return logical.ErrorResponse("user_dn %q is not allowed by the role", userDN), nil
+		}
+	}
+
+	user, err := b.client.SearchDN(config.LDAP, userDN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user %q: %w", userDN, err)
+	}
+	grant := membershipGrant{
+		UserDN:          user.DN,
+		MemberAttribute: dRole.MemberAttribute,
+		MemberValue:     user.DN,
+	}
+	if dRole.MemberAttribute == client.MemberAttributeMemberUID {
+		grant.MemberValue = user.GetAttributeValue("uid")
+		if grant.MemberValue == "" {
+			return nil, fmt.Errorf("user %q has no uid for memberUid", user.DN)
+		}
+	}
+
+	grant.ID, err = uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	grant.GroupDNs = dRole.GroupDNs
+	for _, lock := range b.membershipLocks(grant) {
+		lock.Lock()
+		defer lock.Unlock()
+	}
+
+	// The WAL releases the grant from each group, which removes the
+	// memberships no other lease depends on.
+	walID, err := framework.PutWAL(ctx, req.Storage, membershipWALKey, &membershipWAL{
+		RoleName: dRole.Name,
+		Grant:    grant,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error writing WAL entry: %w", err)
+	}
+
+	var added []string
+	for _, groupDN := range grant.GroupDNs {
+		isAdded, err := b.grantMembership(ctx, req.Storage, config.LDAP, grant, groupDN)
+		if err != nil {
+			b.ldapEvent(ctx, "creds-create-fail", req.Path, dRole.Name, false)
+			merr := multierror.Append(fmt.Errorf("failed to add user to groups: %w", err))
+			if err := b.releaseGroupMemberships(ctx, req.Storage, config.LDAP, grant); err != nil {
+				// Leave the WAL in place so that the release is retried.
+				merr = multierror.Append(merr, fmt.Errorf("failed to roll back group memberships: %w", err))
+			} else {
+				b.deleteDynamicCredsWAL(ctx, req.Storage, walID)
+			}
+			return nil, merr
+		}
+		if isAdded {
+			added = append(added, groupDN)
+		}
+	}
+
+	respData := map[string]interface{}{
+		"user_dn":             grant.UserDN,
+		"group_dns":           dRole.GroupDNs,
+		"distinguished_names": added,
+	}
+	internal := map[string]interface{}{
+		"name":          dRole.Name,
+		"type":          dynamicRoleTypeMembership,
+		"membership":    grant,
+		"template_data": templateData,
+	}
+	resp := b.Secret(secretCredsType).Response(respData, internal)
+	resp.Secret.TTL = dRole.DefaultTTL
+	resp.Secret.MaxTTL = dRole.MaxTTL
+
+	if err := b.commitDynamicCredsWAL(ctx, req.Storage, walID); err != nil {
+		return nil, err
+	}
+
+	b.ldapEvent(ctx, "creds-create", req.Path, dRole.Name, true)
+
+	return resp, nil
+}
+
+// allowedUserDN returns whether a caller-supplied user_dn matches one of the
+// allowed_user_dns of the role. Roles without any allow no user_dn.
+func allowedUserDN(dRole *dynamicRole, userDN string) bool {
+	dn, err := ldap.ParseDN(userDN)
+	if err != nil {
+		return false
+	}
+	for _, pattern := range dRole.AllowedUserDNs {
+		if matchDNPattern(pattern, dn) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateDNPattern returns an error if the pattern is not a DN whose
+// attribute values are valid glob patterns.
+func validateDNPattern(pattern string) error {
+	parsed, err := ldap.ParseDN(pattern)
+	if err != nil {
+		return err
+	}
+	for _, rdn := range parsed.RDNs {
+		for _, attribute := range rdn.Attributes {
+			if _, err := path.Match(attribute.Value, ""); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// matchDNPattern returns whether the DN matches a DN pattern RDN by RDN.
+// Attribute types must be equal, and values match glob patterns of the same
+// RDN, so wildcards never match more or fewer RDNs. Matching is
+// case-insensitive.
+func matchDNPattern(pattern string, dn *ldap.DN) bool {
+	parsed, err := ldap.ParseDN(pattern)
+	if err != nil || len(parsed.RDNs) != len(dn.RDNs) {
+		return false
+	}
+	for i, rdn := range parsed.RDNs {
+		attributes := dn.RDNs[i].Attributes
+		if len(rdn.Attributes) != len(attributes) {
+			return false
+		}
+		for j, attribute := range rdn.Attributes {
+			if !strings.EqualFold(attribute.Type, attributes[j].Type) {
+				return false
+			}
+			if matched, _ := path.Match(strings.ToLower(attribute.Value), strings.ToLower(attributes[j].Value)); !matched {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// grantMembership records the grant in the membership record of the group
+// and adds the user to the group, unless the user already is a member. It
+// returns whether the user was added. The lock of the record must be held.
+func (b *backend) grantMembership(ctx context.Context, s logical.Storage, conf *client.Config, grant membershipGrant, groupDN string) (bool, error) {
+	record, err := retrieveMembershipRecord(ctx, s, groupDN, grant.MemberValue)
+	if err != nil {
+		return false, fmt.Errorf("failed to read membership record: %w", err)
+	}
+	isMember, err := b.client.IsGroupMember(conf, groupDN, grant.MemberAttribute, grant.MemberValue)
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case record == nil:
+		record = &membershipRecord{
+			GroupDN:     groupDN,
+			MemberValue: grant.MemberValue,
+			PreExisting: isMember,
+		}
+	case !isMember:
+		// The membership was removed outside of the plugin, and is added
+		// again for the grants that depend on it.
+		record.PreExisting = false
+	}
+
+	// The grant is recorded before the user is added, so that a release
+	// by walRollback finds it.
+	record.GrantIDs = append(record.GrantIDs, grant.ID)
+	if err := storeMembershipRecord(ctx, s, record); err != nil {
+		return false, err
+	}
+	if isMember {
+		return false, nil
+	}
+
+	added, err := b.client.AddGroupMember(conf, groupDN, grant.MemberAttribute, grant.MemberValue)
+	if err != nil {
+		return false, err
+	}
+	if !added && len(record.GrantIDs) == 1 {
+		// The user was added by someone else since the check.
+		record.PreExisting = true
+		if err := storeMembershipRecord(ctx, s, record); err != nil {
+			return false, err
+		}
+	}
+	return added, nil
+}
+
+// releaseMemberships releases the grant of a membership role lease from each
+// of its groups.
+func (b *backend) releaseMemberships(ctx context.Context, s logical.Storage, conf *client.Config, grant membershipGrant) error {
+	for _, lock := range b.membershipLocks(grant) {
+		lock.Lock()
+		defer lock.Unlock()
+	}
+	return b.releaseGroupMemberships(ctx, s, conf, grant)
+}
+
+// releaseGroupMemberships removes the grant from the membership record of
+// each of its groups, and removes the user from the groups whose last grant
+// it was, unless the membership existed before. The locks of the records
+// must be held.
+func (b *backend) releaseGroupMemberships(ctx context.Context, s logical.Storage, conf *client.Config, grant membershipGrant) error {
+	merr := new(multierror.Error)
+	for _, groupDN := range grant.GroupDNs {
+		record, err := retrieveMembershipRecord(ctx, s, groupDN, grant.MemberValue)
+		if err != nil {
+			merr = multierror.Append(merr, fmt.Errorf("failed to read membership record: %w", err))
+			continue
+		}
+		if grant.ID == "" {
+			// Grants without an ID only list the groups they added the user
+			// to, which are kept while newer grants depend on them.
+			if record == nil {
+				if err := b.client.RemoveGroupMember(conf, groupDN, grant.MemberAttribute, grant.MemberValue); err != nil {
+					merr = multierror.Append(merr, err)
+				}
+			}
+			continue
+		}
+		if record == nil || !strutil.StrListContains(record.GrantIDs, grant.ID) {
+			continue
+		}
+
+		remaining := strutil.StrListDelete(record.GrantIDs, grant.ID)
+		if len(remaining) > 0 {
+			record.GrantIDs = remaining
+			if err := storeMembershipRecord(ctx, s, record); err != nil {
+				merr = multierror.Append(merr, err)
+			}
+			continue
+		}
+		if !record.PreExisting {
+			if err := b.client.RemoveGroupMember(conf, groupDN, grant.MemberAttribute, grant.MemberValue); err != nil {
+				merr = multierror.Append(merr, err)
+				continue
+			}
+		}
+		if err := s.Delete(ctx, membershipRecordPath(groupDN, grant.MemberValue)); err != nil {
+			merr = multierror.Append(merr, fmt.Errorf("failed to delete membership record: %w", err))
+		}
+	}
+	return merr.ErrorOrNil()
+}
+
+// revokeMemberships releases the group memberships of a membership role
+// lease.
+func (b *backend) revokeMemberships(ctx context.Context, req *logical.Request) error {
+	config, err := readConfig(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		return errors.New("missing LDAP configuration")
+	}
+
+	var grant membershipGrant
+	if err := mapstructure.WeakDecode(req.Secret.InternalData["membership"], &grant); err != nil {
+		return fmt.Errorf("broken internal data: unable to decode membership: %w", err)
+	}
+	return b.releaseMemberships(ctx, req.Storage, config.LDAP, grant)
+}
@@ -0,0 +1,523 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/hashicorp/vault-plugin-secrets-openldap/client"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testGroupAdmins = "cn=prod-admins,ou=groups,dc=example,dc=com"
+	testGroupOps    = "cn=ops,ou=groups,dc=example,dc=com"
+	testUserDN      = "uid=alice,ou=people,dc=example,dc=com"
+	testUserDNGlob  = "uid=*,ou=people,dc=example,dc=com"
+)
+
+// groupDirectoryClient tracks the members of each group.
+type groupDirectoryClient struct {
+	fakeLdapClient
+	users   map[string]*ldap.Entry
+	members map[string]map[string]bool
+
+	// addErrs makes adding a member to the given groups fail.
+	addErrs map[string]error
+	// racedGroups are reported as not having any members, as if the user
+	// was added by someone else between the check and the add.
+	racedGroups map[string]bool
+	// onAdd is called after each successful add.
+	onAdd func()
+}
+
+func newGroupDirectoryClient() *groupDirectoryClient {
+	return &groupDirectoryClient{
+		users: map[string]*ldap.Entry{
+			testUserDN: ldap.NewEntry(testUserDN, map[string][]string{"uid": {"alice"}}),
+		},
+		members: map[string]map[string]bool{
+			testGroupAdmins: {},
+			testGroupOps:    {},
+		},
+		addErrs:     map[string]error{},
+		racedGroups: map[string]bool{},
+		onAdd:       func() {},
+	}
+}
+
+func (g *groupDirectoryClient) SearchDN(_ *client.Config, dn string) (*client.Entry, error) {
+	user, ok := g.users[dn]
+	if !ok {
+		return nil, fmt.Errorf("expected one matching entry, but received 0")
+	}
+	return client.NewEntry(user), nil
+}
+
+func (g *groupDirectoryClient) IsGroupMember(_ *client.Config, groupDN, _, value string) (bool, error) {
+	return g.members[groupDN][value] && !g.racedGroups[groupDN], nil
+}
+
+func (g *groupDirectoryClient) AddGroupMember(_ *client.Config, groupDN, _, value string) (bool, error) {
+	if err := g.addErrs[groupDN]; err != nil {
+		return false, err
+	}
+	if g.members[groupDN][value] {
+		return false, nil
+	}
+	g.members[groupDN][value] = true
+	g.onAdd()
+	return true, nil
+}
+
+func (g *groupDirectoryClient) RemoveGroupMember(_ *client.Config, groupDN, _, value string) error {
+	delete(g.members[groupDN], value)
+	return nil
+}
+
+func createMembershipRole(t *testing.T, b *backend, s logical.Storage, data map[string]interface{}) {
+	t.Helper()
+	roleData := map[string]interface{}{
+		"type":        dynamicRoleTypeMembership,
+		"group_dns":   []string{testGroupAdmins, testGroupOps},
+		"default_ttl": "1h",
+	}
+	if _, ok := data["user_dn_template"]; !ok {
+		roleData["allowed_user_dns"] = []string{testUserDNGlob}
+	}
+	for k, v := range data {
+		roleData[k] = v
+	}
+	resp, err := createDynamicRoleWithData(t, b, s, "jit", roleData)
+	require.NoError(t, err)
+	require.False(t, resp != nil && resp.IsError(), "unexpected error response: %v", resp)
+}
+
+func TestMembershipRole_validation(t *testing.T) {
+	testCases := map[string]map[string]interface{}{
+		"missing group_dns": {
+			"type": dynamicRoleTypeMembership,
+		},
+		"invalid group DN": {
+			"type":      dynamicRoleTypeMembership,
+			"group_dns": []string{"not a dn"},
+		},
+		"invalid member_attribute": {
+			"type":             dynamicRoleTypeMembership,
+			"group_dns":        []string{testGroupAdmins},
+			"member_attribute": "memberOf",
+		},
+		"creation_ldif": {
+			"type":          dynamicRoleTypeMembership,
+			"group_dns":     []string{testGroupAdmins},
+			"creation_ldif": ldifCreationTemplate,
+		},
+		"invalid user_dn_template": {
+			"type":             dynamicRoleTypeMembership,
+			"group_dns":        []string{testGroupAdmins},
+			"user_dn_template": "{{.RoleName}}",
+		},
+		"username_collision_retries": {
+			"type":                       dynamicRoleTypeMembership,
+			"group_dns":                  []string{testGroupAdmins},
+			"username_collision_retries": 2,
+		},
+		"max_active_per_entity": {
+			"type":                  dynamicRoleTypeMembership,
+			"group_dns":             []string{testGroupAdmins},
+			"max_active_per_entity": 2,
+		},
+		"verify_credentials": {
+			"type":               dynamicRoleTypeMembership,
+			"group_dns":          []string{testGroupAdmins},
+			"verify_credentials": true,
+		},
+		"credential_type": {
+			"type":            dynamicRoleTypeMembership,
+			"group_dns":       []string{testGroupAdmins},
+			"credential_type": credentialTypeSSHKey,
+		},
+		"response_template": {
+			"type":              dynamicRoleTypeMembership,
+			"group_dns":         []string{testGroupAdmins},
+			"response_template": map[string]interface{}{"upn": "{{.Username}}@example.com"},
+		},
+		"invalid allowed_user_dns": {
+			"type":             dynamicRoleTypeMembership,
+			"group_dns":        []string{testGroupAdmins},
+			"allowed_user_dns": []string{"["},
+		},
+		"allowed_user_dns with user_dn_template": {
+			"type":             dynamicRoleTypeMembership,
+			"group_dns":        []string{testGroupAdmins},
+			"user_dn_template": testUserDN,
+			"allowed_user_dns": []string{testUserDNGlob},
+		},
+		"allowed_user_dns on ldif role": {
+			"creation_ldif":    ldifCreationTemplate,
+			"deletion_ldif":    ldifDeleteTemplate,
+			"allowed_user_dns": []string{testUserDNGlob},
+		},
+		"group_dns on ldif role": {
+			"creation_ldif": ldifCreationTemplate,
+			"deletion_ldif": ldifDeleteTemplate,
+			"group_dns":     []string{testGroupAdmins},
+		},
+		"unknown type": {
+			"type":      "nested",
+			"group_dns": []string{testGroupAdmins},
+		},
+	}
+
+	for name, data := range testCases {
+		t.Run(name, func(t *testing.T) {
+			b, storage := getBackend(false)
+			defer b.Cleanup(context.Background())
+
+			_, err := createDynamicRoleWithData(t, b, storage, "jit", data)
+			require.Error(t, err)
+		})
+	}
+
+	t.Run("read", func(t *testing.T) {
+		b, storage := getBackend(false)
+		defer b.Cleanup(context.Background())
+		createMembershipRole(t, b, storage, map[string]interface{}{
+			"user_dn_template": `uid={{entity_meta "uid" | dn_escape}},ou=people,dc=example,dc=com`,
+		})
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      dynamicRolePath + "jit",
+			Storage:   storage,
+		})
+		require.NoError(t, err)
+		require.Equal(t, dynamicRoleTypeMembership, resp.Data["type"])
+		require.Equal(t, []string{testGroupAdmins, testGroupOps}, resp.Data["group_dns"])
+		require.Equal(t, client.MemberAttributeMember, resp.Data["member_attribute"])
+		require.Equal(t, `uid={{entity_meta "uid" | dn_escape}},ou=people,dc=example,dc=com`, resp.Data["user_dn_template"])
+		require.Empty(t, resp.Data["allowed_user_dns"])
+	})
+}
+
+func TestMembershipCreds(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("existing memberships are kept on revoke", func(t *testing.T) {
+		b, storage := getBackend(false)
+		defer b.Cleanup(ctx)
+		configureOpenLDAPMount(t, b, storage)
+		createMembershipRole(t, b, storage, nil)
+
+		directory := newGroupDirectoryClient()
+		directory.members[testGroupOps][testUserDN] = true
+		b.client = directory
+
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      dynamicCredPath + "jit",
+			Storage:   storage,
+			Data:      map[string]interface{}{"user_dn": testUserDN},
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError(), "unexpected error response: %v", resp)
+		require.Equal(t, testUserDN, resp.Data["user_dn"])
+		require.Equal(t, []string{testGroupAdmins, testGroupOps}, resp.Data["group_dns"])
+		require.Equal(t, []string{testGroupAdmins}, resp.Data["distinguished_names"])
+		require.True(t, directory.members[testGroupAdmins][testUserDN])
+
+		walIDs, err := framework.ListWAL(ctx, storage)
+		require.NoError(t, err)
+		require.Empty(t, walIDs)
+
+		// Revoke with internal data as it is read back from storage.
+		secret := resp.Secret
+		raw, err := json.Marshal(secret.InternalData)
+		require.NoError(t, err)
+		secret.InternalData = nil
+		require.NoError(t, json.Unmarshal(raw, &secret.InternalData))
+
+		_, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.RevokeOperation,
+			Storage:   storage,
+			Secret:    secret,
+		})
+		require.NoError(t, err)
+		require.False(t, directory.members[testGroupAdmins][testUserDN])
+		require.True(t, directory.members[testGroupOps][testUserDN])
+	})
+
+	t.Run("memberUid uses the uid", func(t *testing.T) {
+		b, storage := getBackend(false)
+		defer b.Cleanup(ctx)
+		configureOpenLDAPMount(t, b, storage)
+		createMembershipRole(t, b, storage, map[string]interface{}{
+			"member_attribute": client.MemberAttributeMemberUID,
+		})
+
+		directory := newGroupDirectoryClient()
+		b.client = directory
+
+		_, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      dynamicCredPath + "jit",
+			Storage:   storage,
+			Data:      map[string]interface{}{"user_dn": testUserDN},
+		})
+		require.NoError(t, err)
+		require.Equal(t, map[string]bool{"alice": true}, directory.members[testGroupAdmins])
+	})
+
+	t.Run("user_dn is required", func(t *testing.T) {
+		b, storage := getBackend(false)
+		defer b.Cleanup(ctx)
+		configureOpenLDAPMount(t, b, storage)
+		createMembershipRole(t, b, storage, nil)
+		b.client = newGroupDirectoryClient()
+
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      dynamicCredPath + "jit",
+			Storage:   storage,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      dynamicCredPath + "jit",
+			Storage:   storage,
+			Data:      map[string]interface{}{"user_dn": "uid=bob,ou=people,dc=example,dc=com"},
+		})
+		require.Error(t, err)
+		require.Nil(t, resp)
+	})
+
+	t.Run("user_dn must be allowed by the role", func(t *testing.T) {
+		b, storage := getBackend(false)
+		defer b.Cleanup(ctx)
+		configureOpenLDAPMount(t, b, storage)
+		createMembershipRole(t, b, storage, map[string]interface{}{
+			"allowed_user_dns": []string{"uid=*,ou=contractors,dc=example,dc=com"},
+		})
+		directory := newGroupDirectoryClient()
+		b.client = directory
+
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      dynamicCredPath + "jit",
+			Storage:   storage,
+			Data:      map[string]interface{}{"user_dn": testUserDN},
+		})
+		require.NoError(t, err)
+		require.ErrorContains(t, resp.Error(), "is not allowed by the role")
+		require.False(t, directory.members[testGroupAdmins][testUserDN])
+
+		// Wildcards only match within an RDN value.
+		resp, err = createDynamicRoleWithData(t, b, storage, "jit", map[string]interface{}{"allowed_user_dns": []string{testUserDNGlob}})
+		require.NoError(t, err)
+		require.False(t, resp != nil && resp.IsError(), "unexpected error response: %v", resp)
+		for _, userDN := range []string{
+			"uid=x,ou=admins,ou=people,dc=example,dc=com",
+			"uid=x,ou=people,dc=example,dc=com,dc=org",
+			"cn=x,ou=people,dc=example,dc=com",
+		} {
+			resp, err = b.HandleRequest(ctx, &logical.Request{
+				Operation: logical.ReadOperation,
+				Path:      dynamicCredPath + "jit",
+				Storage:   storage,
+				Data:      map[string]interface{}{"user_dn": userDN},
+			})
+			require.NoError(t, err)
+			require.ErrorContains(t, resp.Error(), "is not allowed by the role", userDN)
+		}
+		require.True(t, allowedUserDN(&dynamicRole{AllowedUserDNs: []string{testUserDNGlob}}, `UID=a\,b,OU=People,dc=example,dc=com`))
+
+		// Roles without allowed_user_dns allow no user_dn.
+		resp, err = createDynamicRoleWithData(t, b, storage, "jit", map[string]interface{}{"allowed_user_dns": []string{}})
+		require.NoError(t, err)
+		require.False(t, resp != nil && resp.IsError(), "unexpected error response: %v", resp)
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      dynamicCredPath + "jit",
+			Storage:   storage,
+			Data:      map[string]interface{}{"user_dn": testUserDN},
+		})
+		require.NoError(t, err)
+		require.ErrorContains(t, resp.Error(), "is not allowed by the role")
+	})
+
+	t.Run("overlapping leases", func(t *testing.T) {
+		b, storage := getBackend(false)
+		defer b.Cleanup(ctx)
+		configureOpenLDAPMount(t, b, storage)
+		createMembershipRole(t, b, storage, nil)
+
+		// The user is a member of ops before any lease.
+		directory := newGroupDirectoryClient()
+		directory.members[testGroupOps][testUserDN] = true
+		b.client = directory
+
+		issue := func() *logical.Secret {
+			resp, err := b.HandleRequest(ctx, &logical.Request{
+				Operation: logical.ReadOperation,
+				Path:      dynamicCredPath + "jit",
+				Storage:   storage,
+				Data:      map[string]interface{}{"user_dn": testUserDN},
+			})
+			require.NoError(t, err)
+			require.False(t, resp.IsError(), "unexpected error response: %v", resp)
+			return resp.Secret
+		}
+		revoke := func(secret *logical.Secret) {
+			_, err := b.HandleRequest(ctx, &logical.Request{
+				Operation: logical.RevokeOperation,
+				Storage:   storage,
+				Secret:    secret,
+			})
+			require.NoError(t, err)
+		}
+
+		first := issue()
+		second := issue()
+
+		revoke(first)
+		require.True(t, directory.members[testGroupAdmins][testUserDN])
+		require.True(t, directory.members[testGroupOps][testUserDN])
+
+		revoke(second)
+		require.False(t, directory.members[testGroupAdmins][testUserDN])
+		require.True(t, directory.members[testGroupOps][testUserDN])
+
+		records, err := storage.List(ctx, membershipRecordsPath)
+		require.NoError(t, err)
+		require.Empty(t, records)
+	})
+
+	t.Run("rollback keeps memberships the plugin didn't add", func(t *testing.T) {
+		b, storage := getBackend(false)
+		defer b.Cleanup(ctx)
+		configureOpenLDAPMount(t, b, storage)
+		createMembershipRole(t, b, storage, map[string]interface{}{
+			"group_dns": []string{testGroupOps, testGroupAdmins},
+		})
+
+		// The user is added to ops by someone else after the membership
+		// check, and the request ends before its response is complete.
+		directory := newGroupDirectoryClient()
+		directory.members[testGroupOps][testUserDN] = true
+		directory.racedGroups[testGroupOps] = true
+		reqCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		directory.onAdd = cancel
+		b.client = directory
+
+		_, err := b.HandleRequest(reqCtx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      dynamicCredPath + "jit",
+			Storage:   storage,
+			Data:      map[string]interface{}{"user_dn": testUserDN},
+		})
+		require.ErrorContains(t, err, "removed by WAL rollback")
+		require.True(t, directory.members[testGroupAdmins][testUserDN])
+
+		walIDs, err := framework.ListWAL(ctx, storage)
+		require.NoError(t, err)
+		require.Len(t, walIDs, 1)
+
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.RollbackOperation,
+			Storage:   storage,
+			Data:      map[string]interface{}{"immediate": true},
+		})
+		require.NoError(t, err)
+		require.False(t, resp != nil && resp.IsError(), "unexpected error response: %v", resp)
+		require.False(t, directory.members[testGroupAdmins][testUserDN])
+		require.True(t, directory.members[testGroupOps][testUserDN])
+	})
+
+	t.Run("failed add is rolled back", func(t *testing.T) {
+		b, storage := getBackend(false)
+		defer b.Cleanup(ctx)
+		configureOpenLDAPMount(t, b, storage)
+		createMembershipRole(t, b, storage, nil)
+
+		directory := newGroupDirectoryClient()
+		directory.addErrs[testGroupOps] = errors.New("insufficient access")
+		b.client = directory
+
+		_, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      dynamicCredPath + "jit",
+			Storage:   storage,
+			Data:      map[string]interface{}{"user_dn": testUserDN},
+		})
+		require.Error(t, err)
+		require.False(t, directory.members[testGroupAdmins][testUserDN])
+
+		walIDs, err := framework.ListWAL(ctx, storage)
+		require.NoError(t, err)
+		require.Empty(t, walIDs)
+	})
+
+	t.Run("WAL rollback removes memberships", func(t *testing.T) {
+		b, storage := getBackend(false)
+		defer b.Cleanup(ctx)
+		configureOpenLDAPMount(t, b, storage)
+
+		directory := newGroupDirectoryClient()
+		directory.members[testGroupAdmins][testUserDN] = true
+		b.client = directory
+
+		_, err := framework.PutWAL(ctx, storage, membershipWALKey, &membershipWAL{
+			RoleName: "jit",
+			Grant: membershipGrant{
+				UserDN:          testUserDN,
+				MemberAttribute: client.MemberAttributeMember,
+				MemberValue:     testUserDN,
+				GroupDNs:        []string{testGroupAdmins},
+			},
+		})
+		require.NoError(t, err)
+
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.RollbackOperation,
+			Storage:   storage,
+			Data:      map[string]interface{}{"immediate": true},
+		})
+		require.NoError(t, err)
+		require.False(t, resp != nil && resp.IsError(), "unexpected error response: %v", resp)
+		require.False(t, directory.members[testGroupAdmins][testUserDN])
+
+		walIDs, err := framework.ListWAL(ctx, storage)
+		require.NoError(t, err)
+		require.Empty(t, walIDs)
+	})
+
+	t.Run("user_dn is rejected by LDIF roles", func(t *testing.T) {
+		b, storage := getBackend(false)
+		defer b.Cleanup(ctx)
+		configureOpenLDAPMount(t, b, storage)
+
+		resp, err := createDynamicRoleWithData(t, b, storage, "hashicorp", getTestDynamicRoleConfig("hashicorp"))
+		require.NoError(t, err)
+		require.False(t, resp != nil && resp.IsError())
+
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      dynamicCredPath + "hashicorp",
+			Storage:   storage,
+			Data:      map[string]interface{}{"user_dn": testUserDN},
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+}
@@ -5,8 +5,8 @@ package openldap
 
 import (
 	"context"
+	"time"
 
-	"github.com/go-ldap/ldif"
 	"github.com/hashicorp/vault-plugin-secrets-openldap/client"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/stretchr/testify/mock"
@@ -28,11 +28,57 @@ func (m *mockLDAPClient) UpdateUserPassword(conf *client.Config, user string, ne
 	return args.Error(0)
 }
 
-func (m *mockLDAPClient) Execute(conf *client.Config, entries []*ldif.Entry, continueOnError bool) (err error) {
-	args := m.Called(conf, entries, continueOnError)
+func (m *mockLDAPClient) SearchDN(conf *client.Config, dn string) (*client.Entry, error) {
+	args := m.Called(conf, dn)
+	return args.Get(0).(*client.Entry), args.Error(1)
+}
+
+func (m *mockLDAPClient) SearchUser(conf *client.Config, username string) (*client.Entry, error) {
+	args := m.Called(conf, username)
+	return args.Get(0).(*client.Entry), args.Error(1)
+}
+
+func (m *mockLDAPClient) SearchSubtree(conf *client.Config, baseDN, filter string, attributes ...string) ([]*client.Entry, error) {
+	args := m.Called(conf, baseDN, filter, attributes)
+	return args.Get(0).([]*client.Entry), args.Error(1)
+}
+
+func (m *mockLDAPClient) PasswordExpiration(conf *client.Config, dn string) (time.Time, error) {
+	args := m.Called(conf, dn)
+	return args.Get(0).(time.Time), args.Error(1)
+}
+
+func (m *mockLDAPClient) VerifyBind(conf *client.Config, dn string, password string) error {
+	args := m.Called(conf, dn, password)
 	return args.Error(0)
 }
 
+func (m *mockLDAPClient) Subschema(conf *client.Config) (*client.Subschema, error) {
+	args := m.Called(conf)
+	return args.Get(0).(*client.Subschema), args.Error(1)
+}
+
+func (m *mockLDAPClient) IsGroupMember(conf *client.Config, groupDN, attribute, value string) (bool, error) {
+	args := m.Called(conf, groupDN, attribute, value)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockLDAPClient) AddGroupMember(conf *client.Config, groupDN, attribute, value string) (bool, error) {
+	args := m.Called(conf, groupDN, attribute, value)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockLDAPClient) RemoveGroupMember(conf *client.Config, groupDN, attribute, value string) error {
+	args := m.Called(conf, groupDN, attribute, value)
+	return args.Error(0)
+}
+
+func (m *mockLDAPClient) Execute(conf *client.Config, entries []*client.LDIFEntry, continueOnError bool) ([]client.ExecuteResult, error) {
+	args := m.Called(conf, entries, continueOnError)
+	results, _ := args.Get(0).([]client.ExecuteResult)
+	return results, args.Error(1)
+}
+
 var _ logical.Storage = (*mockStorage)(nil)
 
 type mockStorage struct {
@@ -0,0 +1,777 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-secure-stdlib/strutil"
+	"github.com/hashicorp/vault-plugin-secrets-openldap/client"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/hashicorp/vault/sdk/queue"
+)
+
+const (
+	exportPath = "export"
+	importPath = "import"
+
+	// bundleVersion is the version of the bundle format written by export.
+	// Import rejects bundles of other versions.
+	bundleVersion = 1
+
+	bundleTypeDynamicRole = "dynamic_role"
+	bundleTypeStaticRole  = "static_role"
+	bundleTypeLibrarySet  = "library_set"
+
+	conflictPolicySkip      = "skip"
+	conflictPolicyOverwrite = "overwrite"
+	conflictPolicyFail      = "fail"
+
+	importActionCreate    = "create"
+	importActionOverwrite = "overwrite"
+	importActionSkip      = "skip"
+)
+
+// bundleNameRegex matches the names accepted by the role and library set
+// paths.
+var bundleNameRegex = regexp.MustCompile(`^\w(([\w-./]+)?\w)?$`)
+
+// bundledStaticRole is the configuration of a static role in a bundle. The
+// password and rotation state of the account are not exported.
+type bundledStaticRole struct {
+	Username           string            `json:"username"`
+	DN                 string            `json:"dn,omitempty"`
+	RotationPeriod     time.Duration     `json:"rotation_period"`
+	RotateBeforeExpiry time.Duration     `json:"rotate_before_expiry,omitempty"`
+	ResponseTemplate   map[string]string `json:"response_template,omitempty"`
+}
+
+// importResult is the outcome of importing an object of a bundle. Action is
+// what was done, or would be done on a dry run. Objects with an Error are not
+// imported.
+type importResult struct {
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Action string `json:"action"`
+	Error  string `json:"error,omitempty"`
+
+	dynamicRole     *dynamicRole
+	staticRole      *bundledStaticRole
+	existingStatic  *roleEntry
+	librarySet      *librarySet
+	existingLibrary *librarySet
+}
+
+func (r *importResult) fail(format string, args ...interface{}) {
+	r.Error = fmt.Sprintf(format, args...)
+}
+
+func (b *backend) pathBundle() []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: exportPath + "$",
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: operationPrefixLDAP,
+				OperationVerb:   "export",
+				OperationSuffix: "configuration",
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathExportRead,
+				},
+			},
+			HelpSynopsis:    pathExportHelpSyn,
+			HelpDescription: pathExportHelpDesc,
+		},
+		{
+			Pattern: importPath + "$",
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: operationPrefixLDAP,
+				OperationVerb:   "import",
+				OperationSuffix: "configuration",
+			},
+			Fields: map[string]*framework.FieldSchema{
+				"version": {
+					Type:        framework.TypeInt,
+					Description: "Version of the bundle format.",
+					Required:    true,
+				},
+				"dynamic_roles": {
+					Type:        framework.TypeMap,
+					Description: "Dynamic roles of the bundle by name, as written by export.",
+				},
+				"static_roles": {
+					Type:        framework.TypeMap,
+					Description: "Static roles of the bundle by name, as written by export.",
+				},
+				"library_sets": {
+					Type:        framework.TypeMap,
+					Description: "Library sets of the bundle by name, as written by export.",
+				},
+				"conflict_policy": {
+					Type: framework.TypeString,
+					Description: "What to do with objects that already exist: skip them, overwrite them, or fail, " +
+						"which imports nothing if any object exists. Defaults to fail.",
+					Default: conflictPolicyFail,
+				},
+				"dry_run": {
+					Type:        framework.TypeBool,
+					Description: "Report what would be imported without changing anything.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback:                    b.pathImportWrite,
+					ForwardPerformanceStandby:   true,
+					ForwardPerformanceSecondary: true,
+				},
+			},
+			HelpSynopsis:    pathImportHelpSyn,
+			HelpDescription: pathImportHelpDesc,
+		},
+	}
+}
+
+func (b *backend) pathExportRead(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	dynamicRoles := map[string]*dynamicRole{}
+	err := walkStoragePath(ctx, req.Storage, dynamicRolePath, func(name string) (bool, error) {
+		if strings.HasSuffix(name, "/") {
+			return false, nil
+		}
+		dRole, err := retrieveDynamicRole(ctx, req.Storage, name)
+		if err != nil || dRole == nil {
+			return false, err
+		}
+		dynamicRoles[name] = dRole
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to export dynamic roles: %w", err)
+	}
+
+	staticRoles := map[string]*bundledStaticRole{}
+	err = walkStoragePath(ctx, req.Storage, staticRolePath, func(name string) (bool, error) {
+		if strings.HasSuffix(name, "/") {
+			return false, nil
+		}
+		role, err := b.staticRole(ctx, req.Storage, name)
+		if err != nil || role == nil || role.StaticAccount == nil {
+			return false, err
+		}
+		staticRoles[name] = &bundledStaticRole{
+			Username:           role.StaticAccount.Username,
+			DN:                 role.StaticAccount.DN,
+			RotationPeriod:     role.StaticAccount.RotationPeriod,
+			RotateBeforeExpiry: role.StaticAccount.RotateBeforeExpiry,
+			ResponseTemplate:   role.StaticAccount.ResponseTemplate,
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to export static roles: %w", err)
+	}
+
+	librarySets := map[string]*librarySet{}
+	err = walkStoragePath(ctx, req.Storage, libraryPrefix, func(name string) (bool, error) {
+		if strings.HasSuffix(name, "/") {
+			return false, nil
+		}
+		set, err := readSet(ctx, req.Storage, name)
+		if err != nil || set == nil {
+			return false, err
+		}
+		librarySets[name] = set
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to export library sets: %w", err)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"version":       bundleVersion,
+			"dynamic_roles": dynamicRoles,
+			"static_roles":  staticRoles,
+			"library_sets":  librarySets,
+		},
+	}, nil
+}
+
+func (b *backend) pathImportWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if version := data.Get("version").(int); version != bundleVersion {
+		return logical.ErrorResponse("unsupported bundle version %d, must be %d", version, bundleVersion), nil
+	}
+	policy := data.Get("conflict_policy").(string)
+	switch policy {
+	case conflictPolicySkip, conflictPolicyOverwrite, conflictPolicyFail:
+	default:
+		return logical.ErrorResponse("invalid conflict_policy %q, must be one of %q, %q, or %q",
+			policy, conflictPolicySkip, conflictPolicyOverwrite, conflictPolicyFail), nil
+	}
+	dryRun := data.Get("dry_run").(bool)
+
+	dynamicRoles, err := bundleObjects(data, "dynamic_roles")
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	staticRoles, err := bundleObjects(data, "static_roles")
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	librarySets, err := bundleObjects(data, "library_sets")
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	// Static roles stay locked for the whole import, as their passwords are
+	// rotated once the other objects are written and the locks shared with
+	// every other write are released.
+	for _, lock := range locksutil.LocksForKeys(b.roleLocks, sortedNames(staticRoles)) {
+		lock.Lock()
+		defer lock.Unlock()
+	}
+
+	results, apply, err := b.planAndApplyImport(ctx, req, policy, dryRun, dynamicRoles, staticRoles, librarySets)
+	if err != nil {
+		return nil, err
+	}
+
+	var failed int
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+		}
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"dry_run": dryRun,
+			"applied": false,
+			"results": results,
+		},
+	}
+	if !apply {
+		if failed > 0 {
+			resp.AddWarning(fmt.Sprintf("%d objects of the bundle cannot be imported, so nothing was imported.", failed))
+		}
+		return resp, nil
+	}
+
+	for _, result := range results {
+		if result.Type != bundleTypeStaticRole {
+			continue
+		}
+		b.applyImport(ctx, req, result)
+		if result.Error != "" {
+			b.releaseImportedUsername(result)
+			failed++
+		}
+	}
+	resp.Data["applied"] = true
+	if failed > 0 {
+		resp.AddWarning(fmt.Sprintf("%d objects of the bundle failed to import, the others were imported.", failed))
+	}
+	return resp, nil
+}
+
+// planAndApplyImport plans the import of a bundle and, unless any object
+// fails the plan or this is a dry run, writes its dynamic roles and library
+// sets. The usernames of static roles are reserved for them, so that the
+// static roles can be written without holding the locks of every object.
+func (b *backend) planAndApplyImport(ctx context.Context, req *logical.Request, policy string, dryRun bool, dynamicRoles, staticRoles, librarySets map[string]json.RawMessage) ([]*importResult, bool, error) {
+	// Take the locks of the other objects up front, in the order of the
+	// paths that write them, so that the plan holds until it is applied.
+	b.ldifTemplateLock.Lock()
+	defer b.ldifTemplateLock.Unlock()
+	for _, lock := range locksutil.LocksForKeys(b.checkOutLocks, sortedNames(librarySets)) {
+		lock.Lock()
+		defer lock.Unlock()
+	}
+	b.managedUserLock.Lock()
+	defer b.managedUserLock.Unlock()
+
+	results, err := b.planImport(ctx, req.Storage, policy, dynamicRoles, staticRoles, librarySets)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, result := range results {
+		if result.Error != "" {
+			return results, false, nil
+		}
+	}
+	if dryRun {
+		return results, false, nil
+	}
+
+	for _, result := range results {
+		switch {
+		case result.Type != bundleTypeStaticRole:
+			b.applyImport(ctx, req, result)
+		case result.Action != importActionSkip:
+			b.managedUsers[result.staticRole.Username] = struct{}{}
+		}
+	}
+	return results, true, nil
+}
+
+// releaseImportedUsername releases the username reserved for a static role
+// that failed to import, unless the role it overwrites manages it.
+func (b *backend) releaseImportedUsername(result *importResult) {
+	username := result.staticRole.Username
+	if result.existingStatic != nil && result.existingStatic.StaticAccount.Username == username {
+		return
+	}
+	b.managedUserLock.Lock()
+	defer b.managedUserLock.Unlock()
+	delete(b.managedUsers, username)
+}
+
+// bundleObjects returns the JSON encoded objects of a bundle field by name,
+// which are decoded as they are planned.
+func bundleObjects(data *framework.FieldData, field string) (map[string]json.RawMessage, error) {
+	raw, ok := data.GetOk(field)
+	if !ok {
+		return nil, nil
+	}
+	objects := make(map[string]json.RawMessage)
+	for name, value := range raw.(map[string]interface{}) {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", field, name, err)
+		}
+		objects[name] = encoded
+	}
+	return objects, nil
+}
+
+func sortedNames(objects map[string]json.RawMessage) []string {
+	names := make([]string, 0, len(objects))
+	for name := range objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// planImport validates each object of the bundle and decides what to do with
+// it according to the conflict policy. Usernames of static roles and library
+// sets must not be managed by anything other than the object they overwrite,
+// nor by another object of the bundle.
+func (b *backend) planImport(ctx context.Context, s logical.Storage, policy string, dynamicRoles, staticRoles, librarySets map[string]json.RawMessage) ([]*importResult, error) {
+	config, err := readConfig(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	var fragments map[string]string
+	var results []*importResult
+	for _, name := range sortedNames(dynamicRoles) {
+		result := &importResult{Type: bundleTypeDynamicRole, Name: name}
+		results = append(results, result)
+
+		dRole := &dynamicRole{}
+		if err := json.Unmarshal(dynamicRoles[name], dRole); err != nil {
+			result.fail("invalid dynamic role: %s", err)
+			continue
+		}
+		dRole.Name = name
+		if len(dRole.LDIFSources) > 0 && fragments == nil {
+			fragments, err = listLDIFTemplates(ctx, s)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read ldif templates: %w", err)
+			}
+		}
+		if err := prepareImportedDynamicRole(dRole, fragments); err != nil {
+			result.fail("%s", err)
+			continue
+		}
+		existing, err := retrieveDynamicRole(ctx, s, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read dynamic role %q: %w", name, err)
+		}
+		if !planAction(result, existing != nil, policy) {
+			continue
+		}
+		result.dynamicRole = dRole
+	}
+
+	// claimed holds the usernames claimed by the static roles and library
+	// sets of the bundle, and owned those managed by the objects they
+	// overwrite.
+	claimed := make(map[string]*importResult)
+	owned := make(map[string]bool)
+	claim := func(result *importResult, username string) {
+		if other, ok := claimed[username]; ok {
+			result.fail("%q is also managed by %s %q of the bundle", username, other.Type, other.Name)
+			return
+		}
+		if _, managed := b.managedUsers[username]; managed && !owned[username] {
+			result.fail("%q is already managed by the secrets engine", username)
+			return
+		}
+		claimed[username] = result
+	}
+
+	for _, name := range sortedNames(staticRoles) {
+		result := &importResult{Type: bundleTypeStaticRole, Name: name}
+		results = append(results, result)
+
+		role := &bundledStaticRole{}
+		if err := json.Unmarshal(staticRoles[name], role); err != nil {
+			result.fail("invalid static role: %s", err)
+			continue
+		}
+		if err := validateImportedStaticRole(name, role); err != nil {
+			result.fail("%s", err)
+			continue
+		}
+		if config == nil {
+			result.fail("missing LDAP configuration")
+			continue
+		}
+		existing, err := b.staticRole(ctx, s, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read static role %q: %w", name, err)
+		}
+		if !planAction(result, existing != nil, policy) {
+			continue
+		}
+		owned = make(map[string]bool)
+		if existing != nil {
+			owned[existing.StaticAccount.Username] = true
+		}
+		if claim(result, role.Username); result.Error != "" {
+			continue
+		}
+		result.staticRole = role
+		result.existingStatic = existing
+	}
+
+	for _, name := range sortedNames(librarySets) {
+		result := &importResult{Type: bundleTypeLibrarySet, Name: name}
+		results = append(results, result)
+
+		set := &librarySet{}
+		if err := json.Unmarshal(librarySets[name], set); err != nil {
+			result.fail("invalid library set: %s", err)
+			continue
+		}
+		if !bundleNameRegex.MatchString(name) || name != strings.ToLower(name) {
+			result.fail("invalid name")
+			continue
+		}
+		if err := set.Validate(); err != nil {
+			result.fail("%s", err)
+			continue
+		}
+		if config == nil {
+			result.fail("missing LDAP configuration")
+			continue
+		}
+		existing, err := readSet(ctx, s, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read library set %q: %w", name, err)
+		}
+		if !planAction(result, existing != nil, policy) {
+			continue
+		}
+		owned = make(map[string]bool)
+		if existing != nil {
+			for _, account := range existing.ServiceAccountNames {
+				owned[account] = true
+			}
+			// Service accounts that the set no longer has must be checked in.
+			for _, account := range strutil.Difference(existing.ServiceAccountNames, set.ServiceAccountNames, true) {
+				checkOut, err := b.LoadCheckOut(ctx, s, account)
+				if err != nil && !errors.Is(err, errNotFound) {
+					return nil, err
+				}
+				if checkOut != nil && !checkOut.IsAvailable {
+					result.fail("%q can't be removed because it is currently checked out", account)
+					break
+				}
+			}
+			if result.Error != "" {
+				continue
+			}
+		}
+		for _, account := range set.ServiceAccountNames {
+			if claim(result, account); result.Error != "" {
+				break
+			}
+		}
+		if result.Error != "" {
+			continue
+		}
+		result.librarySet = set
+		result.existingLibrary = existing
+	}
+	return results, nil
+}
+
+// planAction sets the action of an object according to whether it exists and
+// the conflict policy, and returns whether it is to be written.
+func planAction(result *importResult, exists bool, policy string) bool {
+	if !exists {
+		result.Action = importActionCreate
+		return true
+	}
+	switch policy {
+	case conflictPolicySkip:
+		result.Action = importActionSkip
+		return false
+	case conflictPolicyOverwrite:
+		result.Action = importActionOverwrite
+		return true
+	default:
+		result.Action = importActionOverwrite
+		result.fail("%s already exists", strings.ReplaceAll(result.Type, "_", " "))
+		return false
+	}
+}
+
+// prepareImportedDynamicRole applies the defaults of role writes to an
+// imported dynamic role and validates it. LDIF that includes shared
+// fragments is resolved against the fragments of this mount.
+func prepareImportedDynamicRole(dRole *dynamicRole, fragments map[string]string) error {
+	if !bundleNameRegex.MatchString(dRole.Name) || dRole.Name != strings.ToLower(dRole.Name) {
+		return errors.New("invalid name")
+	}
+	if err := dRole.resolveLDIF(fragments); err != nil {
+		return err
+	}
+	if dRole.OrphanSearch != nil && *dRole.OrphanSearch == (orphanSearch{}) {
+		dRole.OrphanSearch = nil
+	}
+	if dRole.roleType() == dynamicRoleTypeMembership && dRole.MemberAttribute == "" {
+		dRole.MemberAttribute = client.MemberAttributeMember
+	}
+	return validateDynamicRole(dRole)
+}
+
+// validateImportedStaticRole validates a static role as the static role path
+// validates writes.
+func validateImportedStaticRole(name string, role *bundledStaticRole) error {
+	if !bundleNameRegex.MatchString(name) || name != strings.ToLower(name) {
+		return errors.New("invalid name")
+	}
+	if role.Username == "" {
+		return errors.New("username must not be empty")
+	}
+	if role.RotationPeriod < queueTickSeconds*time.Second {
+		return fmt.Errorf("rotation_period must be %d seconds or more", queueTickSeconds)
+	}
+	if role.RotateBeforeExpiry < 0 {
+		return errors.New("rotate_before_expiry must not be negative")
+	}
+	return validateResponseTemplate(role.ResponseTemplate, staticResponseFields, sampleStaticTemplateData)
+}
+
+// applyImport writes a planned object, and records a failure in its result.
+func (b *backend) applyImport(ctx context.Context, req *logical.Request, result *importResult) {
+	if result.Action == importActionSkip {
+		return
+	}
+	operation := "create"
+	if result.Action == importActionOverwrite {
+		operation = "update"
+	}
+
+	var err error
+	var event string
+	switch result.Type {
+	case bundleTypeDynamicRole:
+		event = "role-" + operation
+		err = b.importDynamicRole(ctx, req.Storage, result.dynamicRole)
+	case bundleTypeStaticRole:
+		event = "static-role-" + operation
+		err = b.importStaticRole(ctx, req.Storage, result.Name, result.staticRole, result.existingStatic)
+	case bundleTypeLibrarySet:
+		event = "library-" + operation
+		err = b.importLibrarySet(ctx, req.Storage, result.Name, result.librarySet, result.existingLibrary)
+	}
+	if err != nil {
+		b.Logger().Error("failed to import object", "type", result.Type, "name", result.Name, "error", err)
+		result.fail("%s", err)
+		return
+	}
+	b.ldapEvent(ctx, event, req.Path, result.Name, true)
+}
+
+func (b *backend) importDynamicRole(ctx context.Context, s logical.Storage, dRole *dynamicRole) error {
+	if err := storeDynamicRole(ctx, s, dRole); err != nil {
+		return fmt.Errorf("failed to save dynamic role: %w", err)
+	}
+	if dRole.MaxActiveCredentials > 0 || dRole.MaxActivePerEntity > 0 {
+		if err := b.reconcileActiveCredentials(ctx, s, dRole.Name); err != nil {
+			b.Logger().Warn("failed to reconcile active credentials", "role", dRole.Name, "error", err)
+		}
+	}
+	return nil
+}
+
+// importStaticRole writes a static role. Overwriting a role of the same
+// account keeps its password and rotation schedule. Otherwise the password is
+// rotated, unless the config skips import rotations, as on role creation.
+func (b *backend) importStaticRole(ctx context.Context, s logical.Storage, name string, imported *bundledStaticRole, existing *roleEntry) error {
+	role := &roleEntry{StaticAccount: &staticAccount{}}
+	sameAccount := existing != nil && existing.StaticAccount.Username == imported.Username &&
+		existing.StaticAccount.DN == imported.DN
+	if sameAccount {
+		role = existing
+	}
+	account := role.StaticAccount
+	account.Username = imported.Username
+	account.DN = imported.DN
+	account.RotationPeriod = imported.RotationPeriod
+	account.RotateBeforeExpiry = imported.RotateBeforeExpiry
+	account.ResponseTemplate = imported.ResponseTemplate
+
+	config, err := readConfig(ctx, s)
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		return errors.New("missing LDAP configuration")
+	}
+
+	switch {
+	case sameAccount || config.SkipStaticRoleImportRotation:
+		lastVaultRotation := account.LastVaultRotation
+		if lastVaultRotation.IsZero() {
+			lastVaultRotation = time.Now()
+		}
+		account.SetNextVaultRotation(lastVaultRotation)
+		if err := b.refreshPasswordExpiration(ctx, s, account); err != nil {
+			return err
+		}
+		entry, err := logical.StorageEntryJSON(staticRolePath+name, role)
+		if err != nil {
+			return err
+		}
+		if err := s.Put(ctx, entry); err != nil {
+			return err
+		}
+	default:
+		// setStaticAccountPassword saves the role to storage
+		resp, err := b.setStaticAccountPassword(ctx, s, &setStaticAccountInput{
+			RoleName: name,
+			Role:     role,
+		})
+		if err != nil {
+			if resp != nil && resp.WALID != "" {
+				if walDeleteErr := framework.DeleteWAL(ctx, s, resp.WALID); walDeleteErr != nil {
+					b.Logger().Debug("failed to delete WAL for failed role import", "WAL ID", resp.WALID, "error", walDeleteErr)
+				}
+			}
+			return fmt.Errorf("failed to rotate password: %w", err)
+		}
+	}
+
+	// A role of the same account keeps its queue item, which may track a WAL
+	// ID. The WAL of a replaced account holds a password that is never set,
+	// so it is deleted along with the item.
+	item := &queue.Item{Key: name}
+	if existing != nil {
+		if prev, err := b.popFromRotationQueueByKey(name); err == nil {
+			if sameAccount {
+				item = prev
+			} else if walID, ok := prev.Value.(string); ok && walID != "" {
+				b.Logger().Debug("deleting WAL for replaced static account", "WAL ID", walID, "role", name)
+				if err := framework.DeleteWAL(ctx, s, walID); err != nil {
+					b.Logger().Warn("failed to delete WAL for replaced static account", "WAL ID", walID, "error", err)
+				}
+			}
+		}
+	}
+	item.Priority = account.NextRotationTime().Unix()
+	if err := b.pushItem(item); err != nil {
+		return err
+	}
+
+	// The username was reserved when the import was planned.
+	b.managedUserLock.Lock()
+	defer b.managedUserLock.Unlock()
+	if existing != nil && existing.StaticAccount.Username != account.Username {
+		delete(b.managedUsers, existing.StaticAccount.Username)
+	}
+	b.managedUsers[account.Username] = struct{}{}
+	return nil
+}
+
+// importLibrarySet writes a library set, checking in the service accounts it
+// adds as library set writes do.
+func (b *backend) importLibrarySet(ctx context.Context, s logical.Storage, name string, set *librarySet, existing *librarySet) error {
+	beingAdded := set.ServiceAccountNames
+	var beingDeleted []string
+	if existing != nil {
+		beingAdded = strutil.Difference(set.ServiceAccountNames, existing.ServiceAccountNames, true)
+		beingDeleted = strutil.Difference(existing.ServiceAccountNames, set.ServiceAccountNames, true)
+	}
+	for _, account := range beingAdded {
+		if err := b.CheckIn(ctx, s, account); err != nil {
+			return fmt.Errorf("failed to check in %q: %w", account, err)
+		}
+	}
+	for _, account := range beingDeleted {
+		if err := b.DeleteCheckout(ctx, s, account); err != nil {
+			return err
+		}
+	}
+	if err := storeSet(ctx, s, name, set); err != nil {
+		return err
+	}
+	for _, account := range beingDeleted {
+		delete(b.managedUsers, account)
+	}
+	for _, account := range beingAdded {
+		b.managedUsers[account] = struct{}{}
+	}
+	return nil
+}
+
+const pathExportHelpSyn = `
+Export the dynamic roles, static roles, and library sets of the mount.
+`
+
+const pathExportHelpDesc = `
+This path returns a versioned bundle of the definitions of every dynamic role,
+static role, and library set, which the import path of another mount accepts.
+Passwords and the rotation and check-out state of accounts are not exported.
+Dynamic roles that include shared ldif-template fragments are exported with the
+LDIF as written, so the fragments must exist on the mount they are imported to.
+`
+
+const pathImportHelpSyn = `
+Import dynamic roles, static roles, and library sets from an exported bundle.
+`
+
+const pathImportHelpDesc = `
+This path writes the objects of a bundle written by the export path. Each
+object is validated as a write of its path would be, and conflict_policy
+decides whether objects that already exist are skipped, overwritten, or fail
+the import. Usernames of static roles and library sets must not be managed by
+other roles or sets. If any object fails these checks, nothing is imported.
+
+With dry_run, nothing is changed. Otherwise, static roles are created with a
+password rotation unless the config skips import rotations, and the service
+accounts of library sets are checked in, as when they are written directly.
+Overwriting a static role of the same account keeps its password. Static roles
+are written last, one at a time.
+
+Objects are written one by one, so an object that fails to be written, such as
+a static role whose password cannot be rotated, leaves the bundle partially
+applied: the objects written before and after it are kept. The response
+reports the action taken for each object, and its error if it could not be
+imported, so that the failed objects can be fixed and imported again.
+`
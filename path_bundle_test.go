@@ -0,0 +1,359 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/vault-plugin-secrets-openldap/client"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/jsonutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+// exportBundle exports the configuration of the mount and decodes it as the
+// import path would receive it over the API.
+func exportBundle(t *testing.T, b *backend, s logical.Storage) map[string]interface{} {
+	t.Helper()
+	resp, err := pathOperation(t, b, s, exportPath, nil, logical.ReadOperation)
+	assertNoError(t, resp, err)
+
+	encoded, err := json.Marshal(resp.Data)
+	require.NoError(t, err)
+	require.NotContains(t, strings.ToLower(string(encoded)), "password\"")
+
+	var bundle map[string]interface{}
+	require.NoError(t, jsonutil.DecodeJSON(encoded, &bundle))
+	return bundle
+}
+
+func importBundle(t *testing.T, b *backend, s logical.Storage, bundle map[string]interface{}, options map[string]interface{}) (*logical.Response, []*importResult) {
+	t.Helper()
+	data := make(map[string]interface{})
+	for k, v := range bundle {
+		data[k] = v
+	}
+	for k, v := range options {
+		data[k] = v
+	}
+	resp, err := pathOperation(t, b, s, importPath, data, logical.UpdateOperation)
+	assertNoError(t, resp, err)
+	return resp, resp.Data["results"].([]*importResult)
+}
+
+func resultActions(results []*importResult) map[string]string {
+	actions := make(map[string]string, len(results))
+	for _, result := range results {
+		actions[result.Type+" "+result.Name] = result.Action
+		if result.Error != "" {
+			actions[result.Type+" "+result.Name] += ": " + result.Error
+		}
+	}
+	return actions
+}
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+
+	// The source mount has a role, static role and library set of each kind.
+	src, srcStorage := getBackend(false)
+	defer src.Cleanup(ctx)
+	configureOpenLDAPMount(t, src, srcStorage)
+	resp, err := createDynamicRoleWithData(t, src, srcStorage, "org/dev", getTestDynamicRoleConfig("org/dev"))
+	assertNoError(t, resp, err)
+	staticData := getTestStaticRoleConfig("hashicorp")
+	staticData["response_template"] = map[string]interface{}{"upn": "{{.Username}}@example.com"}
+	resp, err = createStaticRoleWithData(t, src, srcStorage, "hashicorp", staticData)
+	assertNoError(t, resp, err)
+	resp, err = pathOperation(t, src, srcStorage, libraryPrefix+"test-set", map[string]interface{}{
+		"service_account_names": []string{"tester1@example.com", "tester2@example.com"},
+		"ttl":                   "10h",
+		"max_ttl":               "11h",
+	}, logical.CreateOperation)
+	assertNoError(t, resp, err)
+
+	bundle := exportBundle(t, src, srcStorage)
+	require.Equal(t, json.Number("1"), bundle["version"])
+	require.Contains(t, bundle["dynamic_roles"], "org/dev")
+	require.Contains(t, bundle["static_roles"], "hashicorp")
+	require.Contains(t, bundle["library_sets"], "test-set")
+
+	dst, dstStorage := getBackend(false)
+	defer dst.Cleanup(ctx)
+	configureOpenLDAPMount(t, dst, dstStorage)
+
+	t.Run("dry run", func(t *testing.T) {
+		resp, results := importBundle(t, dst, dstStorage, bundle, map[string]interface{}{"dry_run": true})
+		require.Equal(t, false, resp.Data["applied"])
+		require.Equal(t, map[string]string{
+			"dynamic_role org/dev":  importActionCreate,
+			"static_role hashicorp": importActionCreate,
+			"library_set test-set":  importActionCreate,
+		}, resultActions(results))
+
+		dRole, err := retrieveDynamicRole(ctx, dstStorage, "org/dev")
+		require.NoError(t, err)
+		require.Nil(t, dRole)
+		require.Empty(t, dst.managedUsers)
+	})
+
+	t.Run("import", func(t *testing.T) {
+		resp, results := importBundle(t, dst, dstStorage, bundle, nil)
+		require.Equal(t, true, resp.Data["applied"])
+		require.Equal(t, map[string]string{
+			"dynamic_role org/dev":  importActionCreate,
+			"static_role hashicorp": importActionCreate,
+			"library_set test-set":  importActionCreate,
+		}, resultActions(results))
+
+		srcRole, err := retrieveDynamicRole(ctx, srcStorage, "org/dev")
+		require.NoError(t, err)
+		dstRole, err := retrieveDynamicRole(ctx, dstStorage, "org/dev")
+		require.NoError(t, err)
+		require.Equal(t, srcRole, dstRole)
+
+		// The static role is imported with a new password.
+		cred := readStaticCred(t, dst, dstStorage, "hashicorp")
+		require.NotEmpty(t, cred.Data["password"])
+		require.Equal(t, "hashicorp@example.com", cred.Data["upn"])
+		role, err := dst.staticRole(ctx, dstStorage, "hashicorp")
+		require.NoError(t, err)
+		require.Equal(t, float64(5), role.StaticAccount.RotationPeriod.Seconds())
+
+		resp, err = pathOperation(t, dst, dstStorage, libraryPrefix+"test-set/status", nil, logical.ReadOperation)
+		assertNoError(t, resp, err)
+		require.Contains(t, resp.Data, "tester1@example.com")
+
+		require.Equal(t, map[string]struct{}{
+			"hashicorp":           {},
+			"tester1@example.com": {},
+			"tester2@example.com": {},
+		}, dst.managedUsers)
+	})
+
+	t.Run("fail on conflict", func(t *testing.T) {
+		resp, results := importBundle(t, dst, dstStorage, bundle, nil)
+		require.Equal(t, false, resp.Data["applied"])
+		require.Len(t, resp.Warnings, 1)
+		require.Equal(t, map[string]string{
+			"dynamic_role org/dev":  "overwrite: dynamic role already exists",
+			"static_role hashicorp": "overwrite: static role already exists",
+			"library_set test-set":  "overwrite: library set already exists",
+		}, resultActions(results))
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		resp, results := importBundle(t, dst, dstStorage, bundle, map[string]interface{}{"conflict_policy": "skip"})
+		require.Equal(t, true, resp.Data["applied"])
+		require.Equal(t, map[string]string{
+			"dynamic_role org/dev":  importActionSkip,
+			"static_role hashicorp": importActionSkip,
+			"library_set test-set":  importActionSkip,
+		}, resultActions(results))
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		before := readStaticCred(t, dst, dstStorage, "hashicorp")
+
+		dynamicRoles := bundle["dynamic_roles"].(map[string]interface{})
+		dynamicRoles["org/dev"].(map[string]interface{})["username_template"] = "v-{{.RoleName}}-{{random 10}}"
+		libraries := bundle["library_sets"].(map[string]interface{})
+		libraries["test-set"].(map[string]interface{})["service_account_names"] = []interface{}{"tester1@example.com", "tester3@example.com"}
+
+		resp, results := importBundle(t, dst, dstStorage, bundle, map[string]interface{}{"conflict_policy": "overwrite"})
+		require.Equal(t, true, resp.Data["applied"])
+		require.Equal(t, map[string]string{
+			"dynamic_role org/dev":  importActionOverwrite,
+			"static_role hashicorp": importActionOverwrite,
+			"library_set test-set":  importActionOverwrite,
+		}, resultActions(results))
+
+		dRole, err := retrieveDynamicRole(ctx, dstStorage, "org/dev")
+		require.NoError(t, err)
+		require.Equal(t, "v-{{.RoleName}}-{{random 10}}", dRole.UsernameTemplate)
+
+		// Overwriting a static role of the same account keeps its password.
+		after := readStaticCred(t, dst, dstStorage, "hashicorp")
+		require.Equal(t, before.Data["password"], after.Data["password"])
+
+		require.Equal(t, map[string]struct{}{
+			"hashicorp":           {},
+			"tester1@example.com": {},
+			"tester3@example.com": {},
+		}, dst.managedUsers)
+	})
+}
+
+func TestImport_validation(t *testing.T) {
+	ctx := context.Background()
+	b, storage := getBackend(false)
+	defer b.Cleanup(ctx)
+	configureOpenLDAPMount(t, b, storage)
+
+	resp, err := createStaticRoleWithData(t, b, storage, "existing", getTestStaticRoleConfig("existing"))
+	assertNoError(t, resp, err)
+
+	t.Run("invalid requests", func(t *testing.T) {
+		resp, err := pathOperation(t, b, storage, importPath, map[string]interface{}{"version": 2}, logical.UpdateOperation)
+		require.NoError(t, err)
+		require.ErrorContains(t, resp.Error(), "unsupported bundle version 2")
+
+		resp, err = pathOperation(t, b, storage, importPath, map[string]interface{}{
+			"version":         1,
+			"conflict_policy": "merge",
+		}, logical.UpdateOperation)
+		require.NoError(t, err)
+		require.ErrorContains(t, resp.Error(), `invalid conflict_policy "merge"`)
+	})
+
+	t.Run("invalid objects", func(t *testing.T) {
+		resp, results := importBundle(t, b, storage, map[string]interface{}{
+			"version": 1,
+			"dynamic_roles": map[string]interface{}{
+				"no-deletion": map[string]interface{}{"creation_ldif": ldifCreationTemplate},
+			},
+			"static_roles": map[string]interface{}{
+				"new-user":     map[string]interface{}{"username": "new-user", "rotation_period": 5000000000},
+				"often":        map[string]interface{}{"username": "often", "rotation_period": 1000000000},
+				"Upper":        map[string]interface{}{"username": "upper", "rotation_period": 5000000000},
+				"same-account": map[string]interface{}{"username": "existing", "rotation_period": 5000000000},
+				"same-user":    map[string]interface{}{"username": "tester1@example.com", "rotation_period": 5000000000},
+			},
+			"library_sets": map[string]interface{}{
+				"test-set": map[string]interface{}{"service_account_names": []string{"tester1@example.com"}},
+				"empty":    map[string]interface{}{"service_account_names": []string{}},
+			},
+		}, map[string]interface{}{"conflict_policy": "overwrite"})
+		require.Equal(t, false, resp.Data["applied"])
+		require.Equal(t, map[string]string{
+			"dynamic_role no-deletion": ": missing deletion_ldif",
+			"static_role Upper":        ": invalid name",
+			"static_role new-user":     importActionCreate,
+			"static_role often":        ": rotation_period must be 5 seconds or more",
+			"static_role same-account": `create: "existing" is already managed by the secrets engine`,
+			"static_role same-user":    importActionCreate,
+			"library_set empty":        ": at least one service account must be configured",
+			"library_set test-set":     `create: "tester1@example.com" is also managed by static_role "same-user" of the bundle`,
+		}, resultActions(results))
+
+		role, err := b.staticRole(ctx, storage, "new-user")
+		require.NoError(t, err)
+		require.Nil(t, role)
+		_, managed := b.managedUsers["new-user"]
+		require.False(t, managed)
+	})
+}
+
+// importRotationClient fails password updates of failUsername, and records
+// whether the locks shared by every write were held during any update.
+type importRotationClient struct {
+	fakeLdapClient
+	b            *backend
+	failUsername string
+	sharedLocked bool
+}
+
+func (c *importRotationClient) UpdateUserPassword(_ *client.Config, username string, _ string) error {
+	for _, lock := range []*sync.Mutex{&c.b.ldifTemplateLock, &c.b.managedUserLock} {
+		if !lock.TryLock() {
+			c.sharedLocked = true
+			continue
+		}
+		lock.Unlock()
+	}
+	if username == c.failUsername {
+		return errors.New("insufficient access")
+	}
+	return nil
+}
+
+func TestImport_staticRoleRotation(t *testing.T) {
+	ctx := context.Background()
+	b, storage := getBackend(false)
+	defer b.Cleanup(ctx)
+	configureOpenLDAPMount(t, b, storage)
+
+	directory := &importRotationClient{b: b, failUsername: "locked-out"}
+	b.client = directory
+
+	resp, results := importBundle(t, b, storage, map[string]interface{}{
+		"version": 1,
+		"dynamic_roles": map[string]interface{}{
+			"org/dev": map[string]interface{}{"creation_ldif": ldifCreationTemplate, "deletion_ldif": ldifDeleteTemplate},
+		},
+		"static_roles": map[string]interface{}{
+			"rotated":    map[string]interface{}{"username": "rotated", "rotation_period": 5000000000},
+			"locked-out": map[string]interface{}{"username": "locked-out", "rotation_period": 5000000000},
+		},
+	}, nil)
+
+	// The bundle is partially applied.
+	require.Equal(t, true, resp.Data["applied"])
+	require.Len(t, resp.Warnings, 1)
+	actions := resultActions(results)
+	require.Equal(t, importActionCreate, actions["dynamic_role org/dev"])
+	require.Equal(t, importActionCreate, actions["static_role rotated"])
+	require.Contains(t, actions["static_role locked-out"], "insufficient access")
+	require.False(t, directory.sharedLocked)
+
+	role, err := b.staticRole(ctx, storage, "locked-out")
+	require.NoError(t, err)
+	require.Nil(t, role)
+	require.Equal(t, map[string]struct{}{"rotated": {}}, b.managedUsers)
+}
+
+func TestImport_staticRoleOverwriteAccount(t *testing.T) {
+	ctx := context.Background()
+	b, storage := getBackend(false)
+	defer b.Cleanup(ctx)
+	configureOpenLDAPMount(t, b, storage)
+
+	bundle := map[string]interface{}{
+		"version": 1,
+		"static_roles": map[string]interface{}{
+			"hashicorp": map[string]interface{}{"username": "alice", "rotation_period": 5000000000},
+		},
+	}
+	_, results := importBundle(t, b, storage, bundle, nil)
+	require.Equal(t, map[string]string{"static_role hashicorp": importActionCreate}, resultActions(results))
+
+	// A rotation of the old account that is being retried.
+	walID, err := framework.PutWAL(ctx, storage, staticWALKey, &setCredentialsWAL{
+		NewPassword: "retried",
+		RoleName:    "hashicorp",
+		Username:    "alice",
+	})
+	require.NoError(t, err)
+	item, err := b.popFromRotationQueueByKey("hashicorp")
+	require.NoError(t, err)
+	item.Value = walID
+	require.NoError(t, b.pushItem(item))
+
+	bundle["static_roles"] = map[string]interface{}{
+		"hashicorp": map[string]interface{}{"username": "bob", "rotation_period": 5000000000},
+	}
+	_, results = importBundle(t, b, storage, bundle, map[string]interface{}{"conflict_policy": "overwrite"})
+	require.Equal(t, map[string]string{"static_role hashicorp": importActionOverwrite}, resultActions(results))
+
+	role, err := b.staticRole(ctx, storage, "hashicorp")
+	require.NoError(t, err)
+	require.Equal(t, "bob", role.StaticAccount.Username)
+
+	// The WAL of the old account is deleted rather than tracked by the new
+	// account's queue item.
+	walIDs, err := framework.ListWAL(ctx, storage)
+	require.NoError(t, err)
+	require.NotContains(t, walIDs, walID)
+	item, err = b.popFromRotationQueueByKey("hashicorp")
+	require.NoError(t, err)
+	require.NotEqual(t, walID, item.Value)
+
+	require.Equal(t, map[string]struct{}{"bob": {}}, b.managedUsers)
+}
@@ -114,6 +114,31 @@ func (b *backend) configFields() map[string]*framework.FieldSchema {
 		Default: defaultCredentialType,
 	}
 
+	fields["default_password_policy_dn"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+		Description: "DN of the OpenLDAP ppolicy that applies to entries without a pwdPolicySubentry, " +
+			"usually the olcPPolicyDefault of the overlay. Used by rotate_before_expiry.",
+	}
+
+	fields["rotation_binddn"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+		Description: "DN of a privileged account used only to reset the binddn password " +
+			"on root credential rotation. It binds by DN even if upndomain is set. " +
+			"Its password is rotated after the binddn password.",
+	}
+	fields["rotation_bindpass"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: "Password of the rotation_binddn account.",
+		DisplayAttrs: &framework.DisplayAttributes{
+			Sensitive: true,
+		},
+	}
+	fields["rotation_static_role"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+		Description: "Name of a static role whose account is used only to reset the binddn " +
+			"password on root credential rotation. Mutually exclusive with rotation_binddn.",
+	}
+
 	automatedrotationutil.AddAutomatedRotationFields(fields)
 
 	// Deprecated
@@ -200,6 +225,11 @@ func (b *backend) configCreateUpdateOperation(ctx context.Context, req *logical.
 		staticSkip = conf.SkipStaticRoleImportRotation // use existing value if not set
 	}
 
+	defaultPolicyDN := conf.LDAP.DefaultPasswordPolicyDN
+	if defaultPolicyDNRaw, ok := fieldData.GetOk("default_password_policy_dn"); ok {
+		defaultPolicyDN = defaultPolicyDNRaw.(string)
+	}
+
 	// CreateOperations and UpdateOperations should default to credential_type "password"
 	credentialType := defaultCredentialType.String()
 	if credentialTypeRaw, ok := fieldData.GetOk("credential_type"); ok {
@@ -214,12 +244,51 @@ func (b *backend) configCreateUpdateOperation(ctx context.Context, req *logical.
 		return logical.ErrorResponse(err.Error()), nil
 	}
 
+	rotationBindDN := conf.RotationBindDN
+	if rotationBindDNRaw, ok := fieldData.GetOk("rotation_binddn"); ok {
+		rotationBindDN = rotationBindDNRaw.(string)
+	}
+	rotationBindPass := conf.RotationBindPassword
+	if rotationBindPassRaw, ok := fieldData.GetOk("rotation_bindpass"); ok {
+		rotationBindPass = rotationBindPassRaw.(string)
+	}
+	rotationStaticRole := conf.RotationStaticRole
+	if rotationStaticRoleRaw, ok := fieldData.GetOk("rotation_static_role"); ok {
+		rotationStaticRole = rotationStaticRoleRaw.(string)
+	}
+	if rotationBindDN != "" && rotationStaticRole != "" {
+		return logical.ErrorResponse("cannot set both 'rotation_binddn' and 'rotation_static_role'"), nil
+	}
+	if rotationBindDN != "" && rotationBindPass == "" {
+		return logical.ErrorResponse("'rotation_bindpass' is required when 'rotation_binddn' is set"), nil
+	}
+	if rotationBindDN == "" {
+		rotationBindPass = ""
+	}
+	if rotationStaticRole != "" && rotationStaticRole != conf.RotationStaticRole {
+		role, err := b.staticRole(ctx, req.Storage, rotationStaticRole)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			return logical.ErrorResponse("rotation_static_role %q does not exist", rotationStaticRole), nil
+		}
+	}
+	if rotationBindDN != conf.RotationBindDN || rotationBindPass != conf.RotationBindPassword {
+		conf.LastRotationBindPassword = ""
+		conf.LastRotationBindPasswordRotation = time.Time{}
+	}
+
 	// Update config field values
 	conf.PasswordPolicy = passPolicy
 	conf.PasswordLength = passLength
 	conf.SkipStaticRoleImportRotation = staticSkip
+	conf.RotationBindDN = rotationBindDN
+	conf.RotationBindPassword = rotationBindPass
+	conf.RotationStaticRole = rotationStaticRole
 	conf.LDAP.ConfigEntry = ldapConf
 	conf.LDAP.Schema = schema
+	conf.LDAP.DefaultPasswordPolicyDN = defaultPolicyDN
 
 	// set up rotation after everything is fine
 	var rotOp string
@@ -335,6 +404,9 @@ func (b *backend) configReadOperation(ctx context.Context, req *logical.Request,
 	if config.LDAP.Schema != "" {
 		configMap["schema"] = config.LDAP.Schema
 	}
+	if config.LDAP.DefaultPasswordPolicyDN != "" {
+		configMap["default_password_policy_dn"] = config.LDAP.DefaultPasswordPolicyDN
+	}
 	configMap["credential_type"] = config.LDAP.CredentialType.String()
 	if config.LDAP.CredentialType == client.CredentialTypeUnknown {
 		// this handles the upgrade path for legacy configs created before
@@ -342,6 +414,17 @@ func (b *backend) configReadOperation(ctx context.Context, req *logical.Request,
 		configMap["credential_type"] = client.CredentialTypePassword.String()
 	}
 
+	// "rotation_bindpass" is intentionally not returned by this endpoint
+	if config.RotationBindDN != "" {
+		configMap["rotation_binddn"] = config.RotationBindDN
+	}
+	if config.RotationStaticRole != "" {
+		configMap["rotation_static_role"] = config.RotationStaticRole
+	}
+	if !config.LastRotationBindPasswordRotation.IsZero() {
+		configMap["last_rotation_bindpass_rotation"] = config.LastRotationBindPasswordRotation
+	}
+
 	config.PopulateAutomatedRotationData(configMap)
 
 	resp := &logical.Response{
@@ -366,6 +449,21 @@ type config struct {
 	PasswordPolicy               string `json:"password_policy,omitempty"`
 	SkipStaticRoleImportRotation bool   `json:"skip_static_role_import_rotation"`
 
+	// RotationBindDN and RotationBindPassword identify an optional account
+	// used only to reset the BindDN password on root credential rotation.
+	RotationBindDN                   string    `json:"rotation_binddn,omitempty"`
+	RotationBindPassword             string    `json:"rotation_bindpass,omitempty"`
+	LastRotationBindPassword         string    `json:"last_rotation_bindpass,omitempty"`
+	LastRotationBindPasswordRotation time.Time `json:"last_rotation_bindpass_rotation,omitempty"`
+
+	// RotationStaticRole optionally names a static role whose account is used
+	// to reset the BindDN password on root credential rotation.
+	RotationStaticRole string `json:"rotation_static_role,omitempty"`
+
+	// PendingRotations holds the passwords of rotations of the credentials
+	// above that are in progress, which are referenced by ID from their WAL.
+	PendingRotations map[rootCredential]*pendingRotation `json:"pending_rotations,omitempty"`
+
 	automatedrotationutil.AutomatedRotationParams
 
 	// Deprecated
@@ -602,6 +602,46 @@ func TestConfig_Update(t *testing.T) {
 				initialPasswordPolicy, resp.Data["password_policy"])
 		}
 	})
+
+	t.Run("update retains default_password_policy_dn", func(t *testing.T) {
+		b, storage := getBackend(false)
+		defer b.Cleanup(context.Background())
+
+		policyDN := "cn=default,ou=policies,dc=example,dc=com"
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      configPath,
+			Storage:   storage,
+			Data: map[string]interface{}{
+				"binddn":                     "tester",
+				"bindpass":                   "pa$$w0rd",
+				"url":                        "ldap://138.91.247.105",
+				"default_password_policy_dn": policyDN,
+			},
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%s resp:%#v\n", err, resp)
+		}
+
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      configPath,
+			Storage:   storage,
+			Data:      map[string]interface{}{"binddn": "newtester"},
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%s resp:%#v\n", err, resp)
+		}
+
+		config, err := readConfig(context.Background(), storage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.LDAP.DefaultPasswordPolicyDN != policyDN {
+			t.Fatalf("expected default_password_policy_dn to be %q after update, got %q",
+				policyDN, config.LDAP.DefaultPasswordPolicyDN)
+		}
+	})
 }
 
 func TestConfig_Delete(t *testing.T) {
@@ -5,18 +5,20 @@ package openldap
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/go-ldap/ldif"
+	"github.com/go-ldap/ldap/v3"
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/vault-plugin-secrets-openldap/client"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/helper/template"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/mitchellh/mapstructure"
-	"golang.org/x/text/encoding/unicode"
 )
 
 func (b *backend) pathDynamicCredsCreate() []*framework.Path {
@@ -33,10 +35,21 @@ func (b *backend) pathDynamicCredsCreate() []*framework.Path {
 					Type:        framework.TypeLowerCaseString,
 					Description: "Name of the dynamic role.",
 				},
+				"user_dn": {
+					Type: framework.TypeString,
+					Description: "DN of the existing user to add to the groups of a membership role without a user_dn_template. " +
+						"Must match one of the allowed_user_dns of the role.",
+				},
+				"params": {
+					Type:        framework.TypeKVPairs,
+					Description: "Values of the role's allowed_parameters, as key=value pairs. Parameters that take multiple values are comma separated.",
+				},
 			},
 			Operations: map[logical.Operation]framework.OperationHandler{
 				logical.ReadOperation: &framework.PathOperation{
-					Callback: b.pathDynamicCredsRead,
+					Callback:                    b.pathDynamicCredsRead,
+					ForwardPerformanceStandby:   true,
+					ForwardPerformanceSecondary: true,
 				},
 			},
 			HelpSynopsis: "Request LDAP credentials for a dynamic role. These credentials are " +
@@ -44,7 +57,8 @@ func (b *backend) pathDynamicCredsCreate() []*framework.Path {
 			HelpDescription: "This path requests new LDAP credentials for a certain dynamic role. " +
 				"The credentials are created within the LDAP system based on the creation_ldif " +
 				"specified within the dynamic role configuration. Depending on the LDAP implementation " +
-				"the credentials may not be immediately usable due to eventual consistency.",
+				"the credentials may not be immediately usable due to eventual consistency, unless the " +
+				"role sets verify_credentials.",
 		},
 	}
 }
@@ -69,49 +83,83 @@ func (b *backend) pathDynamicCredsRead(ctx context.Context, req *logical.Request
 		return nil, fmt.Errorf("missing LDAP configuration")
 	}
 
-	// Generate dynamic data
-	username, err := generateUsername(req, dRole)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate username: %w", err)
+	if dRole.roleType() == dynamicRoleTypeMembership {
+		return b.pathMembershipCredsRead(ctx, req, data, dRole, config)
 	}
-	password, err := b.GeneratePassword(ctx, config)
+	if _, ok := data.GetOk("user_dn"); ok {
+		return logical.ErrorResponse("user_dn is only supported by membership roles"), nil
+	}
+
+	entity, err := b.requestEntity(req)
 	if err != nil {
 		return nil, err
 	}
 
-	// Apply the template & execute
-	now := time.Now()
-	exp := now.Add(dRole.DefaultTTL)
-	templateData := dynamicTemplateData{
-		Username:              username,
-		Password:              password,
-		DisplayName:           req.DisplayName,
-		RoleName:              roleName,
-		IssueTime:             now.Format(time.RFC3339),
-		IssueTimeSeconds:      now.Unix(),
-		ExpirationTime:        exp.Format(time.RFC3339),
-		ExpirationTimeSeconds: exp.Unix(),
+	var supplied map[string]string
+	if raw, ok := data.GetOk("params"); ok {
+		supplied = raw.(map[string]string)
 	}
-	dns, err := b.executeLDIF(config.LDAP, dRole.CreationLDIF, templateData, false)
+	params, err := resolveParams(dRole.AllowedParameters, supplied)
 	if err != nil {
-		// Creation failed, attempt a rollback if one is specified
-		b.ldapEvent(ctx, "creds-create-fail", req.Path, roleName, false)
-		if dRole.RollbackLDIF == "" {
-			return nil, fmt.Errorf("failed to create user: %w", err)
-		}
+		return logical.ErrorResponse(err.Error()), nil
+	}
 
-		merr := multierror.Append(fmt.Errorf("failed to create user: %w", err))
-		_, err = b.executeLDIF(config.LDAP, dRole.RollbackLDIF, templateData, true)
+	// The key pair of ssh_key roles is kept across username retries.
+	var key *sshKeyPair
+	if dRole.credentialType() == credentialTypeSSHKey {
+		key, err = dRole.generateSSHKey()
 		if err != nil {
-			merr = multierror.Append(merr, fmt.Errorf("failed to roll back user creation: %w", err))
+			return nil, fmt.Errorf("failed to generate SSH key: %w", err)
 		}
-		return nil, merr
 	}
+
+	// Usernames are regenerated for the configured number of retries if
+	// creation fails because an entry already exists.
+	var retries int
+	var templateData dynamicTemplateData
+	var dns []string
+	var rendered map[string]string
+	var walID string
+	for {
+		var collided bool
+		templateData, dns, rendered, walID, collided, err = b.createDynamicUser(ctx, req, dRole, config, entity, params, key)
+		if err == nil {
+			break
+		}
+		if !collided || retries >= dRole.UsernameCollisionRetries {
+			metadata := []string{"retries", strconv.Itoa(retries)}
+			var createErr *creationError
+			if errors.As(err, &createErr) {
+				if report, jerr := json.Marshal(createErr.report); jerr == nil {
+					metadata = append(metadata, "creation_report", string(report))
+				}
+			}
+			b.ldapEvent(ctx, "creds-create-fail", req.Path, roleName, false, metadata...)
+			var quotaErr *quotaExceededError
+			if errors.As(err, &quotaErr) {
+				return logical.ErrorResponse(quotaErr.Error()), nil
+			}
+			return nil, err
+		}
+		retries++
+		b.Logger().Debug("username collision, retrying with a new username", "role", roleName, "retry", retries, "error", err)
+	}
+
 	respData := map[string]interface{}{
-		"username":            username,
-		"password":            password,
+		"username":            templateData.Username,
 		"distinguished_names": dns,
 	}
+	if dRole.generatesPassword() {
+		respData["password"] = templateData.Password
+	}
+	if key != nil {
+		respData["key_type"] = key.KeyType
+		respData["public_key"] = key.PublicKey
+		respData["private_key"] = key.PrivateKey
+	}
+	for name, output := range rendered {
+		respData[name] = output
+	}
 	internal := map[string]interface{}{
 		"name": roleName,
 		// Including the deletion_ldif in the event that the role is deleted while
@@ -123,12 +171,161 @@ func (b *backend) pathDynamicCredsRead(ctx context.Context, req *logical.Request
 	resp.Secret.TTL = dRole.DefaultTTL
 	resp.Secret.MaxTTL = dRole.MaxTTL
 
+	if err := b.commitDynamicCredsWAL(ctx, req.Storage, walID); err != nil {
+		return nil, err
+	}
+
 	// Send event notification for credentials creation
-	b.ldapEvent(ctx, "creds-create", req.Path, roleName, true)
+	b.ldapEvent(ctx, "creds-create", req.Path, roleName, true, "retries", strconv.Itoa(retries))
 
 	return resp, nil
 }
 
+// createDynamicUser generates a username and, if the role uses them, a
+// password, and runs the creation LDIF of the role with them and the public
+// key of ssh_key roles. The role's response templates are rendered up front
+// with the same data, so that they cannot fail once the user exists. If
+// creation failed because an entry or an issued credential record already
+// exists for the username, collided is true and the creation has been rolled
+// back, so that it can be retried with a new username. The WAL of the
+// created user is returned for the caller to commit once its response is
+// complete.
+func (b *backend) createDynamicUser(ctx context.Context, req *logical.Request, dRole *dynamicRole, config *config, entity templateEntity, params map[string]interface{}, key *sshKeyPair) (templateData dynamicTemplateData, dns []string, response map[string]string, walID string, collided bool, err error) {
+	username, err := generateUsername(req, dRole, entity)
+	if err != nil {
+		return templateData, nil, nil, "", false, fmt.Errorf("failed to generate username: %w", err)
+	}
+	var password string
+	if dRole.generatesPassword() {
+		password, err = b.GeneratePassword(ctx, config)
+		if err != nil {
+			return templateData, nil, nil, "", false, err
+		}
+	}
+	var publicKey string
+	if key != nil {
+		publicKey = key.PublicKey
+	}
+
+	// Apply the template & execute
+	now := time.Now()
+	exp := now.Add(dRole.DefaultTTL)
+	templateData = dynamicTemplateData{
+		Username:              username,
+		Password:              password,
+		PublicKey:             publicKey,
+		DisplayName:           req.DisplayName,
+		RoleName:              dRole.Name,
+		IssueTime:             now.Format(time.RFC3339),
+		IssueTimeSeconds:      now.Unix(),
+		ExpirationTime:        exp.Format(time.RFC3339),
+		ExpirationTimeSeconds: exp.Unix(),
+		Entity:                entity,
+		Params:                params,
+	}
+
+	// Render the creation LDIF up front so that its DNs are recorded in the
+	// issued credential index before anything is created.
+	creationLDIF, err := applyTemplate(dRole.CreationLDIF, templateData)
+	if err != nil {
+		return templateData, nil, nil, "", false, fmt.Errorf("failed to apply template: %w", err)
+	}
+	creationEntries, err := client.ParseLDIF(creationLDIF)
+	if err != nil {
+		return templateData, nil, nil, "", false, fmt.Errorf("failed to parse generated LDIF: %w", err)
+	}
+	dns = getDNs(creationEntries)
+	response, err = renderResponseTemplate(dRole.ResponseTemplate, entity, templateData)
+	if err != nil {
+		return templateData, nil, nil, "", false, err
+	}
+
+	// The record of a live credential with the same username must not be
+	// replaced, since it would be removed along with this attempt.
+	existing, err := retrieveIssuedCredential(ctx, req.Storage, dRole.Name, username)
+	if err != nil {
+		return templateData, nil, nil, "", false, fmt.Errorf("failed to read issued credential record: %w", err)
+	}
+	if existing != nil {
+		return templateData, nil, nil, "", true, fmt.Errorf("failed to create user: username %q has already been issued", username)
+	}
+
+	// Write a WAL with the cleanup templates before creating anything, so
+	// that entries created by a request that never results in a lease are
+	// removed by walRollback.
+	walID, err = b.putDynamicCredsWAL(ctx, req.Storage, dRole, templateData)
+	if err != nil {
+		return templateData, nil, nil, "", false, err
+	}
+
+	// The password is only recorded once it has been rotated.
+	recorded := templateData
+	recorded.Password = ""
+	err = b.recordIssuedCredential(ctx, req.Storage, dRole, &issuedCredential{
+		Username:           username,
+		DistinguishedNames: dns,
+		IssueTime:          now,
+		ExpireTime:         b.leaseExpiration(now, dRole),
+		EntityID:           entity.ID,
+		TemplateData:       &recorded,
+	})
+	if err != nil {
+		b.deleteDynamicCredsWAL(ctx, req.Storage, walID)
+		return templateData, nil, nil, "", false, err
+	}
+
+	report, err := b.client.Execute(config.LDAP, creationEntries, false)
+	if err != nil {
+		collided = ldap.IsErrorWithCode(err, ldap.LDAPResultEntryAlreadyExists)
+		createErr := &creationError{
+			err:    fmt.Errorf("failed to create user: failed to execute statements: %w", err),
+			report: report,
+		}
+
+		// Creation failed, attempt a rollback if one is specified
+		undone, err := b.undoCreation(ctx, req.Storage, config.LDAP, dRole, templateData, report, dRole.RollbackLDIF, walID, createErr)
+		return templateData, nil, nil, "", collided && undone, err
+	}
+
+	if dRole.VerifyCredentials {
+		if err := b.verifyDynamicCredential(ctx, config.LDAP, dRole, templateData, report); err != nil {
+			cleanupLDIF := dRole.RollbackLDIF
+			if cleanupLDIF == "" {
+				cleanupLDIF = dRole.DeletionLDIF
+			}
+			verifyErr := fmt.Errorf("failed to verify credentials: %w", err)
+			_, err = b.undoCreation(ctx, req.Storage, config.LDAP, dRole, templateData, report, cleanupLDIF, walID, verifyErr)
+			return templateData, nil, nil, "", false, err
+		}
+	}
+	return templateData, dns, response, walID, false, nil
+}
+
+// undoCreation runs the cleanup LDIF of a credential whose creation failed
+// with cause, and returns whether the cleanup succeeded along with the errors.
+// The cleanup LDIF is given the creation report so that it can undo only the
+// records that succeeded. If the cleanup fails, the WAL and index record are
+// left in place so that it is retried, with LDIF rendered without the report
+// that undoes everything. Without cleanup LDIF, only the records are removed.
+func (b *backend) undoCreation(ctx context.Context, s logical.Storage, conf *client.Config, dRole *dynamicRole, templateData dynamicTemplateData, report []client.ExecuteResult, cleanupLDIF, walID string, cause error) (bool, error) {
+	if cleanupLDIF == "" {
+		b.removeIssuedCredential(ctx, s, dRole.Name, templateData.Username)
+		b.deleteDynamicCredsWAL(ctx, s, walID)
+		return true, cause
+	}
+
+	merr := multierror.Append(cause)
+	cleanupData := templateData
+	cleanupData.CreationReport = report
+	if _, err := b.executeLDIF(conf, cleanupLDIF, cleanupData, true); err != nil {
+		merr = multierror.Append(merr, fmt.Errorf("failed to roll back user creation: %w", err))
+		return false, merr
+	}
+	b.removeIssuedCredential(ctx, s, dRole.Name, templateData.Username)
+	b.deleteDynamicCredsWAL(ctx, s, walID)
+	return true, merr
+}
+
 // executeLDIF applies the template data against the LDIF template & executes the LDIF statements against the LDAP
 // server. If more than one statement is specified within the LDIF string, this will result in multiple operations
 // against the LDAP server. This is due to the fact that LDAP does not have transactions, nor any other form of
@@ -140,37 +337,60 @@ func (b *backend) executeLDIF(config *client.Config, ldifTemplate string, templa
 	if err != nil {
 		return nil, fmt.Errorf("failed to apply template: %w", err)
 	}
+	return b.executeRenderedLDIF(config, rawLDIF, continueOnError)
+}
 
+// executeRenderedLDIF executes LDIF statements that have already been rendered
+// from a template. See executeLDIF.
+func (b *backend) executeRenderedLDIF(config *client.Config, rawLDIF string, continueOnError bool) (dns []string, err error) {
 	// Parse the raw LDIF & run it against the LDAP client
-	entries, err := ldif.Parse(rawLDIF)
+	entries, err := client.ParseLDIF(rawLDIF)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse generated LDIF: %w", err)
 	}
 
-	err = b.client.Execute(config, entries.Entries, continueOnError)
+	_, err = b.client.Execute(config, entries, continueOnError)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute statements: %w", err)
 	}
-	dns = getDNs(entries.Entries)
+	dns = getDNs(entries)
 	return dns, nil
 }
 
-func getDNs(entries []*ldif.Entry) []string {
+// creationError is returned when the creation LDIF of a dynamic role fails. It
+// reports the result of each record that ran, so that operators can tell
+// which entries were created.
+type creationError struct {
+	err    error
+	report []client.ExecuteResult
+}
+
+func (e *creationError) Error() string {
+	if len(e.report) == 0 {
+		return e.err.Error()
+	}
+	steps := make([]string, 0, len(e.report))
+	for _, result := range e.report {
+		steps = append(steps, result.String())
+	}
+	return fmt.Sprintf("%s; creation report: %s", e.err, strings.Join(steps, "; "))
+}
+
+func (e *creationError) Unwrap() error {
+	return e.err
+}
+
+// getDNs returns the DNs of the entries the LDIF records apply to. Records
+// that rename or move an entry return its new DN, so that the DNs are those of
+// the entries that exist once the LDIF has been executed.
+func getDNs(entries []*client.LDIFEntry) []string {
 	dns := make([]string, 0, len(entries))
 	for _, entry := range entries {
 		if entry == nil {
 			continue
 		}
-
-		switch {
-		case entry.Entry != nil:
-			dns = append(dns, entry.Entry.DN)
-		case entry.Add != nil:
-			dns = append(dns, entry.Add.DN)
-		case entry.Modify != nil:
-			dns = append(dns, entry.Modify.DN)
-		case entry.Del != nil:
-			dns = append(dns, entry.Del.DN)
+		if dn := entry.ResultDN(); dn != "" {
+			dns = append(dns, dn)
 		}
 	}
 	return dns
@@ -198,6 +418,24 @@ func (b *backend) secretCredsRenew() framework.OperationFunc {
 		secret.TTL = dRole.DefaultTTL
 		secret.MaxTTL = dRole.MaxTTL
 
+		// The index record holds the current password of rotated credentials,
+		// which the renewal_ldif must see, so renewals are serialized with
+		// rotations of the credential.
+		if templateData, err := decodeTemplateData(req.Secret.InternalData["template_data"]); err == nil && templateData.Username != "" {
+			lock := b.issuedCredentialLock(roleName, templateData.Username)
+			lock.Lock()
+			defer lock.Unlock()
+		}
+		if err := b.renewIssuedCredential(ctx, req, dRole); err != nil {
+			return nil, err
+		}
+
+		if dRole.RenewalLDIF != "" {
+			if err := b.executeRenewalLDIF(ctx, req, dRole); err != nil {
+				return nil, err
+			}
+		}
+
 		resp := &logical.Response{
 			Secret: req.Secret,
 		}
@@ -215,6 +453,10 @@ func (b *backend) secretCredsRevoke() framework.OperationFunc {
 			return nil, fmt.Errorf("missing LDAP configuration")
 		}
 
+		if leaseType, _ := getString(req.Secret.InternalData, "type"); leaseType == dynamicRoleTypeMembership {
+			return nil, b.revokeMemberships(ctx, req)
+		}
+
 		deletionTemplate, err := getString(req.Secret.InternalData, "deletion_ldif")
 		if err != nil {
 			return nil, fmt.Errorf("broken internal data: unable to retrieve deletion_ldif: %w", err)
@@ -224,39 +466,121 @@ func (b *backend) secretCredsRevoke() framework.OperationFunc {
 			return nil, fmt.Errorf("broken internal data: missing deletion_ldif")
 		}
 
-		var templateData dynamicTemplateData
-		rawTemplateData := req.Secret.InternalData["template_data"]
-		switch td := rawTemplateData.(type) {
-		case dynamicTemplateData:
-			templateData = td
-		case map[string]interface{}:
-			err := mapstructure.WeakDecode(td, &templateData)
-			if err != nil {
-				return nil, fmt.Errorf("unable to decode internal data: %w", err)
-			}
-		default:
-			return nil, fmt.Errorf("unable to revoke LDAP dynamic credentials: unrecognized internal data type: %T", td)
+		templateData, err := decodeTemplateData(req.Secret.InternalData["template_data"])
+		if err != nil {
+			return nil, fmt.Errorf("unable to revoke LDAP dynamic credentials: %w", err)
+		}
+
+		roleName, _ := getString(req.Secret.InternalData, "name")
+		cred, err := retrieveIssuedCredential(ctx, req.Storage, roleName, templateData.Username)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read issued credential record: %w", err)
+		}
+		if cred != nil {
+			cred.applyRotatedPassword(&templateData)
 		}
 
 		_, err = b.executeLDIF(config.LDAP, deletionTemplate, templateData, true)
-		return nil, err
+		if err != nil {
+			// Vault eventually stops retrying the revocation of a lease, so the
+			// deletion is queued and retried by the plugin instead.
+			if qerr := queueRevocation(ctx, req.Storage, roleName, deletionTemplate, templateData, err); qerr != nil {
+				return nil, multierror.Append(err, fmt.Errorf("failed to queue deletion for retry: %w", qerr))
+			}
+			b.Logger().Warn("failed to delete dynamic credential, queued for retry", "role", roleName, "username", templateData.Username, "error", err)
+			return nil, nil
+		}
+
+		b.removeIssuedCredential(ctx, req.Storage, roleName, templateData.Username)
+		return nil, nil
 	}
 }
 
+// executeRenewalLDIF runs the renewal_ldif of the given role with the
+// expiration recomputed for the renewed lease, and records the new expiration
+// in the lease's template data.
+func (b *backend) executeRenewalLDIF(ctx context.Context, req *logical.Request, dRole *dynamicRole) error {
+	config, err := readConfig(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		return fmt.Errorf("missing LDAP configuration")
+	}
+
+	templateData, err := decodeTemplateData(req.Secret.InternalData["template_data"])
+	if err != nil {
+		return fmt.Errorf("unable to renew LDAP dynamic credentials: %w", err)
+	}
+
+	exp := b.leaseExpiration(req.Secret.IssueTime, dRole)
+	templateData.ExpirationTime = exp.Format(time.RFC3339)
+	templateData.ExpirationTimeSeconds = exp.Unix()
+
+	if _, err := b.executeLDIF(config.LDAP, dRole.RenewalLDIF, templateData, false); err != nil {
+		return fmt.Errorf("failed to execute renewal_ldif: %w", err)
+	}
+	req.Secret.InternalData["template_data"] = templateData
+	return nil
+}
+
+// leaseExpiration returns the time at which a lease issued at issueTime
+// expires if it is issued or renewed now with the role's TTLs, capped by its
+// max TTL from the issue time.
+func (b *backend) leaseExpiration(issueTime time.Time, dRole *dynamicRole) time.Time {
+	ttl := dRole.DefaultTTL
+	maxTTL := dRole.MaxTTL
+	if sys := b.System(); sys != nil {
+		if ttl == 0 {
+			ttl = sys.DefaultLeaseTTL()
+		}
+		if maxTTL == 0 {
+			maxTTL = sys.MaxLeaseTTL()
+		}
+	}
+
+	exp := time.Now().Add(ttl)
+	if !issueTime.IsZero() && maxTTL > 0 {
+		if maxExp := issueTime.Add(maxTTL); maxExp.Before(exp) {
+			exp = maxExp
+		}
+	}
+	return exp
+}
+
+// decodeTemplateData returns the template data stored in a lease's internal
+// data.
+func decodeTemplateData(rawTemplateData interface{}) (dynamicTemplateData, error) {
+	var templateData dynamicTemplateData
+	switch td := rawTemplateData.(type) {
+	case dynamicTemplateData:
+		templateData = td
+	case map[string]interface{}:
+		err := mapstructure.WeakDecode(td, &templateData)
+		if err != nil {
+			return templateData, fmt.Errorf("unable to decode internal data: %w", err)
+		}
+	default:
+		return templateData, fmt.Errorf("unrecognized internal data type: %T", td)
+	}
+	return templateData, nil
+}
+
 type usernameTemplateData struct {
 	DisplayName string
 	RoleName    string
+	Entity      templateEntity
 }
 
 const defaultUsernameTemplate = "v_{{.DisplayName}}_{{.RoleName}}_{{random 10}}_{{unix_time}}"
 
-func generateUsername(req *logical.Request, role *dynamicRole) (string, error) {
+func generateUsername(req *logical.Request, role *dynamicRole, entity templateEntity) (string, error) {
 	usernameTemplate := role.UsernameTemplate
 	if role.UsernameTemplate == "" {
 		usernameTemplate = defaultUsernameTemplate
 	}
 	tmpl, err := template.NewTemplate(
-		template.Template(usernameTemplate),
+		append(templateFunctions(entity), template.Template(usernameTemplate))...,
 	)
 	if err != nil {
 		return "", err
@@ -264,6 +588,7 @@ func generateUsername(req *logical.Request, role *dynamicRole) (string, error) {
 	usernameData := usernameTemplateData{
 		DisplayName: req.DisplayName,
 		RoleName:    role.Name,
+		Entity:      entity,
 	}
 	return tmpl.Generate(usernameData)
 }
@@ -271,33 +596,56 @@ func generateUsername(req *logical.Request, role *dynamicRole) (string, error) {
 type dynamicTemplateData struct {
 	Username              string
 	Password              string
+	PublicKey             string `json:",omitempty"`
 	DisplayName           string
 	RoleName              string
 	IssueTime             string
 	IssueTimeSeconds      int64
 	ExpirationTime        string
 	ExpirationTimeSeconds int64
+
+	// Entity is the identity of the requester.
+	Entity templateEntity
+
+	// EntityMetadata is the metadata of the requesting entity recorded by
+	// leases issued before Entity was added. entity_meta falls back to it.
+	EntityMetadata map[string]string `json:",omitempty"`
+
+	// Params are the DN escaped allowed_parameters of the role, as supplied
+	// by the caller or defaulted. Values are strings, or string lists for
+	// parameters that take multiple values.
+	Params map[string]interface{} `json:",omitempty"`
+
+	// CreationReport is the result of each creation LDIF record that ran,
+	// available to the rollback LDIF of a failed creation.
+	CreationReport []client.ExecuteResult `json:",omitempty"`
 }
 
-func applyTemplate(rawTemplate string, data dynamicTemplateData) (string, error) {
-	tmpl, err := template.NewTemplate(
-		template.Template(rawTemplate),
-		template.Function("utf16le", encodeUTF16LE),
-	)
-	if err != nil {
-		return "", fmt.Errorf("failed to parse template: %w", err)
+// Succeeded reports whether the creation LDIF records for the DN succeeded, so
+// that rollback LDIF can undo only the steps that ran:
+//
+//	{{if .Succeeded "cn=group,dc=example,dc=com"}}...{{end}}
+//
+// Without a creation report, such as when a rollback is retried from a WAL,
+// every record may have run, and Succeeded is true.
+func (d dynamicTemplateData) Succeeded(dn string) bool {
+	if d.CreationReport == nil {
+		return true
 	}
-	str, err := tmpl.Generate(data)
-	if err != nil {
-		return "", fmt.Errorf("failed to execute template: %w", err)
+	for _, result := range d.CreationReport {
+		if result.Success && strings.EqualFold(result.DN, dn) {
+			return true
+		}
 	}
-
-	return str, nil
+	return false
 }
 
-func encodeUTF16LE(str string) (string, error) {
-	enc := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewEncoder()
-	return enc.String(str)
+func applyTemplate(rawTemplate string, data dynamicTemplateData) (string, error) {
+	entity := data.Entity
+	if entity.Metadata == nil {
+		entity.Metadata = data.EntityMetadata
+	}
+	return renderTemplate(rawTemplate, entity, data)
 }
 
 func getString(m map[string]interface{}, key string) (string, error) {
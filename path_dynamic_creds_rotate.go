@@ -0,0 +1,168 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func (b *backend) pathDynamicCredsRotate() []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: strings.TrimSuffix(dynamicCredPath, "/") + genericNameWithForwardSlashRegex("name") + "/rotate$",
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: operationPrefixLDAP,
+				OperationVerb:   "rotate",
+				OperationSuffix: "dynamic-role-credentials",
+			},
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeLowerCaseString,
+					Description: "Name of the dynamic role.",
+					Required:    true,
+				},
+				"username": {
+					Type:        framework.TypeString,
+					Description: "Username of the credential to rotate. Either username or lease_id is required.",
+				},
+				"lease_id": {
+					Type: framework.TypeString,
+					Description: "ID of the lease of the credential to rotate. Only leases that have been renewed " +
+						"can be found by ID, since the plugin does not learn lease IDs until then. Either username " +
+						"or lease_id is required.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback:                    b.pathDynamicCredsRotateUpdate,
+					ForwardPerformanceStandby:   true,
+					ForwardPerformanceSecondary: true,
+				},
+			},
+			HelpSynopsis: "Rotate the password of an active dynamic credential.",
+			HelpDescription: "This path sets a new password for an active credential of the role with the " +
+				"role's rotation_ldif and returns it. The credential is found in the role's issued credentials " +
+				"by its username, or by the ID of its lease, which the plugin records when the lease is first " +
+				"renewed. Credentials whose leases have never been renewed are rotated by username. The lease " +
+				"is not revoked, and LDIF run for the lease later, such as its renewal_ldif and deletion_ldif, " +
+				"sees the new password.",
+		},
+	}
+}
+
+func (b *backend) pathDynamicCredsRotateUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	roleName := data.Get("name").(string)
+	username := data.Get("username").(string)
+	leaseID := data.Get("lease_id").(string)
+
+	dRole, err := retrieveDynamicRole(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve dynamic role: %w", err)
+	}
+	if dRole == nil {
+		return nil, nil
+	}
+	if dRole.RotationLDIF == "" {
+		return logical.ErrorResponse("role %q has no rotation_ldif", roleName), nil
+	}
+	if !dRole.generatesPassword() {
+		return logical.ErrorResponse("role %q issues credentials without a password", roleName), nil
+	}
+
+	switch {
+	case username == "" && leaseID == "":
+		return logical.ErrorResponse("missing username or lease_id"), nil
+	case username != "" && leaseID != "":
+		return logical.ErrorResponse("only one of username or lease_id can be supplied"), nil
+	}
+
+	// Lease IDs are the request path followed by a random ID, so leases of
+	// roles nested under this role's name are rejected too.
+	if leaseID != "" {
+		leaseSuffix, ok := strings.CutPrefix(strings.TrimPrefix(leaseID, req.MountPoint), dynamicCredPath+roleName+"/")
+		if !ok || leaseSuffix == "" || strings.Contains(leaseSuffix, "/") {
+			return logical.ErrorResponse("lease %q was not issued by role %q", leaseID, roleName), nil
+		}
+	}
+
+	config, err := readConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, fmt.Errorf("missing LDAP configuration")
+	}
+
+	if leaseID != "" {
+		cred, err := findIssuedCredentialByLease(ctx, req.Storage, roleName, leaseID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read issued credentials: %w", err)
+		}
+		if cred == nil {
+			return logical.ErrorResponse("lease %q has not been renewed since it was issued, rotate its credential by username instead", leaseID), nil
+		}
+		username = cred.Username
+	}
+
+	lock := b.issuedCredentialLock(roleName, username)
+	lock.Lock()
+	defer lock.Unlock()
+
+	// The record is read under the lock, as it may have been removed or
+	// renewed in the meantime.
+	cred, err := retrieveIssuedCredential(ctx, req.Storage, roleName, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read issued credential record: %w", err)
+	}
+	if cred == nil || (leaseID != "" && cred.LeaseID != leaseID) {
+		return logical.ErrorResponse("no active credential with username %q was issued by role %q", username, roleName), nil
+	}
+
+	now := time.Now()
+	if !cred.live(now) {
+		return logical.ErrorResponse("credential %q has expired", cred.Username), nil
+	}
+	if cred.TemplateData == nil {
+		return logical.ErrorResponse("credential %q was issued before password rotation was supported", cred.Username), nil
+	}
+
+	password, err := b.GeneratePassword(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	templateData := *cred.TemplateData
+	templateData.Password = password
+
+	dns, err := b.executeLDIF(config.LDAP, dRole.RotationLDIF, templateData, false)
+	if err != nil {
+		b.ldapEvent(ctx, "creds-rotate-fail", req.Path, roleName, false)
+		return nil, fmt.Errorf("failed to rotate password: %w", err)
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"username":            cred.Username,
+			"password":            password,
+			"distinguished_names": dns,
+			"lease_id":            cred.LeaseID,
+		},
+	}
+
+	cred.TemplateData = &templateData
+	cred.LastPasswordRotation = now
+	if err := storeIssuedCredential(ctx, req.Storage, roleName, cred); err != nil {
+		// The password has been changed, so it is returned regardless.
+		b.Logger().Error("failed to record rotated password", "role", roleName, "username", cred.Username, "error", err)
+		resp.AddWarning("The new password could not be recorded, so LDIF run for the lease later sees the previous password.")
+	}
+
+	b.ldapEvent(ctx, "creds-rotate", req.Path, roleName, true)
+	return resp, nil
+}
@@ -0,0 +1,197 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault-plugin-secrets-openldap/client"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+const ldifRotationTemplate = `dn: cn={{.Username}},ou=users,dc=hashicorp,dc=com
+changetype: modify
+replace: userPassword
+userPassword: {{.Password}}
+-`
+
+// modifiedPassword returns the userPassword set by the given LDIF entries.
+func modifiedPassword(t *testing.T, entries []*client.LDIFEntry) string {
+	t.Helper()
+	require.Len(t, entries, 1)
+	require.NotNil(t, entries[0].Modify)
+	return entries[0].Modify.Changes[0].Modification.Vals[0]
+}
+
+func TestDynamicCredsRotate(t *testing.T) {
+	ctx := context.Background()
+	b, storage := getBackend(false)
+	defer b.Cleanup(ctx)
+	configureOpenLDAPMount(t, b, storage)
+
+	data := getTestDynamicRoleConfig("hashicorp")
+	data["rotation_ldif"] = ldifRotationTemplate
+	data["renewal_ldif"] = ldifRotationTemplate
+	resp, err := createDynamicRoleWithData(t, b, storage, "hashicorp", data)
+	require.NoError(t, err)
+	require.False(t, resp != nil && resp.IsError())
+
+	recorder := &executeRecordingClient{}
+	b.client = recorder
+
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      dynamicCredPath + "hashicorp",
+		Storage:   storage,
+	})
+	require.NoError(t, err)
+	username := resp.Data["username"].(string)
+	password := resp.Data["password"].(string)
+	secret := resp.Secret
+	secret.LeaseID = "creds/hashicorp/8E2mbxoRJT1BdsFyLmCCpE0u"
+
+	rotate := func(data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      dynamicCredPath + "hashicorp/rotate",
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+
+	renew := func(t *testing.T) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.RenewOperation,
+			Storage:   storage,
+			Secret:    secret,
+		})
+		require.NoError(t, err)
+		return resp
+	}
+
+	t.Run("unrenewed lease is not found by ID", func(t *testing.T) {
+		resp, err := rotate(map[string]interface{}{"lease_id": secret.LeaseID})
+		require.NoError(t, err)
+		require.ErrorContains(t, resp.Error(), "rotate its credential by username instead")
+	})
+
+	t.Run("unrenewed credential is rotated by username", func(t *testing.T) {
+		resp, err := rotate(map[string]interface{}{"username": username})
+		require.NoError(t, err)
+		require.False(t, resp.IsError(), "unexpected error response: %v", resp)
+		require.Equal(t, username, resp.Data["username"])
+		require.Equal(t, "", resp.Data["lease_id"])
+		require.NotEqual(t, password, resp.Data["password"])
+		require.Equal(t, resp.Data["password"], modifiedPassword(t, recorder.entries[len(recorder.entries)-1]))
+		password = resp.Data["password"].(string)
+
+		resp, err = rotate(map[string]interface{}{"username": "v-unknown"})
+		require.NoError(t, err)
+		require.ErrorContains(t, resp.Error(), "no active credential")
+
+		resp, err = rotate(map[string]interface{}{"username": username, "lease_id": secret.LeaseID})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("lease of another role", func(t *testing.T) {
+		resp, err := rotate(map[string]interface{}{
+			"lease_id": "creds/hashicorp/nested/8E2mbxoRJT1BdsFyLmCCpE0u",
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("rotate", func(t *testing.T) {
+		renew(t)
+
+		resp, err := rotate(map[string]interface{}{"lease_id": secret.LeaseID})
+		require.NoError(t, err)
+		require.False(t, resp.IsError(), "unexpected error response: %v", resp)
+		require.Equal(t, username, resp.Data["username"])
+		require.Equal(t, secret.LeaseID, resp.Data["lease_id"])
+		require.NotEqual(t, password, resp.Data["password"])
+		require.Equal(t, resp.Data["password"], modifiedPassword(t, recorder.entries[len(recorder.entries)-1]))
+		password = resp.Data["password"].(string)
+
+		// Other leases of the role cannot claim the credential.
+		resp, err = rotate(map[string]interface{}{"lease_id": "creds/hashicorp/0ther"})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+
+		resp, err = rotate(map[string]interface{}{"lease_id": secret.LeaseID})
+		require.NoError(t, err)
+		require.False(t, resp.IsError(), "unexpected error response: %v", resp)
+		require.NotEqual(t, password, resp.Data["password"])
+		password = resp.Data["password"].(string)
+	})
+
+	t.Run("renewal sees the rotated password", func(t *testing.T) {
+		resp := renew(t)
+		require.Equal(t, password, modifiedPassword(t, recorder.entries[len(recorder.entries)-1]))
+
+		templateData, err := decodeTemplateData(resp.Secret.InternalData["template_data"])
+		require.NoError(t, err)
+		require.Equal(t, password, templateData.Password)
+	})
+
+	t.Run("role without rotation_ldif", func(t *testing.T) {
+		resp, err := createDynamicRoleWithData(t, b, storage, "plain", getTestDynamicRoleConfig("plain"))
+		require.NoError(t, err)
+		require.False(t, resp != nil && resp.IsError())
+
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      dynamicCredPath + "plain/rotate",
+			Storage:   storage,
+			Data:      map[string]interface{}{"lease_id": "creds/plain/8E2mbxoRJT1BdsFyLmCCpE0u"},
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+}
+
+func TestDynamicCredsRotate_leaseSeenOnRenewal(t *testing.T) {
+	ctx := context.Background()
+	b, storage := getBackend(false)
+	defer b.Cleanup(ctx)
+	configureOpenLDAPMount(t, b, storage)
+
+	data := getTestDynamicRoleConfig("hashicorp")
+	data["rotation_ldif"] = ldifRotationTemplate
+	resp, err := createDynamicRoleWithData(t, b, storage, "hashicorp", data)
+	require.NoError(t, err)
+	require.False(t, resp != nil && resp.IsError())
+	b.client = &executeRecordingClient{}
+
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      dynamicCredPath + "hashicorp",
+		Storage:   storage,
+	})
+	require.NoError(t, err)
+	secret := resp.Secret
+	secret.LeaseID = "ldap/creds/hashicorp/8E2mbxoRJT1BdsFyLmCCpE0u"
+
+	_, err = b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.RenewOperation,
+		Storage:   storage,
+		Secret:    secret,
+	})
+	require.NoError(t, err)
+
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Operation:  logical.UpdateOperation,
+		Path:       dynamicCredPath + "hashicorp/rotate",
+		Storage:    storage,
+		MountPoint: "ldap/",
+		Data:       map[string]interface{}{"lease_id": secret.LeaseID},
+	})
+	require.NoError(t, err)
+	require.False(t, resp.IsError(), "unexpected error response: %v", resp)
+	require.Equal(t, secret.LeaseID, resp.Data["lease_id"])
+}
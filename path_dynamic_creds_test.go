@@ -5,14 +5,19 @@ package openldap
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"path"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/go-ldap/ldap/v3"
 	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault-plugin-secrets-openldap/client"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/helper/logging"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -151,11 +156,13 @@ func TestDynamicCredsRead_failures(t *testing.T) {
 		storage.On("Get", mock.Anything, configPath).
 			Return(configStorageResp, nil).
 			Once()
+		expectDynamicCredsWAL(storage)
+		expectIssuedCredential(storage, true)
 		defer storage.AssertExpectations(t)
 
 		client := new(mockLDAPClient)
 		client.On("Execute", mock.Anything, mock.Anything, mock.Anything).
-			Return(fmt.Errorf("test error")).
+			Return(nil, fmt.Errorf("test error")).
 			Once()
 
 		b := Backend(client)
@@ -176,6 +183,455 @@ func TestDynamicCredsRead_failures(t *testing.T) {
 	})
 }
 
+// expectIssuedCredential sets up the storage calls that record a dynamic
+// credential in the issued credential index and the active credential
+// counts and, if removed is set, delete the record again. The mock doesn't
+// return stored records, so removal doesn't update the counts.
+func expectIssuedCredential(storage *mockStorage, removed bool) {
+	indexGets := 1
+	if removed {
+		indexGets = 2
+	}
+	storage.On("Get", mock.Anything, mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, dynamicCredsIndexPath)
+	})).
+		Return((*logical.StorageEntry)(nil), nil).
+		Times(indexGets)
+	storage.On("Get", mock.Anything, mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, activeCredentialsPath)
+	})).
+		Return((*logical.StorageEntry)(nil), nil).
+		Once()
+	storage.On("Put", mock.Anything, mock.MatchedBy(func(entry *logical.StorageEntry) bool {
+		return strings.HasPrefix(entry.Key, dynamicCredsIndexPath) || strings.HasPrefix(entry.Key, activeCredentialsPath)
+	})).
+		Return(error(nil)).
+		Twice()
+	if removed {
+		storage.On("Delete", mock.Anything, mock.MatchedBy(func(key string) bool {
+			return strings.HasPrefix(key, dynamicCredsIndexPath)
+		})).
+			Return(error(nil)).
+			Once()
+	}
+}
+
+// expectDynamicCredsWAL sets up the storage calls that write and then delete
+// the WAL of a dynamic credential.
+func expectDynamicCredsWAL(storage *mockStorage) {
+	storage.On("Put", mock.Anything, mock.MatchedBy(func(entry *logical.StorageEntry) bool {
+		return strings.HasPrefix(entry.Key, framework.WALPrefix)
+	})).
+		Return(error(nil)).
+		Once()
+	storage.On("Delete", mock.Anything, mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, framework.WALPrefix)
+	})).
+		Return(error(nil)).
+		Once()
+}
+
+func TestDynamicCredsRead_usernameCollision(t *testing.T) {
+	ctx := context.Background()
+	entryExists := ldap.NewError(ldap.LDAPResultEntryAlreadyExists, errors.New("entry already exists"))
+
+	type testCase struct {
+		retries int
+		// results are returned by the creation LDIF on each attempt.
+		results       []error
+		expectErr     bool
+		expectCreates int
+	}
+
+	tests := map[string]testCase{
+		"retried after a collision": {
+			retries:       2,
+			results:       []error{entryExists},
+			expectCreates: 2,
+		},
+		"no retries by default": {
+			results:       []error{entryExists},
+			expectErr:     true,
+			expectCreates: 1,
+		},
+		"retries exhausted": {
+			retries:       1,
+			results:       []error{entryExists, entryExists},
+			expectErr:     true,
+			expectCreates: 2,
+		},
+		"other errors are not retried": {
+			retries:       2,
+			results:       []error{errors.New("insufficient access")},
+			expectErr:     true,
+			expectCreates: 1,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := testBackendConfig()
+			eventSender := logical.NewMockEventSender()
+			config.EventsSender = eventSender
+			b, config := getBackendWithConfig(config, false)
+			storage := config.StorageView
+			defer b.Cleanup(ctx)
+			configureOpenLDAPMount(t, b, storage)
+
+			data := getTestDynamicRoleConfig("hashicorp")
+			data["username_collision_retries"] = test.retries
+			resp, err := createDynamicRoleWithData(t, b, storage, "hashicorp", data)
+			require.NoError(t, err)
+			require.False(t, resp != nil && resp.IsError())
+
+			var creates int
+			recorder := &executeRecordingClient{}
+			recorder.onExecute = func() error {
+				entries := recorder.entries[len(recorder.entries)-1]
+				if entries[0].Entry == nil {
+					// rollback_ldif
+					return nil
+				}
+				creates++
+				if creates <= len(test.results) {
+					return test.results[creates-1]
+				}
+				return nil
+			}
+			b.client = recorder
+
+			resp, err = b.HandleRequest(ctx, &logical.Request{
+				Operation: logical.ReadOperation,
+				Path:      dynamicCredPath + "hashicorp",
+				Storage:   storage,
+			})
+			require.Equal(t, test.expectCreates, creates)
+			// Every failed creation is rolled back.
+			require.Len(t, recorder.executed, test.expectCreates+len(test.results))
+
+			// The retries are reported in the metadata of the creation event.
+			event := eventSender.Events[len(eventSender.Events)-1]
+			require.Equal(t, strconv.Itoa(test.expectCreates-1), event.Event.Metadata.AsMap()["retries"])
+
+			creds, listErr := listIssuedCredentials(ctx, storage, "hashicorp")
+			require.NoError(t, listErr)
+			walIDs, listErr := framework.ListWAL(ctx, storage)
+			require.NoError(t, listErr)
+			require.Empty(t, walIDs)
+
+			if test.expectErr {
+				require.Error(t, err)
+				require.Empty(t, creds)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, creds, 1)
+			require.Equal(t, resp.Data["username"], creds[0].Username)
+			require.Equal(t, []string{"cn=" + creds[0].Username + ",ou=users,dc=hashicorp,dc=com"}, recorder.executed[len(recorder.executed)-1])
+			require.NotEqual(t, recorder.executed[0], recorder.executed[len(recorder.executed)-1])
+		})
+	}
+
+	t.Run("negative retries", func(t *testing.T) {
+		b, storage := getBackend(false)
+		defer b.Cleanup(ctx)
+
+		data := getTestDynamicRoleConfig("hashicorp")
+		data["username_collision_retries"] = -1
+		_, err := createDynamicRoleWithData(t, b, storage, "hashicorp", data)
+		require.Error(t, err)
+	})
+
+	t.Run("issued username is not reused", func(t *testing.T) {
+		b, storage := getBackend(false)
+		defer b.Cleanup(ctx)
+		configureOpenLDAPMount(t, b, storage)
+
+		data := getTestDynamicRoleConfig("hashicorp")
+		data["username_template"] = "v-{{.RoleName}}"
+		data["username_collision_retries"] = 3
+		resp, err := createDynamicRoleWithData(t, b, storage, "hashicorp", data)
+		require.NoError(t, err)
+		require.False(t, resp != nil && resp.IsError())
+
+		recorder := &executeRecordingClient{}
+		b.client = recorder
+		req := &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      dynamicCredPath + "hashicorp",
+			Storage:   storage,
+		}
+		_, err = b.HandleRequest(ctx, req)
+		require.NoError(t, err)
+
+		_, err = b.HandleRequest(ctx, req)
+		require.Error(t, err)
+		require.Len(t, recorder.executed, 1)
+
+		cred, err := retrieveIssuedCredential(ctx, storage, "hashicorp", "v-hashicorp")
+		require.NoError(t, err)
+		require.NotNil(t, cred)
+	})
+}
+
+func TestDynamicCredsRead_params(t *testing.T) {
+	ctx := context.Background()
+	b, storage := getBackend(false)
+	defer b.Cleanup(ctx)
+	configureOpenLDAPMount(t, b, storage)
+
+	resp, err := createDynamicRoleWithData(t, b, storage, "dev-user", map[string]interface{}{
+		"creation_ldif": `dn: cn={{.Username}},ou={{.Params.team}},dc=hashicorp,dc=com
+objectClass: person
+objectClass: top
+cn: learn
+sn: learn
+userPassword: {{.Password}}`,
+		"deletion_ldif": `dn: cn={{.Username}},ou={{.Params.team}},dc=hashicorp,dc=com
+changetype: delete`,
+		"allowed_parameters": []interface{}{
+			map[string]interface{}{
+				"name":    "team",
+				"pattern": "[a-z-]+",
+				"default": "platform",
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.False(t, resp != nil && resp.IsError(), "unexpected error response: %v", resp)
+
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      dynamicRolePath + "dev-user",
+		Storage:   storage,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []map[string]interface{}{
+		{"name": "team", "pattern": "[a-z-]+", "multiple": false, "default": "platform"},
+	}, resp.Data["allowed_parameters"])
+
+	recorder := &executeRecordingClient{}
+	b.client = recorder
+	requestCreds := func(params interface{}) (*logical.Response, error) {
+		req := &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      dynamicCredPath + "dev-user",
+			Storage:   storage,
+		}
+		if params != nil {
+			req.Data = map[string]interface{}{"params": params}
+		}
+		return b.HandleRequest(ctx, req)
+	}
+
+	t.Run("default", func(t *testing.T) {
+		resp, err := requestCreds(nil)
+		require.NoError(t, err)
+		require.False(t, resp.IsError(), "unexpected error response: %v", resp)
+		require.Equal(t, []string{"cn=" + resp.Data["username"].(string) + ",ou=platform,dc=hashicorp,dc=com"}, resp.Data["distinguished_names"])
+	})
+
+	t.Run("supplied and kept for revocation", func(t *testing.T) {
+		resp, err := requestCreds([]string{"team=site-reliability"})
+		require.NoError(t, err)
+		require.False(t, resp.IsError(), "unexpected error response: %v", resp)
+		dn := "cn=" + resp.Data["username"].(string) + ",ou=site-reliability,dc=hashicorp,dc=com"
+		require.Equal(t, []string{dn}, resp.Data["distinguished_names"])
+
+		// Revoke with internal data as it is read back from storage.
+		secret := resp.Secret
+		raw, err := json.Marshal(secret.InternalData)
+		require.NoError(t, err)
+		secret.InternalData = nil
+		require.NoError(t, json.Unmarshal(raw, &secret.InternalData))
+
+		_, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.RevokeOperation,
+			Storage:   storage,
+			Secret:    secret,
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{dn}, recorder.executed[len(recorder.executed)-1])
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		executed := len(recorder.executed)
+		for _, params := range []interface{}{
+			map[string]interface{}{"team": "admins,dc=hashicorp"},
+			map[string]interface{}{"ou": "admins"},
+		} {
+			resp, err := requestCreds(params)
+			require.NoError(t, err)
+			require.True(t, resp.IsError())
+		}
+		require.Len(t, recorder.executed, executed)
+	})
+}
+
+func TestDynamicCredsRead_entity(t *testing.T) {
+	ctx := context.Background()
+	config := testBackendConfig()
+	sv := config.System.(testSystemView)
+	sv.EntityVal = &logical.Entity{
+		ID:   "entity-id",
+		Name: "JDoe",
+		Metadata: map[string]string{
+			"mail":       "jdoe@example.com",
+			"department": "Platform\nuserPassword: injected",
+			"manager":    "Jane Smith, PhD",
+		},
+		Aliases: []*logical.Alias{
+			{Name: "jdoe@corp", MountAccessor: "auth_oidc_1234", MountType: "oidc"},
+		},
+	}
+	sv.GroupsVal = []*logical.Group{{Name: "ops"}, {Name: "dev"}}
+	config.System = sv
+	b, _ := getBackendWithConfig(config, false)
+	storage := config.StorageView
+	defer b.Cleanup(ctx)
+	configureOpenLDAPMount(t, b, storage)
+
+	resp, err := createDynamicRoleWithData(t, b, storage, "employee", map[string]interface{}{
+		"username_template": `v_{{.Entity.Name | lower}}_{{entity_alias "oidc" | truncate 4}}_{{random 4}}`,
+		"creation_ldif": `dn: cn={{.Username}},ou=users,dc=hashicorp,dc=com
+objectClass: person
+objectClass: top
+cn: {{.Entity.Name}}
+sn: {{.Entity.Name}}
+mail: {{entity_meta "mail"}}
+description:: {{printf "%s, managed by %s" .Entity.Metadata.department (entity_meta "manager") | b64enc}}
+{{- range .Entity.Groups}}
+businessCategory: {{.}}
+{{- end}}
+userPassword: {{.Password}}`,
+		"deletion_ldif": `dn: cn={{.Username}},ou=users,dc=hashicorp,dc=com
+changetype: delete`,
+	})
+	require.NoError(t, err)
+	require.False(t, resp != nil && resp.IsError(), "unexpected error response: %v", resp)
+
+	recorder := &executeRecordingClient{}
+	b.client = recorder
+
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      dynamicCredPath + "employee",
+		Storage:   storage,
+		EntityID:  "entity-id",
+	})
+	require.NoError(t, err)
+	require.False(t, resp.IsError(), "unexpected error response: %v", resp)
+	require.Regexp(t, `^v_jdoe_jdoe_[a-zA-Z0-9]{4}$`, resp.Data["username"])
+
+	entries := recorder.entries[len(recorder.entries)-1]
+	require.Len(t, entries, 1)
+	attributes := map[string][]string{}
+	for _, attr := range entries[0].Entry.Attributes {
+		attributes[attr.Name] = attr.Values
+	}
+	require.Equal(t, []string{"jdoe@example.com"}, attributes["mail"])
+	require.Equal(t, []string{"PlatformuserPassword: injected, managed by Jane Smith, PhD"}, attributes["description"])
+	require.Equal(t, []string{"dev", "ops"}, attributes["businessCategory"])
+	require.Len(t, attributes["userPassword"], 1)
+
+	// The entity is kept with the lease for renewal and deletion.
+	raw, err := json.Marshal(resp.Secret.InternalData)
+	require.NoError(t, err)
+	var internalData map[string]interface{}
+	require.NoError(t, json.Unmarshal(raw, &internalData))
+	templateData, err := decodeTemplateData(internalData["template_data"])
+	require.NoError(t, err)
+	require.Equal(t, "JDoe", templateData.Entity.Name)
+	require.Equal(t, "jdoe@corp", templateData.Entity.alias("auth_oidc_1234"))
+}
+
+func TestDynamicCredsRead_creationReport(t *testing.T) {
+	ctx := context.Background()
+	config := testBackendConfig()
+	eventSender := logical.NewMockEventSender()
+	config.EventsSender = eventSender
+	b, _ := getBackendWithConfig(config, false)
+	storage := config.StorageView
+	defer b.Cleanup(ctx)
+	configureOpenLDAPMount(t, b, storage)
+
+	resp, err := createDynamicRoleWithData(t, b, storage, "grouped", map[string]interface{}{
+		"creation_ldif": `dn: cn={{.Username}},ou=users,dc=hashicorp,dc=com
+objectClass: person
+cn: {{.Username}}
+userPassword: {{.Password}}
+
+dn: cn=auditors,ou=groups,dc=hashicorp,dc=com
+changetype: modify
+add: member
+member: cn={{.Username}},ou=users,dc=hashicorp,dc=com
+-
+
+dn: cn=admins,ou=groups,dc=hashicorp,dc=com
+changetype: modify
+add: member
+member: cn={{.Username}},ou=users,dc=hashicorp,dc=com
+-`,
+		"rollback_ldif": `{{- if .Succeeded "cn=admins,ou=groups,dc=hashicorp,dc=com"}}
+dn: cn=admins,ou=groups,dc=hashicorp,dc=com
+changetype: modify
+delete: member
+member: cn={{.Username}},ou=users,dc=hashicorp,dc=com
+-
+
+{{end -}}
+dn: cn={{.Username}},ou=users,dc=hashicorp,dc=com
+changetype: delete`,
+		"deletion_ldif": `dn: cn={{.Username}},ou=users,dc=hashicorp,dc=com
+changetype: delete`,
+	})
+	require.NoError(t, err)
+	require.False(t, resp != nil && resp.IsError(), "unexpected error response: %v", resp)
+
+	recorder := &executeRecordingClient{failDN: "cn=admins,ou=groups,dc=hashicorp,dc=com"}
+	b.client = recorder
+
+	_, err = b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      dynamicCredPath + "grouped",
+		Storage:   storage,
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "creation report: add cn=v_")
+	require.Contains(t, err.Error(), "modify cn=auditors,ou=groups,dc=hashicorp,dc=com: success; "+
+		"modify cn=admins,ou=groups,dc=hashicorp,dc=com: Insufficient Access Rights (50): access denied")
+
+	// The rollback doesn't remove the membership that failed.
+	require.Len(t, recorder.executed, 2)
+	username := strings.TrimPrefix(strings.Split(recorder.executed[0][0], ",")[0], "cn=")
+	require.Equal(t, []string{"cn=" + username + ",ou=users,dc=hashicorp,dc=com"}, recorder.executed[1])
+
+	event := eventSender.Events[len(eventSender.Events)-1]
+	require.Equal(t, "ldap/creds-create-fail", string(event.Type))
+	metadata := event.Event.Metadata.AsMap()
+	var report []client.ExecuteResult
+	require.NoError(t, json.Unmarshal([]byte(metadata["creation_report"].(string)), &report))
+	require.Equal(t, []client.ExecuteResult{
+		{Index: 0, DN: "cn=" + username + ",ou=users,dc=hashicorp,dc=com", Operation: "add", Success: true},
+		{Index: 1, DN: "cn=auditors,ou=groups,dc=hashicorp,dc=com", Operation: "modify", Success: true},
+		{
+			Index:      2,
+			DN:         "cn=admins,ou=groups,dc=hashicorp,dc=com",
+			Operation:  "modify",
+			ResultCode: ldap.LDAPResultInsufficientAccessRights,
+			Message:    "access denied",
+		},
+	}, report)
+}
+
+func TestDynamicTemplateDataSucceeded(t *testing.T) {
+	dn := "cn=admins,ou=groups,dc=hashicorp,dc=com"
+	require.True(t, dynamicTemplateData{}.Succeeded(dn))
+	require.False(t, dynamicTemplateData{CreationReport: []client.ExecuteResult{}}.Succeeded(dn))
+	require.False(t, dynamicTemplateData{CreationReport: []client.ExecuteResult{{DN: dn}}}.Succeeded(dn))
+	require.True(t, dynamicTemplateData{CreationReport: []client.ExecuteResult{{DN: "CN=Admins,ou=groups,dc=hashicorp,dc=com", Success: true}}}.Succeeded(dn))
+}
+
 func TestDynamicCredsRead_missing_role(t *testing.T) {
 	roleName := "testrole"
 
@@ -301,7 +757,7 @@ func TestDynamicCredsRead_success(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			client := new(mockLDAPClient)
 			client.On("Execute", mock.Anything, mock.Anything, mock.Anything).
-				Return(error(nil)).
+				Return(nil, error(nil)).
 				Once()
 			defer client.AssertExpectations(t)
 
@@ -337,6 +793,8 @@ func TestDynamicCredsRead_success(t *testing.T) {
 			storage.On("Get", mock.Anything, configPath).
 				Return(configStore, error(nil)).
 				Once()
+			expectDynamicCredsWAL(storage)
+			expectIssuedCredential(storage, false)
 			defer storage.AssertExpectations(t)
 
 			b := Backend(client)
@@ -511,6 +969,9 @@ func TestSecretCredsRenew(t *testing.T) {
 			storage.On("Get", mock.Anything, path.Join(dynamicRolePath, roleName)).
 				Return(test.storageResp, test.storageErr).
 				Once()
+			storage.On("Get", mock.Anything, issuedCredentialPath(roleName, "alice")).
+				Return((*logical.StorageEntry)(nil), error(nil)).
+				Maybe()
 			defer storage.AssertExpectations(t)
 
 			client := new(mockLDAPClient)
@@ -539,6 +1000,64 @@ func TestSecretCredsRenew(t *testing.T) {
 	}
 }
 
+func TestSecretCredsRenew_renewalLDIF(t *testing.T) {
+	b, storage := getBackend(false)
+	defer b.Cleanup(context.Background())
+	configureOpenLDAPMount(t, b, storage)
+
+	data := getTestDynamicRoleConfig("testrole")
+	data["default_ttl"] = "1h"
+	data["max_ttl"] = "2h"
+	data["renewal_ldif"] = `dn: cn={{.Username}},ou=users,dc=hashicorp,dc=com
+changetype: modify
+replace: shadowExpire
+shadowExpire: {{.ExpirationTimeSeconds}}
+-`
+	resp, err := createDynamicRoleWithData(t, b, storage, "testrole", data)
+	require.NoError(t, err)
+	require.False(t, resp != nil && resp.IsError())
+
+	recorder := &executeRecordingClient{}
+	b.client = recorder
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      dynamicCredPath + "testrole",
+		Storage:   storage,
+	})
+	require.NoError(t, err)
+	secret := resp.Secret
+	secret.IssueTime = time.Now().Add(-90 * time.Minute)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RenewOperation,
+		Storage:   storage,
+		Secret:    secret,
+	})
+	require.NoError(t, err)
+	require.Len(t, recorder.entries, 2)
+
+	// The renewed expiration is capped by the max TTL from the issue time.
+	expectedExp := secret.IssueTime.Add(2 * time.Hour).Unix()
+	renewal := recorder.entries[1][0].Modify
+	require.Equal(t, []string{strconv.FormatInt(expectedExp, 10)}, renewal.Changes[0].Modification.Vals)
+
+	templateData, err := decodeTemplateData(resp.Secret.InternalData["template_data"])
+	require.NoError(t, err)
+	require.Equal(t, expectedExp, templateData.ExpirationTimeSeconds)
+
+	// A failed renewal LDIF fails the renewal.
+	recorder.onExecute = func() error {
+		return fmt.Errorf("test error")
+	}
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RenewOperation,
+		Storage:   storage,
+		Secret:    secret,
+	})
+	require.Error(t, err)
+}
+
 func TestSecretCredsRevoke(t *testing.T) {
 	t.Run("error getting config", func(t *testing.T) {
 		storage := new(mockStorage)
@@ -662,54 +1181,73 @@ func TestSecretCredsRevoke(t *testing.T) {
 		require.Error(t, err)
 	})
 
-	t.Run("ldap error", func(t *testing.T) {
-		storage := new(mockStorage)
+	// Failed deletions are queued for retry, and only fail the revocation if
+	// they can't be queued.
+	for name, queueErr := range map[string]error{
+		"ldap error is queued":       nil,
+		"ldap error can't be queued": fmt.Errorf("test storage error"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			storage := new(mockStorage)
 
-		storageResp := &logical.StorageEntry{
-			Key:   configPath,
-			Value: jsonEncode(t, config{}),
-		}
-		storage.On("Get", mock.Anything, configPath).
-			Return(storageResp, error(nil)).
-			Once()
-		defer storage.AssertExpectations(t)
+			storageResp := &logical.StorageEntry{
+				Key:   configPath,
+				Value: jsonEncode(t, config{}),
+			}
+			storage.On("Get", mock.Anything, configPath).
+				Return(storageResp, error(nil)).
+				Once()
+			storage.On("Get", mock.Anything, issuedCredentialPath("testrole", "testuser")).
+				Return((*logical.StorageEntry)(nil), error(nil)).
+				Once()
+			storage.On("Put", mock.Anything, mock.MatchedBy(func(entry *logical.StorageEntry) bool {
+				return strings.HasPrefix(entry.Key, revocationQueuePath)
+			})).
+				Return(queueErr).
+				Once()
+			defer storage.AssertExpectations(t)
 
-		client := new(mockLDAPClient)
-		client.On("Execute", mock.Anything, mock.Anything, mock.Anything).
-			Return(fmt.Errorf("test error")).
-			Once()
-		defer client.AssertExpectations(t)
+			client := new(mockLDAPClient)
+			client.On("Execute", mock.Anything, mock.Anything, mock.Anything).
+				Return(nil, fmt.Errorf("test error")).
+				Once()
+			defer client.AssertExpectations(t)
 
-		b := Backend(client)
+			b := Backend(client)
 
-		now := time.Now()
-		exp := now.Add(1 * time.Hour)
-		req := &logical.Request{
-			Storage: storage,
-			Secret: &logical.Secret{
-				InternalData: map[string]interface{}{
-					"name":          "testrole",
-					"deletion_ldif": ldifDeleteTemplate,
-					"template_data": dynamicTemplateData{
-						Username:              "testuser",
-						Password:              "asdfa08ay4t98hoizvohiuz",
-						DisplayName:           "token",
-						RoleName:              "testrole",
-						IssueTime:             now.Format(time.RFC3339),
-						IssueTimeSeconds:      now.Unix(),
-						ExpirationTime:        exp.Format(time.RFC3339),
-						ExpirationTimeSeconds: exp.Unix(),
+			now := time.Now()
+			exp := now.Add(1 * time.Hour)
+			req := &logical.Request{
+				Storage: storage,
+				Secret: &logical.Secret{
+					InternalData: map[string]interface{}{
+						"name":          "testrole",
+						"deletion_ldif": ldifDeleteTemplate,
+						"template_data": dynamicTemplateData{
+							Username:              "testuser",
+							Password:              "asdfa08ay4t98hoizvohiuz",
+							DisplayName:           "token",
+							RoleName:              "testrole",
+							IssueTime:             now.Format(time.RFC3339),
+							IssueTimeSeconds:      now.Unix(),
+							ExpirationTime:        exp.Format(time.RFC3339),
+							ExpirationTimeSeconds: exp.Unix(),
+						},
 					},
 				},
-			},
-		}
-		var data *framework.FieldData
-		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
-		defer cancel()
+			}
+			var data *framework.FieldData
+			ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+			defer cancel()
 
-		_, err := b.secretCredsRevoke()(ctx, req, data)
-		require.Error(t, err)
-	})
+			_, err := b.secretCredsRevoke()(ctx, req, data)
+			if queueErr != nil {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
 
 	t.Run("happy path", func(t *testing.T) {
 		storage := new(mockStorage)
@@ -721,11 +1259,17 @@ func TestSecretCredsRevoke(t *testing.T) {
 		storage.On("Get", mock.Anything, configPath).
 			Return(storageResp, error(nil)).
 			Once()
+		storage.On("Get", mock.Anything, issuedCredentialPath("testrole", "testuser")).
+			Return((*logical.StorageEntry)(nil), error(nil)).
+			Twice()
+		storage.On("Delete", mock.Anything, issuedCredentialPath("testrole", "testuser")).
+			Return(error(nil)).
+			Once()
 		defer storage.AssertExpectations(t)
 
 		client := new(mockLDAPClient)
 		client.On("Execute", mock.Anything, mock.Anything, mock.Anything).
-			Return(error(nil)).
+			Return(nil, error(nil)).
 			Once()
 		defer client.AssertExpectations(t)
 
@@ -964,3 +1508,28 @@ func getStringSlice(t *testing.T, m map[string]interface{}, key string) []string
 
 	return strSlice
 }
+
+func TestGetDNs(t *testing.T) {
+	entries, err := client.ParseLDIF(`dn: cn=alice,ou=staging,dc=example,dc=com
+objectClass: person
+cn: alice
+
+dn: cn=alice,ou=staging,dc=example,dc=com
+changetype: moddn
+newrdn: cn=alice
+deleteoldrdn: 1
+newsuperior: ou=users,dc=example,dc=com
+
+dn: cn=admins,ou=groups,dc=example,dc=com
+control: 1.2.840.113556.1.4.1413
+changetype: modify
+add: member
+member: cn=alice,ou=users,dc=example,dc=com
+-`)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"cn=alice,ou=staging,dc=example,dc=com",
+		"cn=alice,ou=users,dc=example,dc=com",
+		"cn=admins,ou=groups,dc=example,dc=com",
+	}, getDNs(entries))
+}
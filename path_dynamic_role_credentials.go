@@ -0,0 +1,198 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// issuedUsernameRegex matches the usernames of issued credentials, which are
+// generated by username templates and may contain any character but a slash.
+func issuedUsernameRegex(name string) string {
+	return fmt.Sprintf(`(?P<%s>[^/]+)`, name)
+}
+
+func (b *backend) pathDynamicRoleCredentials() []*framework.Path {
+	credentialsPattern := strings.TrimSuffix(dynamicRolePath, "/") + genericNameWithForwardSlashRegex("name") + "/credentials"
+	fields := map[string]*framework.FieldSchema{
+		"name": {
+			Type:        framework.TypeLowerCaseString,
+			Description: "Name of the dynamic role.",
+			Required:    true,
+		},
+		"username": {
+			Type:        framework.TypeString,
+			Description: "Username of the issued credential.",
+			Required:    true,
+		},
+	}
+
+	return []*framework.Path{
+		{
+			Pattern: credentialsPattern + "/" + issuedUsernameRegex("username") + "/force-delete$",
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: operationPrefixLDAP,
+				OperationVerb:   "force-delete",
+				OperationSuffix: "dynamic-role-credential",
+			},
+			Fields: fields,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback:                    b.pathDynamicRoleCredentialForceDelete,
+					ForwardPerformanceStandby:   true,
+					ForwardPerformanceSecondary: true,
+				},
+			},
+			HelpSynopsis: "Run the deletion LDIF of a dynamic role for a username.",
+			HelpDescription: "This path runs the role's deletion_ldif for the username, for example after the " +
+				"lease of the credential was lost, and removes the credential from the role's issued credentials. " +
+				"The username must have been issued by the role, or have a failed revocation, and must not be " +
+				"managed by a static role or library set. The credential's recorded template data is used if " +
+				"there is one. Leases that still exist for the credential are not revoked.",
+		},
+		{
+			Pattern: credentialsPattern + "/" + issuedUsernameRegex("username") + "$",
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: operationPrefixLDAP,
+				OperationVerb:   "read",
+				OperationSuffix: "dynamic-role-credential",
+			},
+			Fields: fields,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathDynamicRoleCredentialRead,
+				},
+			},
+			HelpSynopsis: "Read a credential issued by a dynamic role.",
+			HelpDescription: "Returns the username, DNs, lease ID, requesting entity ID, issue time, and expiration " +
+				"of a credential issued by the role whose entries have not been deleted.",
+		},
+		{
+			Pattern: credentialsPattern + "/?$",
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: operationPrefixLDAP,
+				OperationVerb:   "list",
+				OperationSuffix: "dynamic-role-credentials",
+			},
+			Fields: map[string]*framework.FieldSchema{
+				"name": fields["name"],
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: b.pathDynamicRoleCredentialsList,
+				},
+			},
+			HelpSynopsis: "List the credentials issued by a dynamic role.",
+			HelpDescription: "Lists the usernames of the credentials issued by the role whose entries have not " +
+				"been deleted, with their details as key_info. Lease IDs are only known once a lease has been " +
+				"renewed or its password rotated. Credentials issued before the plugin kept an index of issued " +
+				"credentials are not listed.",
+		},
+	}
+}
+
+func (b *backend) pathDynamicRoleCredentialsList(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	roleName := data.Get("name").(string)
+	creds, err := listIssuedCredentials(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list issued credentials: %w", err)
+	}
+
+	keys := make([]string, 0, len(creds))
+	keyInfo := make(map[string]interface{}, len(creds))
+	for _, cred := range creds {
+		keys = append(keys, cred.Username)
+		keyInfo[cred.Username] = cred.toMap()
+	}
+	return logical.ListResponseWithInfo(keys, keyInfo), nil
+}
+
+func (b *backend) pathDynamicRoleCredentialRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	cred, err := retrieveIssuedCredential(ctx, req.Storage, data.Get("name").(string), data.Get("username").(string))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read issued credential record: %w", err)
+	}
+	if cred == nil {
+		return nil, nil
+	}
+	return &logical.Response{
+		Data: cred.toMap(),
+	}, nil
+}
+
+func (b *backend) pathDynamicRoleCredentialForceDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	roleName := data.Get("name").(string)
+	username := data.Get("username").(string)
+
+	dRole, err := retrieveDynamicRole(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve dynamic role: %w", err)
+	}
+	if dRole == nil {
+		return logical.ErrorResponse("role %q not found", roleName), nil
+	}
+	if dRole.roleType() != dynamicRoleTypeLDIF {
+		return logical.ErrorResponse("role %q has no deletion_ldif", roleName), nil
+	}
+
+	config, err := readConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, fmt.Errorf("missing LDAP configuration")
+	}
+
+	cred, err := retrieveIssuedCredential(ctx, req.Storage, roleName, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read issued credential record: %w", err)
+	}
+	// Usernames without a record are only deleted if a revocation of theirs
+	// failed, so that the path cannot delete arbitrary entries.
+	if cred == nil {
+		failed, err := hasFailedRevocation(ctx, req.Storage, roleName, username)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read failed revocations: %w", err)
+		}
+		if !failed {
+			return logical.ErrorResponse("no credential with username %q was issued by role %q", username, roleName), nil
+		}
+	}
+	b.managedUserLock.Lock()
+	_, managed := b.managedUsers[username]
+	b.managedUserLock.Unlock()
+	if managed {
+		return logical.ErrorResponse("%q is managed by a static role or library set", username), nil
+	}
+
+	templateData := dynamicTemplateData{
+		Username: username,
+		RoleName: roleName,
+	}
+	if cred != nil && cred.TemplateData != nil {
+		templateData = *cred.TemplateData
+	}
+
+	// Entries that were already deleted, such as by the revocation of a lease
+	// that was thought lost, don't fail the deletion.
+	dns, err := b.executeLDIF(config.LDAP, dRole.DeletionLDIF, templateData, true)
+	if err != nil && !alreadyDeleted(err) {
+		b.ldapEvent(ctx, "creds-force-delete-fail", req.Path, roleName, false, "username", username)
+		return nil, fmt.Errorf("failed to delete credential: %w", err)
+	}
+	b.removeIssuedCredential(ctx, req.Storage, roleName, username)
+
+	b.ldapEvent(ctx, "creds-force-delete", req.Path, roleName, true, "username", username)
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"username":            username,
+			"distinguished_names": dns,
+		},
+	}, nil
+}
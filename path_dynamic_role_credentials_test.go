@@ -0,0 +1,151 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDynamicRoleCredentials(t *testing.T) {
+	ctx := context.Background()
+	b, storage := getBackend(false)
+	defer b.Cleanup(ctx)
+	configureOpenLDAPMount(t, b, storage)
+
+	resp, err := createDynamicRoleWithData(t, b, storage, "hashicorp", getTestDynamicRoleConfig("hashicorp"))
+	require.NoError(t, err)
+	require.False(t, resp != nil && resp.IsError())
+
+	recorder := &executeRecordingClient{}
+	b.client = recorder
+
+	var usernames []string
+	for _, entityID := range []string{"alice", ""} {
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      dynamicCredPath + "hashicorp",
+			Storage:   storage,
+			EntityID:  entityID,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError(), "unexpected error response: %v", resp)
+		usernames = append(usernames, resp.Data["username"].(string))
+	}
+	alice, other := usernames[0], usernames[1]
+
+	listCreds := func() *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ListOperation,
+			Path:      dynamicRolePath + "hashicorp/credentials/",
+			Storage:   storage,
+		})
+		require.NoError(t, err)
+		return resp
+	}
+	forceDeleteRequest := func(username string) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      dynamicRolePath + "hashicorp/credentials/" + username + "/force-delete",
+			Storage:   storage,
+		})
+	}
+	forceDelete := func(username string) *logical.Response {
+		t.Helper()
+		resp, err := forceDeleteRequest(username)
+		require.NoError(t, err)
+		return resp
+	}
+
+	t.Run("list", func(t *testing.T) {
+		resp := listCreds()
+		require.ElementsMatch(t, usernames, resp.Data["keys"])
+		info := resp.Data["key_info"].(map[string]interface{})[alice].(map[string]interface{})
+		require.Equal(t, "alice", info["entity_id"])
+		require.Equal(t, []string{"cn=" + alice + ",ou=users,dc=hashicorp,dc=com"}, info["distinguished_names"])
+	})
+
+	t.Run("read", func(t *testing.T) {
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      dynamicRolePath + "hashicorp/credentials/" + other,
+			Storage:   storage,
+		})
+		require.NoError(t, err)
+		require.Equal(t, other, resp.Data["username"])
+		require.Equal(t, "", resp.Data["entity_id"])
+		require.Equal(t, "", resp.Data["lease_id"])
+		require.NotContains(t, resp.Data, "last_password_rotation")
+	})
+
+	t.Run("force delete failure", func(t *testing.T) {
+		recorder.onExecute = func() error {
+			return errors.New("connection refused")
+		}
+		defer func() { recorder.onExecute = nil }()
+
+		resp, err := forceDeleteRequest(alice)
+		require.ErrorContains(t, err, "connection refused")
+		require.Nil(t, resp)
+		require.Len(t, listCreds().Data["keys"], 2)
+	})
+
+	t.Run("force delete", func(t *testing.T) {
+		resp := forceDelete(alice)
+		require.False(t, resp.IsError(), "unexpected error response: %v", resp)
+		require.Equal(t, []string{"cn=" + alice + ",ou=users,dc=learn,dc=example"}, recorder.executed[len(recorder.executed)-1])
+		require.Equal(t, []string{other}, listCreds().Data["keys"])
+
+		active, err := retrieveActiveCredentials(ctx, storage, "hashicorp")
+		require.NoError(t, err)
+		require.Equal(t, &activeCredentials{Total: 1}, active)
+	})
+
+	t.Run("force delete of deleted entries", func(t *testing.T) {
+		recorder.onExecute = func() error {
+			return ldap.NewError(ldap.LDAPResultNoSuchObject, errors.New("no such object"))
+		}
+		defer func() { recorder.onExecute = nil }()
+
+		resp := forceDelete(other)
+		require.False(t, resp.IsError(), "unexpected error response: %v", resp)
+		require.Nil(t, listCreds().Data["keys"])
+	})
+
+	t.Run("force delete of unknown username", func(t *testing.T) {
+		executed := len(recorder.executed)
+		resp := forceDelete("v-lost")
+		require.ErrorContains(t, resp.Error(), "was issued by role")
+		require.Len(t, recorder.executed, executed)
+	})
+
+	t.Run("force delete of failed revocation", func(t *testing.T) {
+		require.NoError(t, storeFailedRevocation(ctx, storage, revocationFailuresPath, &failedRevocation{
+			ID:       "lost",
+			RoleName: "hashicorp",
+			Username: "v-lost",
+		}))
+
+		resp := forceDelete("v-lost")
+		require.False(t, resp.IsError(), "unexpected error response: %v", resp)
+		require.Equal(t, []string{"cn=v-lost,ou=users,dc=learn,dc=example"}, resp.Data["distinguished_names"])
+	})
+
+	t.Run("force delete of managed user", func(t *testing.T) {
+		require.NoError(t, storeIssuedCredential(ctx, storage, "hashicorp", &issuedCredential{Username: "svc-backup"}))
+		b.managedUsers["svc-backup"] = struct{}{}
+		defer delete(b.managedUsers, "svc-backup")
+
+		executed := len(recorder.executed)
+		resp := forceDelete("svc-backup")
+		require.ErrorContains(t, resp.Error(), "is managed by a static role or library set")
+		require.Len(t, recorder.executed, executed)
+	})
+}
@@ -0,0 +1,218 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/hashicorp/vault-plugin-secrets-openldap/client"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// redactedPassword is rendered in place of the password in previews.
+const redactedPassword = "REDACTED"
+
+func (b *backend) pathDynamicRolePreview() []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: strings.TrimSuffix(dynamicRolePath, "/") + genericNameWithForwardSlashRegex("name") + "/preview$",
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: operationPrefixLDAP,
+				OperationVerb:   "preview",
+				OperationSuffix: "dynamic-role",
+			},
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeLowerCaseString,
+					Description: "Name of the dynamic role.",
+					Required:    true,
+				},
+				"check_schema": {
+					Type:        framework.TypeBool,
+					Description: "Validate objectClasses and attribute names against the server's subschemaSubentry.",
+				},
+				"params": {
+					Type:        framework.TypeKVPairs,
+					Description: "Values of the role's allowed_parameters, as they would be supplied when requesting credentials.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathDynamicRolePreviewRead,
+				},
+			},
+			HelpSynopsis: "Render the LDIF of a dynamic role without executing it.",
+			HelpDescription: "This path renders the creation, deletion, rollback, renewal, and rotation LDIF of a dynamic " +
+				"role with generated values, as they would be executed for a new credential, along with the " +
+				"outputs of its response_template. The password is redacted. With check_schema, the rendered LDIF is also checked against the schema published " +
+				"by the LDAP server.",
+		},
+	}
+}
+
+func (b *backend) pathDynamicRolePreviewRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	roleName := data.Get("name").(string)
+
+	dRole, err := retrieveDynamicRole(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve dynamic role: %w", err)
+	}
+	if dRole == nil {
+		return nil, nil
+	}
+	if dRole.roleType() != dynamicRoleTypeLDIF {
+		return logical.ErrorResponse("role %q has no LDIF to preview", roleName), nil
+	}
+
+	entity, err := b.requestEntity(req)
+	if err != nil {
+		return nil, err
+	}
+	username, err := generateUsername(req, dRole, entity)
+	if err != nil {
+		return logical.ErrorResponse("failed to generate username: %s", err), nil
+	}
+	var supplied map[string]string
+	if raw, ok := data.GetOk("params"); ok {
+		supplied = raw.(map[string]string)
+	}
+	params, err := resolveParams(dRole.AllowedParameters, supplied)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	now := time.Now()
+	exp := now.Add(dRole.DefaultTTL)
+	templateData := dynamicTemplateData{
+		Username:              username,
+		Password:              redactedPassword,
+		PublicKey:             sampleSSHPublicKey,
+		DisplayName:           req.DisplayName,
+		RoleName:              roleName,
+		IssueTime:             now.Format(time.RFC3339),
+		IssueTimeSeconds:      now.Unix(),
+		ExpirationTime:        exp.Format(time.RFC3339),
+		ExpirationTimeSeconds: exp.Unix(),
+		Entity:                entity,
+		Params:                params,
+	}
+	if !dRole.generatesPassword() {
+		templateData.Password = ""
+	}
+
+	templates := []struct {
+		field    string
+		template string
+	}{
+		{"creation_ldif", dRole.CreationLDIF},
+		{"deletion_ldif", dRole.DeletionLDIF},
+		{"rollback_ldif", dRole.RollbackLDIF},
+		{"renewal_ldif", dRole.RenewalLDIF},
+		{"rotation_ldif", dRole.RotationLDIF},
+	}
+
+	respData := map[string]interface{}{
+		"username": username,
+	}
+	rendered := make(map[string][]*client.LDIFEntry)
+	for _, tmpl := range templates {
+		if tmpl.template == "" {
+			continue
+		}
+		rawLDIF, err := applyTemplate(tmpl.template, templateData)
+		if err != nil {
+			return logical.ErrorResponse("failed to render %s: %s", tmpl.field, err), nil
+		}
+		entries, err := client.ParseLDIF(rawLDIF)
+		if err != nil {
+			return logical.ErrorResponse("failed to parse rendered %s: %s", tmpl.field, err), nil
+		}
+		respData[tmpl.field] = rawLDIF
+		rendered[tmpl.field] = entries
+	}
+	respData["distinguished_names"] = getDNs(rendered["creation_ldif"])
+
+	response, err := renderResponseTemplate(dRole.ResponseTemplate, entity, templateData)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	if response != nil {
+		respData["response_template"] = response
+	}
+
+	if data.Get("check_schema").(bool) {
+		config, err := readConfig(ctx, req.Storage)
+		if err != nil {
+			return nil, err
+		}
+		if config == nil {
+			return logical.ErrorResponse("missing LDAP configuration"), nil
+		}
+
+		subschema, err := b.client.Subschema(config.LDAP)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read schema: %w", err)
+		}
+
+		schemaErrors := []string{}
+		for _, tmpl := range templates {
+			for _, entry := range rendered[tmpl.field] {
+				for _, problem := range schemaProblems(subschema, entry) {
+					schemaErrors = append(schemaErrors, fmt.Sprintf("%s: %s", tmpl.field, problem))
+				}
+			}
+		}
+		respData["schema_errors"] = schemaErrors
+	}
+
+	return &logical.Response{
+		Data: respData,
+	}, nil
+}
+
+// schemaProblems returns the object classes and attribute types used by the
+// given LDIF entry that are not defined by the schema.
+func schemaProblems(subschema *client.Subschema, entry *client.LDIFEntry) []string {
+	var dn string
+	var attributes []ldap.Attribute
+	switch {
+	case entry.Entry != nil:
+		dn = entry.Entry.DN
+		for _, attr := range entry.Entry.Attributes {
+			attributes = append(attributes, ldap.Attribute{Type: attr.Name, Vals: attr.Values})
+		}
+	case entry.Add != nil:
+		dn = entry.Add.DN
+		attributes = entry.Add.Attributes
+	case entry.Modify != nil:
+		dn = entry.Modify.DN
+		for _, change := range entry.Modify.Changes {
+			attributes = append(attributes, ldap.Attribute{Type: change.Modification.Type, Vals: change.Modification.Vals})
+		}
+	default:
+		return nil
+	}
+
+	var problems []string
+	for _, attr := range attributes {
+		if !subschema.HasAttributeType(attr.Type) {
+			problems = append(problems, fmt.Sprintf("%s: unknown attribute type %q", dn, attr.Type))
+			continue
+		}
+		if !strings.EqualFold(attr.Type, "objectClass") {
+			continue
+		}
+		for _, objectClass := range attr.Vals {
+			if !subschema.HasObjectClass(objectClass) {
+				problems = append(problems, fmt.Sprintf("%s: unknown object class %q", dn, objectClass))
+			}
+		}
+	}
+	return problems
+}
@@ -0,0 +1,115 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault-plugin-secrets-openldap/client"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDynamicRolePreview(t *testing.T) {
+	ctx := context.Background()
+	b, storage := getBackend(false)
+	defer b.Cleanup(ctx)
+	configureOpenLDAPMount(t, b, storage)
+
+	resp, err := createDynamicRoleWithData(t, b, storage, "hashicorp", getTestDynamicRoleConfig("hashicorp"))
+	require.NoError(t, err)
+	require.False(t, resp != nil && resp.IsError())
+
+	recorder := &executeRecordingClient{
+		fakeLdapClient: fakeLdapClient{
+			subschema: client.ParseSubschema(
+				[]string{"( 2.5.6.6 NAME 'person' SUP top STRUCTURAL )", "( 2.5.6.0 NAME 'top' ABSTRACT )"},
+				[]string{"( 2.5.4.0 NAME 'objectClass' )", "( 2.5.4.3 NAME ( 'cn' 'commonName' ) )", "( 2.5.4.4 NAME ( 'sn' 'surname' ) )", "( 2.5.4.35 NAME 'userPassword' )"},
+			),
+		},
+	}
+	b.client = recorder
+
+	t.Run("renders without executing", func(t *testing.T) {
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      dynamicRolePath + "hashicorp/preview",
+			Storage:   storage,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError(), "unexpected error response: %v", resp)
+		require.Empty(t, recorder.executed)
+
+		username := resp.Data["username"].(string)
+		require.True(t, strings.HasPrefix(username, "v-foo-hashicorp-"))
+		require.Equal(t, []string{"cn=" + username + ",ou=users,dc=hashicorp,dc=com"}, resp.Data["distinguished_names"])
+
+		creation := resp.Data["creation_ldif"].(string)
+		require.Contains(t, creation, "userPassword: "+redactedPassword)
+		_, err = client.ParseLDIF(creation)
+		require.NoError(t, err)
+		require.Contains(t, resp.Data["deletion_ldif"], "cn="+username)
+		require.Contains(t, resp.Data["rollback_ldif"], "cn="+username)
+		require.NotContains(t, resp.Data, "renewal_ldif")
+		require.NotContains(t, resp.Data, "schema_errors")
+	})
+
+	t.Run("check schema", func(t *testing.T) {
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      dynamicRolePath + "hashicorp/preview",
+			Storage:   storage,
+			Data:      map[string]interface{}{"check_schema": true},
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError(), "unexpected error response: %v", resp)
+
+		schemaErrors := resp.Data["schema_errors"].([]string)
+		require.Len(t, schemaErrors, 1)
+		require.Contains(t, schemaErrors[0], `creation_ldif: cn=`)
+		require.Contains(t, schemaErrors[0], `unknown attribute type "memberOf"`)
+	})
+
+	t.Run("missing role", func(t *testing.T) {
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      dynamicRolePath + "missing/preview",
+			Storage:   storage,
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+	})
+}
+
+func TestSchemaProblems(t *testing.T) {
+	subschema := client.ParseSubschema(
+		[]string{"( 2.5.6.6 NAME 'person' )"},
+		[]string{"( 2.5.4.0 NAME 'objectClass' )", "( 2.5.4.3 NAME 'cn' )", "( 2.5.4.13 NAME 'description' )"},
+	)
+
+	entries, err := client.ParseLDIF(`dn: cn=alice,dc=example
+changetype: add
+objectClass: person
+objectClass: posixAccount
+cn: alice
+
+dn: cn=alice,dc=example
+changetype: modify
+replace: description
+description: test
+-
+add: mail
+mail: alice@example.com
+-
+
+dn: cn=alice,dc=example
+changetype: delete`)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{`cn=alice,dc=example: unknown object class "posixAccount"`}, schemaProblems(subschema, entries[0]))
+	require.Equal(t, []string{`cn=alice,dc=example: unknown attribute type "mail"`}, schemaProblems(subschema, entries[1]))
+	require.Empty(t, schemaProblems(subschema, entries[2]))
+}
@@ -0,0 +1,232 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/hashicorp/vault-plugin-secrets-openldap/client"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// orphanSearch finds the entries created by a dynamic role in the directory,
+// so that entries without a live credential can be found by a sweep.
+type orphanSearch struct {
+	BaseDN string `json:"base_dn" mapstructure:"base_dn"`
+	Filter string `json:"filter"  mapstructure:"filter"`
+
+	// UsernameAttribute is the attribute holding the username that the
+	// deletion LDIF is rendered with. Defaults to the value of the first RDN.
+	UsernameAttribute string `json:"username_attribute,omitempty" mapstructure:"username_attribute"`
+}
+
+func (o *orphanSearch) validate() error {
+	if o.BaseDN == "" {
+		return fmt.Errorf("missing base_dn")
+	}
+	if _, err := ldap.ParseDN(o.BaseDN); err != nil {
+		return fmt.Errorf("invalid base_dn: %w", err)
+	}
+	if o.Filter == "" {
+		return fmt.Errorf("missing filter")
+	}
+	if _, err := ldap.CompileFilter(o.Filter); err != nil {
+		return fmt.Errorf("invalid filter: %w", err)
+	}
+	return nil
+}
+
+// username returns the username of an entry found by the search.
+func (o *orphanSearch) username(entry *client.Entry) (string, error) {
+	if o.UsernameAttribute != "" {
+		username := entry.GetAttributeValue(o.UsernameAttribute)
+		if username == "" {
+			return "", fmt.Errorf("entry has no %s", o.UsernameAttribute)
+		}
+		return username, nil
+	}
+
+	dn, err := ldap.ParseDN(entry.DN)
+	if err != nil {
+		return "", fmt.Errorf("invalid DN: %w", err)
+	}
+	if len(dn.RDNs) == 0 || len(dn.RDNs[0].Attributes) == 0 {
+		return "", fmt.Errorf("entry has an empty DN")
+	}
+	return dn.RDNs[0].Attributes[0].Value, nil
+}
+
+func (o *orphanSearch) toMap() map[string]interface{} {
+	return map[string]interface{}{
+		"base_dn":            o.BaseDN,
+		"filter":             o.Filter,
+		"username_attribute": o.UsernameAttribute,
+	}
+}
+
+func (b *backend) pathDynamicRoleSweep() []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: strings.TrimSuffix(dynamicRolePath, "/") + genericNameWithForwardSlashRegex("name") + "/sweep$",
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: operationPrefixLDAP,
+				OperationSuffix: "dynamic-role-orphans",
+			},
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeLowerCaseString,
+					Description: "Name of the dynamic role.",
+					Required:    true,
+				},
+				"delete": {
+					Type:        framework.TypeBool,
+					Description: "Delete the orphaned entries with the role's deletion_ldif.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathDynamicRoleSweepRead,
+					DisplayAttrs: &framework.DisplayAttributes{
+						OperationVerb: "find",
+					},
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback:                    b.pathDynamicRoleSweepUpdate,
+					ForwardPerformanceStandby:   true,
+					ForwardPerformanceSecondary: true,
+					DisplayAttrs: &framework.DisplayAttributes{
+						OperationVerb: "sweep",
+					},
+				},
+			},
+			HelpSynopsis: "Find and delete entries of a dynamic role that have no live credential.",
+			HelpDescription: "Reading this path runs the orphan_search of the dynamic role and reports the " +
+				"matching entries that do not belong to a live credential issued by the role. Writing to it " +
+				"with delete set to true also runs the role's deletion_ldif for each orphaned entry, rendered " +
+				"with the template data of its expired credential, or only its username and the role name for " +
+				"entries without one. Entries created by credentials issued before the plugin kept an index of " +
+				"issued credentials are reported as orphans.",
+		},
+	}
+}
+
+func (b *backend) pathDynamicRoleSweepRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	return b.sweepDynamicRole(ctx, req, data.Get("name").(string), false)
+}
+
+func (b *backend) pathDynamicRoleSweepUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	return b.sweepDynamicRole(ctx, req, data.Get("name").(string), data.Get("delete").(bool))
+}
+
+// sweepDynamicRole reports the entries found by the orphan_search of a role
+// that do not belong to a live credential in the issued credential index, and
+// optionally deletes them.
+func (b *backend) sweepDynamicRole(ctx context.Context, req *logical.Request, roleName string, deleteOrphans bool) (*logical.Response, error) {
+	dRole, err := retrieveDynamicRole(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve dynamic role: %w", err)
+	}
+	if dRole == nil {
+		return nil, nil
+	}
+	if dRole.OrphanSearch == nil {
+		return logical.ErrorResponse("role %q has no orphan_search", roleName), nil
+	}
+
+	config, err := readConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, fmt.Errorf("missing LDAP configuration")
+	}
+
+	search := dRole.OrphanSearch
+	attributes := []string{"1.1"}
+	if search.UsernameAttribute != "" {
+		attributes = []string{search.UsernameAttribute}
+	}
+	entries, err := b.client.SearchSubtree(config.LDAP, search.BaseDN, search.Filter, attributes...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run orphan_search: %w", err)
+	}
+
+	creds, err := listIssuedCredentials(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list issued credentials: %w", err)
+	}
+	now := time.Now()
+	live := make(map[string]bool)
+	expired := make(map[string]*issuedCredential)
+	for _, cred := range creds {
+		for _, dn := range cred.DistinguishedNames {
+			if cred.live(now) {
+				live[normalizeDN(dn)] = true
+			} else {
+				expired[normalizeDN(dn)] = cred
+			}
+		}
+	}
+
+	orphans := []map[string]interface{}{}
+	var deleted int
+	for _, entry := range entries {
+		key := normalizeDN(entry.DN)
+		if live[key] {
+			continue
+		}
+
+		orphan := map[string]interface{}{
+			"dn": entry.DN,
+		}
+		orphans = append(orphans, orphan)
+
+		// Expired credentials whose entries remain were not revoked, so the
+		// deletion LDIF is rendered with the data they were issued with.
+		// Entries without a record only have their username and role.
+		var templateData dynamicTemplateData
+		if cred, ok := expired[key]; ok {
+			if cred.TemplateData != nil {
+				templateData = *cred.TemplateData
+			}
+			templateData.Username = cred.Username
+		} else {
+			templateData.Username, err = search.username(entry)
+			if err != nil {
+				orphan["error"] = err.Error()
+				continue
+			}
+		}
+		templateData.RoleName = roleName
+		username := templateData.Username
+		orphan["username"] = username
+
+		if !deleteOrphans {
+			continue
+		}
+		if _, err := b.executeLDIF(config.LDAP, dRole.DeletionLDIF, templateData, true); err != nil {
+			orphan["error"] = err.Error()
+			continue
+		}
+		orphan["deleted"] = true
+		deleted++
+		b.removeIssuedCredential(ctx, req.Storage, roleName, username)
+	}
+
+	if deleteOrphans {
+		b.ldapEvent(ctx, "role-sweep", req.Path, roleName, deleted > 0, "deleted", strconv.Itoa(deleted))
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"orphans": orphans,
+		},
+	}, nil
+}
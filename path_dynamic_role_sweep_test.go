@@ -0,0 +1,198 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/hashicorp/vault-plugin-secrets-openldap/client"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+// searchClient returns entries from SearchSubtree and records executed LDIF.
+type searchClient struct {
+	executeRecordingClient
+	entries []*client.Entry
+}
+
+func (s *searchClient) SearchSubtree(_ *client.Config, _, _ string, _ ...string) ([]*client.Entry, error) {
+	return s.entries, nil
+}
+
+func TestDynamicRoleSweep(t *testing.T) {
+	ctx := context.Background()
+	b, storage := getBackend(false)
+	defer b.Cleanup(ctx)
+	configureOpenLDAPMount(t, b, storage)
+
+	data := getTestDynamicRoleConfig("hashicorp")
+	data["orphan_search"] = map[string]interface{}{
+		"base_dn": "ou=users,dc=hashicorp,dc=com",
+		"filter":  "(description=vault-dynamic:hashicorp)",
+	}
+	resp, err := createDynamicRoleWithData(t, b, storage, "hashicorp", data)
+	require.NoError(t, err)
+	require.False(t, resp != nil && resp.IsError())
+
+	directory := &searchClient{}
+	b.client = directory
+
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      dynamicCredPath + "hashicorp",
+		Storage:   storage,
+	})
+	require.NoError(t, err)
+	liveDN := resp.Data["distinguished_names"].([]string)[0]
+
+	require.NoError(t, storeIssuedCredential(ctx, storage, "hashicorp", &issuedCredential{
+		Username:           "v-expired",
+		DistinguishedNames: []string{"cn=v-expired,ou=users,dc=hashicorp,dc=com"},
+		IssueTime:          time.Now().Add(-2 * time.Hour),
+		ExpireTime:         time.Now().Add(-time.Hour),
+	}))
+
+	directory.entries = []*client.Entry{
+		client.NewEntry(&ldap.Entry{DN: "CN=" + liveDN[len("cn="):]}),
+		client.NewEntry(&ldap.Entry{DN: "cn=v-expired,ou=users,dc=hashicorp,dc=com"}),
+		client.NewEntry(&ldap.Entry{DN: "cn=v-unknown,ou=users,dc=hashicorp,dc=com"}),
+	}
+	directory.executed = nil
+
+	t.Run("report", func(t *testing.T) {
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      dynamicRolePath + "hashicorp/sweep",
+			Storage:   storage,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError(), "unexpected error response: %v", resp)
+		require.Equal(t, []map[string]interface{}{
+			{"dn": "cn=v-expired,ou=users,dc=hashicorp,dc=com", "username": "v-expired"},
+			{"dn": "cn=v-unknown,ou=users,dc=hashicorp,dc=com", "username": "v-unknown"},
+		}, resp.Data["orphans"])
+		require.Empty(t, directory.executed)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      dynamicRolePath + "hashicorp/sweep",
+			Storage:   storage,
+			Data:      map[string]interface{}{"delete": true},
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError(), "unexpected error response: %v", resp)
+		for _, orphan := range resp.Data["orphans"].([]map[string]interface{}) {
+			require.Equal(t, true, orphan["deleted"])
+		}
+		require.Equal(t, [][]string{
+			{"cn=v-expired,ou=users,dc=learn,dc=example"},
+			{"cn=v-unknown,ou=users,dc=learn,dc=example"},
+		}, directory.executed)
+
+		cred, err := retrieveIssuedCredential(ctx, storage, "hashicorp", "v-expired")
+		require.NoError(t, err)
+		require.Nil(t, cred)
+	})
+
+	t.Run("expired credentials use their template data", func(t *testing.T) {
+		data := getTestDynamicRoleConfig("tagged")
+		data["deletion_ldif"] = "dn: cn={{.Username}},ou={{.DisplayName}},dc=learn,dc=example\nchangetype: delete"
+		data["orphan_search"] = map[string]interface{}{
+			"base_dn": "ou=users,dc=hashicorp,dc=com",
+			"filter":  "(description=vault-dynamic:tagged)",
+		}
+		resp, err := createDynamicRoleWithData(t, b, storage, "tagged", data)
+		require.NoError(t, err)
+		require.False(t, resp != nil && resp.IsError())
+
+		require.NoError(t, storeIssuedCredential(ctx, storage, "tagged", &issuedCredential{
+			Username:           "v-tagged",
+			DistinguishedNames: []string{"cn=v-tagged,ou=users,dc=hashicorp,dc=com"},
+			IssueTime:          time.Now().Add(-2 * time.Hour),
+			ExpireTime:         time.Now().Add(-time.Hour),
+			TemplateData: &dynamicTemplateData{
+				Username:    "v-tagged",
+				DisplayName: "token-alice",
+				RoleName:    "tagged",
+			},
+		}))
+		directory.entries = []*client.Entry{
+			client.NewEntry(&ldap.Entry{DN: "cn=v-tagged,ou=users,dc=hashicorp,dc=com"}),
+		}
+		directory.executed = nil
+
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      dynamicRolePath + "tagged/sweep",
+			Storage:   storage,
+			Data:      map[string]interface{}{"delete": true},
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError(), "unexpected error response: %v", resp)
+		require.Equal(t, [][]string{{"cn=v-tagged,ou=token-alice,dc=learn,dc=example"}}, directory.executed)
+	})
+
+	t.Run("role without orphan_search", func(t *testing.T) {
+		resp, err := createDynamicRoleWithData(t, b, storage, "plain", getTestDynamicRoleConfig("plain"))
+		require.NoError(t, err)
+		require.False(t, resp != nil && resp.IsError())
+
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      dynamicRolePath + "plain/sweep",
+			Storage:   storage,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+}
+
+func TestDynamicRole_orphanSearch(t *testing.T) {
+	ctx := context.Background()
+	b, storage := getBackend(false)
+	defer b.Cleanup(ctx)
+
+	data := getTestDynamicRoleConfig("hashicorp")
+	data["orphan_search"] = map[string]interface{}{
+		"base_dn": "ou=users,dc=hashicorp,dc=com",
+		"filter":  "description=unbalanced)",
+	}
+	_, err := createDynamicRoleWithData(t, b, storage, "hashicorp", data)
+	require.Error(t, err)
+
+	data["orphan_search"] = map[string]interface{}{
+		"base_dn":            "ou=users,dc=hashicorp,dc=com",
+		"filter":             "(description=vault-dynamic:hashicorp)",
+		"username_attribute": "uid",
+	}
+	resp, err := createDynamicRoleWithData(t, b, storage, "hashicorp", data)
+	require.NoError(t, err)
+	require.False(t, resp != nil && resp.IsError())
+
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      dynamicRolePath + "hashicorp",
+		Storage:   storage,
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"base_dn":            "ou=users,dc=hashicorp,dc=com",
+		"filter":             "(description=vault-dynamic:hashicorp)",
+		"username_attribute": "uid",
+	}, resp.Data["orphan_search"])
+
+	search := &orphanSearch{UsernameAttribute: "uid"}
+	username, err := search.username(client.NewEntry(ldap.NewEntry("cn=Alice,dc=example", map[string][]string{"uid": {"alice"}})))
+	require.NoError(t, err)
+	require.Equal(t, "alice", username)
+
+	_, err = search.username(client.NewEntry(&ldap.Entry{DN: "cn=Alice,dc=example"}))
+	require.Error(t, err)
+}
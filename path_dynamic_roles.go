@@ -10,9 +10,10 @@ import (
 	"strings"
 	"time"
 
-	"github.com/go-ldap/ldif"
+	"github.com/go-ldap/ldap/v3"
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/go-secure-stdlib/parseutil"
+	"github.com/hashicorp/vault-plugin-secrets-openldap/client"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/mitchellh/mapstructure"
@@ -38,24 +39,142 @@ func (b *backend) pathDynamicRoles() []*framework.Path {
 					Description: "Name of the role (lowercase)",
 					Required:    true,
 				},
+				"type": {
+					Type:          framework.TypeString,
+					Description:   "Type of the role. Either ldif, which creates accounts with LDIF, or membership, which adds an existing user to groups.",
+					Default:       dynamicRoleTypeLDIF,
+					AllowedValues: []interface{}{dynamicRoleTypeLDIF, dynamicRoleTypeMembership},
+				},
 				"creation_ldif": {
-					Type:        framework.TypeString,
-					Description: "LDIF string used to create new entities within the LDAP system. This LDIF can be templated.",
-					Required:    true,
+					Type: framework.TypeString,
+					Description: "LDIF string used to create new entities within the LDAP system. This LDIF can be templated. " +
+						`All LDIF of the role can include shared ldif-template fragments with {{template "name" .}}, ` +
+						"and can contain modrdn and moddn records and control lines. Required for ldif roles.",
 				},
 				"deletion_ldif": {
 					Type:        framework.TypeString,
-					Description: "LDIF string used to delete entities created within the LDAP system. This LDIF can be templated.",
-					Required:    true,
+					Description: "LDIF string used to delete entities created within the LDAP system. This LDIF can be templated. Required for ldif roles.",
 				},
 				"rollback_ldif": {
+					Type: framework.TypeString,
+					Description: "LDIF string used to rollback changes in the event of a failure to create credentials. This LDIF can be templated. " +
+						`.CreationReport lists the result of each creation_ldif record that ran, and {{if .Succeeded "<dn>"}} ` +
+						"undoes only records that succeeded. Rollbacks retried after a restart have no report, and Succeeded is always true.",
+				},
+				"renewal_ldif": {
 					Type:        framework.TypeString,
-					Description: "LDIF string used to rollback changes in the event of a failure to create credentials. This LDIF can be templated.",
+					Description: "LDIF string executed when credentials are renewed, e.g. to extend a directory-side account expiration. This LDIF can be templated.",
+				},
+				"rotation_ldif": {
+					Type: framework.TypeString,
+					Description: "LDIF string used to set a new password for an active credential, requested with " +
+						"creds/<name>/rotate. This LDIF can be templated.",
 				},
 				"username_template": {
 					Type:        framework.TypeString,
 					Description: "The template used to create a username",
 				},
+				"username_collision_retries": {
+					Type: framework.TypeInt,
+					Description: "Number of times credential creation is retried with a new username and password " +
+						"if an entry already exists for the generated username. The rollback_ldif is run before each " +
+						"retry. Defaults to 0, which disables retries.",
+				},
+				"orphan_search": {
+					Type: framework.TypeMap,
+					Description: "Search for the entries created by the role, used by role/<name>/sweep to find entries " +
+						"without a live credential. Takes base_dn, filter, and optionally username_attribute, which " +
+						"defaults to the value of the first RDN. Only supported by ldif roles.",
+				},
+				"allowed_parameters": {
+					Type: framework.TypeSlice,
+					Description: "Parameters that callers can supply as params when requesting credentials, available " +
+						"to LDIF templates as .Params.<name> after DN escaping. Each takes a name, a pattern that values " +
+						"must fully match and/or a list of allowed_values, an optional default, and multiple, which " +
+						"makes the parameter a comma separated list. Parameters without a default are required, and " +
+						"values with control characters are rejected. Only supported by ldif roles.",
+				},
+				"max_active_credentials": {
+					Type: framework.TypeInt,
+					Description: "Maximum number of live credentials of the role. Requests for credentials beyond it " +
+						"fail until leases are revoked. Defaults to 0, which is unlimited. Only supported by ldif roles.",
+				},
+				"max_active_per_entity": {
+					Type: framework.TypeInt,
+					Description: "Maximum number of live credentials of the role per requesting entity. Requests " +
+						"without an entity, such as those made with root tokens, are not limited by it. Defaults to 0, " +
+						"which is unlimited. Only supported by ldif roles.",
+				},
+				"verify_credentials": {
+					Type: framework.TypeBool,
+					Description: "Bind as each new credential before returning it, retrying with backoff until the " +
+						"directory accepts it or verify_timeout passes, so that credentials are usable on replicas. " +
+						"Credentials that can't be verified are removed with the rollback_ldif, or the deletion_ldif " +
+						"if there is none. Only supported by ldif roles.",
+				},
+				"verify_bind_as": {
+					Type: framework.TypeString,
+					Description: "How verify_credentials binds: dn binds as the first entry added by the creation_ldif, " +
+						"and upn binds as the username at the configured upndomain. Defaults to dn.",
+				},
+				"verify_all_urls": {
+					Type: framework.TypeBool,
+					Description: "Verify credentials against each configured url rather than the first that " +
+						"can be reached.",
+				},
+				"verify_timeout": {
+					Type: framework.TypeDurationSecond,
+					Description: "How long verify_credentials retries binding, in total across urls. " +
+						"Defaults to " + defaultVerifyTimeout.String() + ".",
+				},
+				"credential_type": {
+					Type: framework.TypeString,
+					Description: "Type of credential issued: password, the default, or ssh_key, which generates an SSH " +
+						"key pair whose public key is available to LDIF templates as .PublicKey in the authorized_keys " +
+						"format, and whose private key is returned with the credential. Only supported by ldif roles.",
+				},
+				"ssh_key_type": {
+					Type:        framework.TypeString,
+					Description: "Type of SSH key generated by ssh_key roles, ed25519 or rsa. Defaults to ed25519.",
+				},
+				"ssh_key_bits": {
+					Type: framework.TypeInt,
+					Description: "Size of the RSA keys generated by ssh_key roles. Defaults to " +
+						fmt.Sprint(defaultSSHKeyBits) + ", and must be at least " + fmt.Sprint(minSSHKeyBits) + ".",
+				},
+				"generate_password": {
+					Type: framework.TypeBool,
+					Description: "Generate a password for the credentials of ssh_key roles too. Required for " +
+						"verify_credentials and rotation.",
+				},
+				"response_template": {
+					Type: framework.TypeMap,
+					Description: "Named templates rendered with the same data as the LDIF, whose outputs are added to " +
+						"the credential response under their names, e.g. upn={{.Username}}@example.com. Names cannot " +
+						"replace the username, password, distinguished_names, or SSH key fields. Only supported by " +
+						"ldif roles.",
+				},
+				"group_dns": {
+					Type:        framework.TypeStringSlice,
+					Description: "DNs of the groups an existing user is added to. Required for membership roles.",
+				},
+				"member_attribute": {
+					Type: framework.TypeString,
+					Description: "Group attribute that lists members for membership roles. Either member or uniqueMember, " +
+						"which hold the user's DN, or memberUid, which holds the user's uid. Defaults to member.",
+				},
+				"user_dn_template": {
+					Type: framework.TypeString,
+					Description: "Template for the DN of the user that membership roles add to groups, e.g. using entity_meta. " +
+						"If unset, the DN is supplied as user_dn when requesting credentials.",
+				},
+				"allowed_user_dns": {
+					Type: framework.TypeStringSlice,
+					Description: "Patterns of the DNs that can be supplied as user_dn when requesting credentials from " +
+						"membership roles without a user_dn_template, e.g. uid=*,ou=people,dc=example,dc=com. Patterns " +
+						"are matched RDN by RDN, with glob patterns as attribute values, so DNs with more or fewer RDNs " +
+						"never match. Matching is case-insensitive. If unset, no user_dn is allowed.",
+				},
 				"default_ttl": {
 					Type:        framework.TypeDurationSecond,
 					Description: "Default TTL for dynamic credentials",
@@ -123,7 +242,16 @@ func dynamicSecretCreds(b *backend) *framework.Secret {
 				Type: framework.TypeStringSlice,
 				Description: "List of the distinguished names (DN) created. Each name in this list corresponds to" +
 					"each action taken within the creation_ldif statements. This does not de-duplicate entries, " +
-					"so this will have one entry for each LDIF statement within creation_ldif.",
+					"so this will have one entry for each LDIF statement within creation_ldif. For membership " +
+					"roles, this lists the groups the user was added to.",
+			},
+			"user_dn": {
+				Type:        framework.TypeString,
+				Description: "DN of the existing user added to groups by a membership role",
+			},
+			"group_dns": {
+				Type:        framework.TypeStringSlice,
+				Description: "DNs of the groups the user is a member of for the duration of a membership role's lease",
 			},
 		},
 
@@ -134,12 +262,16 @@ func dynamicSecretCreds(b *backend) *framework.Secret {
 
 func (b *backend) pathDynamicRoleCreateUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	rawData := data.Raw
-	err := convertToDuration(rawData, "default_ttl", "max_ttl")
+	err := convertToDuration(rawData, "default_ttl", "max_ttl", "verify_timeout")
 	if err != nil {
 		return nil, fmt.Errorf("failed to convert TTLs to duration: %w", err)
 	}
 
 	roleName := data.Get("name").(string)
+
+	b.ldifTemplateLock.Lock()
+	defer b.ldifTemplateLock.Unlock()
+
 	dRole, err := retrieveDynamicRole(ctx, req.Storage, roleName)
 	if err != nil {
 		return nil, fmt.Errorf("unable to look for existing role: %w", err)
@@ -150,6 +282,14 @@ func (b *backend) pathDynamicRoleCreateUpdate(ctx context.Context, req *logical.
 		}
 		dRole = &dynamicRole{}
 	}
+	// Decoding merges into existing maps and slices, so response_template
+	// and allowed_user_dns are replaced as a whole.
+	if _, ok := rawData["response_template"]; ok {
+		dRole.ResponseTemplate = nil
+	}
+	if _, ok := rawData["allowed_user_dns"]; ok {
+		dRole.AllowedUserDNs = nil
+	}
 	err = mapstructure.WeakDecode(rawData, dRole)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode request: %w", err)
@@ -158,6 +298,38 @@ func (b *backend) pathDynamicRoleCreateUpdate(ctx context.Context, req *logical.
 	dRole.CreationLDIF = decodeBase64(dRole.CreationLDIF)
 	dRole.RollbackLDIF = decodeBase64(dRole.RollbackLDIF)
 	dRole.DeletionLDIF = decodeBase64(dRole.DeletionLDIF)
+	dRole.RenewalLDIF = decodeBase64(dRole.RenewalLDIF)
+	dRole.RotationLDIF = decodeBase64(dRole.RotationLDIF)
+
+	// LDIF written with this request replaces the LDIF as written before, and
+	// shared fragments are only read if the role includes any.
+	var includes bool
+	for field, value := range dRole.ldifFields() {
+		if _, ok := rawData[field]; ok {
+			delete(dRole.LDIFSources, field)
+		}
+		if templateIncludeRegex.MatchString(*value) {
+			includes = true
+		}
+	}
+	var fragments map[string]string
+	if includes || len(dRole.LDIFSources) > 0 {
+		fragments, err = listLDIFTemplates(ctx, req.Storage)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ldif templates: %w", err)
+		}
+	}
+	if err := dRole.resolveLDIF(fragments); err != nil {
+		return nil, err
+	}
+
+	if dRole.OrphanSearch != nil && *dRole.OrphanSearch == (orphanSearch{}) {
+		dRole.OrphanSearch = nil
+	}
+
+	if dRole.roleType() == dynamicRoleTypeMembership && dRole.MemberAttribute == "" {
+		dRole.MemberAttribute = client.MemberAttributeMember
+	}
 
 	err = validateDynamicRole(dRole)
 	if err != nil {
@@ -169,13 +341,39 @@ func (b *backend) pathDynamicRoleCreateUpdate(ctx context.Context, req *logical.
 		return nil, fmt.Errorf("failed to save dynamic role: %w", err)
 	}
 
+	// Count the live credentials of roles that are given quotas, since they
+	// may have been issued before the counts existed.
+	var resp *logical.Response
+	if dRole.MaxActiveCredentials > 0 || dRole.MaxActivePerEntity > 0 {
+		if err := b.reconcileActiveCredentials(ctx, req.Storage, roleName); err != nil {
+			b.Logger().Warn("failed to reconcile active credentials", "role", roleName, "error", err)
+			resp = &logical.Response{}
+			resp.AddWarning("Active credentials could not be counted, so quotas may not be enforced accurately until they are reconciled.")
+		}
+	}
+
 	// Send event notification for role create/update
 	b.ldapEvent(ctx, fmt.Sprintf("role-%s", req.Operation), req.Path, roleName, true)
 
-	return nil, nil
+	return resp, nil
 }
 
 func validateDynamicRole(dRole *dynamicRole) error {
+	switch dRole.roleType() {
+	case dynamicRoleTypeLDIF:
+		return validateLDIFRole(dRole)
+	case dynamicRoleTypeMembership:
+		return validateMembershipRole(dRole)
+	default:
+		return fmt.Errorf("invalid type %q, must be one of %q or %q", dRole.Type, dynamicRoleTypeLDIF, dynamicRoleTypeMembership)
+	}
+}
+
+func validateLDIFRole(dRole *dynamicRole) error {
+	if len(dRole.GroupDNs) > 0 || dRole.MemberAttribute != "" || dRole.UserDNTemplate != "" || len(dRole.AllowedUserDNs) > 0 {
+		return fmt.Errorf("group_dns, member_attribute, user_dn_template, and allowed_user_dns are only supported by membership roles")
+	}
+
 	if dRole.CreationLDIF == "" {
 		return fmt.Errorf("missing creation_ldif")
 	}
@@ -184,23 +382,127 @@ func validateDynamicRole(dRole *dynamicRole) error {
 		return fmt.Errorf("missing deletion_ldif")
 	}
 
-	err := assertValidLDIFTemplate(dRole.CreationLDIF)
+	if err := validateAllowedParameters(dRole.AllowedParameters); err != nil {
+		return fmt.Errorf("invalid allowed_parameters: %w", err)
+	}
+	params := sampleParams(dRole.AllowedParameters)
+
+	err := assertValidLDIFTemplate(dRole.CreationLDIF, params)
 	if err != nil {
 		return fmt.Errorf("invalid creation_ldif: %w", err)
 	}
 
-	err = assertValidLDIFTemplate(dRole.DeletionLDIF)
+	err = assertValidLDIFTemplate(dRole.DeletionLDIF, params)
 	if err != nil {
 		return fmt.Errorf("invalid deletion_ldif: %w", err)
 	}
 
 	if dRole.RollbackLDIF != "" {
-		err = assertValidLDIFTemplate(dRole.RollbackLDIF)
+		err = assertValidLDIFTemplate(dRole.RollbackLDIF, params)
 		if err != nil {
 			return fmt.Errorf("invalid rollback_ldif: %w", err)
 		}
 	}
 
+	if dRole.RenewalLDIF != "" {
+		err = assertValidLDIFTemplate(dRole.RenewalLDIF, params)
+		if err != nil {
+			return fmt.Errorf("invalid renewal_ldif: %w", err)
+		}
+	}
+
+	if dRole.RotationLDIF != "" {
+		err = assertValidLDIFTemplate(dRole.RotationLDIF, params)
+		if err != nil {
+			return fmt.Errorf("invalid rotation_ldif: %w", err)
+		}
+	}
+
+	if dRole.UsernameCollisionRetries < 0 {
+		return fmt.Errorf("username_collision_retries must not be negative")
+	}
+
+	if dRole.MaxActiveCredentials < 0 || dRole.MaxActivePerEntity < 0 {
+		return fmt.Errorf("max_active_credentials and max_active_per_entity must not be negative")
+	}
+
+	if err := dRole.validateCredentialType(); err != nil {
+		return err
+	}
+
+	if err := validateResponseTemplate(dRole.ResponseTemplate, dynamicResponseFields, sampleTemplateData(params)); err != nil {
+		return err
+	}
+
+	switch dRole.VerifyBindAs {
+	case "", verifyBindAsDN, verifyBindAsUPN:
+	default:
+		return fmt.Errorf("invalid verify_bind_as %q, must be one of %q or %q", dRole.VerifyBindAs, verifyBindAsDN, verifyBindAsUPN)
+	}
+	if dRole.VerifyTimeout < 0 {
+		return fmt.Errorf("verify_timeout must not be negative")
+	}
+
+	if dRole.OrphanSearch != nil {
+		if err := dRole.OrphanSearch.validate(); err != nil {
+			return fmt.Errorf("invalid orphan_search: %w", err)
+		}
+	}
+
+	if dRole.UsernameTemplate != "" {
+		_, err = generateUsername(&logical.Request{DisplayName: "testdisplayname"}, dRole, templateEntity{})
+		if err != nil {
+			return fmt.Errorf("invalid username_template: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func validateMembershipRole(dRole *dynamicRole) error {
+	if dRole.CreationLDIF != "" || dRole.DeletionLDIF != "" || dRole.RollbackLDIF != "" ||
+		dRole.RenewalLDIF != "" || dRole.RotationLDIF != "" || dRole.UsernameTemplate != "" ||
+		dRole.UsernameCollisionRetries != 0 || dRole.OrphanSearch != nil || len(dRole.AllowedParameters) > 0 ||
+		dRole.MaxActiveCredentials != 0 || dRole.MaxActivePerEntity != 0 || dRole.VerifyCredentials ||
+		dRole.VerifyBindAs != "" || dRole.VerifyAllURLs || dRole.VerifyTimeout != 0 || dRole.CredentialType != "" ||
+		dRole.SSHKeyType != "" || dRole.SSHKeyBits != 0 || dRole.GeneratePassword || len(dRole.ResponseTemplate) > 0 {
+		return fmt.Errorf("creation_ldif, deletion_ldif, rollback_ldif, renewal_ldif, rotation_ldif, username_template, " +
+			"username_collision_retries, orphan_search, allowed_parameters, max_active_credentials, max_active_per_entity, " +
+			"credential_type, the ssh_key_ and verify_ options, generate_password, and response_template are not supported " +
+			"by membership roles")
+	}
+
+	if len(dRole.GroupDNs) == 0 {
+		return fmt.Errorf("missing group_dns")
+	}
+	for _, groupDN := range dRole.GroupDNs {
+		if _, err := ldap.ParseDN(groupDN); err != nil {
+			return fmt.Errorf("invalid group DN %q: %w", groupDN, err)
+		}
+	}
+
+	if !client.ValidMemberAttribute(dRole.MemberAttribute) {
+		return fmt.Errorf("invalid member_attribute %q, must be one of %q", dRole.MemberAttribute, client.SupportedMemberAttributes())
+	}
+
+	if dRole.UserDNTemplate != "" {
+		_, err := renderUserDN(dRole, dynamicTemplateData{
+			DisplayName: "testdisplayname",
+			RoleName:    "testrolename",
+		})
+		if err != nil {
+			return fmt.Errorf("invalid user_dn_template: %w", err)
+		}
+		if len(dRole.AllowedUserDNs) > 0 {
+			return fmt.Errorf("allowed_user_dns cannot be set with a user_dn_template")
+		}
+	}
+	for _, pattern := range dRole.AllowedUserDNs {
+		if err := validateDNPattern(pattern); err != nil {
+			return fmt.Errorf("invalid allowed_user_dns pattern %q: %w", pattern, err)
+		}
+	}
+
 	return nil
 }
 
@@ -237,33 +539,39 @@ func decodeBase64(str string) string {
 	return string(decoded)
 }
 
-func assertValidLDIFTemplate(rawTemplate string) error {
-	// Test the template to ensure there aren't any errors in the template syntax
+// sampleTemplateData returns the data that templates of a role are rendered
+// with when they are validated.
+func sampleTemplateData(params map[string]interface{}) dynamicTemplateData {
 	now := time.Now()
 	exp := now.Add(24 * time.Hour)
-	testTemplateData := dynamicTemplateData{
+	return dynamicTemplateData{
 		Username:              "testuser",
 		Password:              "testpass",
+		PublicKey:             sampleSSHPublicKey,
 		DisplayName:           "testdisplayname",
 		RoleName:              "testrolename",
 		IssueTime:             now.Format(time.RFC3339),
 		IssueTimeSeconds:      now.Unix(),
 		ExpirationTime:        exp.Format(time.RFC3339),
 		ExpirationTimeSeconds: exp.Unix(),
+		Params:                params,
 	}
+}
 
-	testLDIF, err := applyTemplate(rawTemplate, testTemplateData)
+func assertValidLDIFTemplate(rawTemplate string, params map[string]interface{}) error {
+	// Test the template to ensure there aren't any errors in the template syntax
+	testLDIF, err := applyTemplate(rawTemplate, sampleTemplateData(params))
 	if err != nil {
 		return fmt.Errorf("invalid template: %w", err)
 	}
 
 	// Test the LDIF to ensure there aren't any errors in the syntax
-	entries, err := ldif.Parse(testLDIF)
+	entries, err := client.ParseLDIF(testLDIF)
 	if err != nil {
 		return fmt.Errorf("LDIF is invalid: %w", err)
 	}
 
-	if len(entries.Entries) == 0 {
+	if len(entries) == 0 {
 		return fmt.Errorf("must specify at least one LDIF entry")
 	}
 
@@ -283,14 +591,54 @@ func (b *backend) pathDynamicRoleRead(ctx context.Context, req *logical.Request,
 
 	resp := &logical.Response{
 		Data: map[string]interface{}{
-			"creation_ldif":     dRole.CreationLDIF,
-			"deletion_ldif":     dRole.DeletionLDIF,
-			"rollback_ldif":     dRole.RollbackLDIF,
-			"username_template": dRole.UsernameTemplate,
-			"default_ttl":       dRole.DefaultTTL.Seconds(),
-			"max_ttl":           dRole.MaxTTL.Seconds(),
+			"creation_ldif":              dRole.CreationLDIF,
+			"deletion_ldif":              dRole.DeletionLDIF,
+			"rollback_ldif":              dRole.RollbackLDIF,
+			"renewal_ldif":               dRole.RenewalLDIF,
+			"rotation_ldif":              dRole.RotationLDIF,
+			"username_template":          dRole.UsernameTemplate,
+			"username_collision_retries": dRole.UsernameCollisionRetries,
+			"max_active_credentials":     dRole.MaxActiveCredentials,
+			"max_active_per_entity":      dRole.MaxActivePerEntity,
+			"verify_credentials":         dRole.VerifyCredentials,
+			"verify_bind_as":             dRole.verifyBindAs(),
+			"verify_all_urls":            dRole.VerifyAllURLs,
+			"verify_timeout":             dRole.verifyTimeout().Seconds(),
+			"credential_type":            dRole.credentialType(),
+			"default_ttl":                dRole.DefaultTTL.Seconds(),
+			"max_ttl":                    dRole.MaxTTL.Seconds(),
+			"type":                       dRole.roleType(),
 		},
 	}
+	if dRole.OrphanSearch != nil {
+		resp.Data["orphan_search"] = dRole.OrphanSearch.toMap()
+	}
+	if len(dRole.LDIFSources) > 0 {
+		resp.Data["ldif_sources"] = dRole.LDIFSources
+	}
+	if len(dRole.ResponseTemplate) > 0 {
+		resp.Data["response_template"] = dRole.ResponseTemplate
+	}
+	if len(dRole.AllowedParameters) > 0 {
+		allowed := make([]map[string]interface{}, 0, len(dRole.AllowedParameters))
+		for i := range dRole.AllowedParameters {
+			allowed = append(allowed, dRole.AllowedParameters[i].toMap())
+		}
+		resp.Data["allowed_parameters"] = allowed
+	}
+	if dRole.credentialType() == credentialTypeSSHKey {
+		resp.Data["ssh_key_type"] = dRole.sshKeyType()
+		if dRole.sshKeyType() == sshKeyTypeRSA {
+			resp.Data["ssh_key_bits"] = dRole.sshKeyBits()
+		}
+		resp.Data["generate_password"] = dRole.GeneratePassword
+	}
+	if dRole.roleType() == dynamicRoleTypeMembership {
+		resp.Data["group_dns"] = dRole.GroupDNs
+		resp.Data["member_attribute"] = dRole.MemberAttribute
+		resp.Data["user_dn_template"] = dRole.UserDNTemplate
+		resp.Data["allowed_user_dns"] = dRole.AllowedUserDNs
+	}
 	return resp, nil
 }
 
@@ -865,12 +865,23 @@ func TestDynamicRoleRead(t *testing.T) {
 			storageErr: nil,
 			expectedResp: &logical.Response{
 				Data: map[string]interface{}{
-					"creation_ldif":     ldifCreationTemplate,
-					"rollback_ldif":     ldifRollbackTemplate,
-					"deletion_ldif":     ldifDeleteTemplate,
-					"username_template": "v-foo-{{.RoleName}}-{{random 20}}-{{unix_time}}",
-					"default_ttl":       (24 * time.Hour).Seconds(),
-					"max_ttl":           (5 * 24 * time.Hour).Seconds(),
+					"creation_ldif":              ldifCreationTemplate,
+					"rollback_ldif":              ldifRollbackTemplate,
+					"deletion_ldif":              ldifDeleteTemplate,
+					"renewal_ldif":               "",
+					"rotation_ldif":              "",
+					"username_template":          "v-foo-{{.RoleName}}-{{random 20}}-{{unix_time}}",
+					"username_collision_retries": 0,
+					"max_active_credentials":     0,
+					"max_active_per_entity":      0,
+					"verify_credentials":         false,
+					"verify_bind_as":             "dn",
+					"verify_all_urls":            false,
+					"verify_timeout":             (30 * time.Second).Seconds(),
+					"credential_type":            "password",
+					"default_ttl":                (24 * time.Hour).Seconds(),
+					"max_ttl":                    (5 * 24 * time.Hour).Seconds(),
+					"type":                       dynamicRoleTypeLDIF,
 				},
 			},
 			expectErr: false,
@@ -0,0 +1,241 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/template"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	ldifTemplateHelpSynopsis    = "Manage LDIF fragments shared by dynamic roles."
+	ldifTemplateHelpDescription = `
+This path manages named LDIF fragments that the LDIF of dynamic roles, and
+other fragments, include with {{template "name" .}}. Includes are resolved
+when a role is written, and role reads show the resolved LDIF along with the
+LDIF as written in ldif_sources. Writing a fragment resolves and validates the
+roles that include it again, and fails without changing anything if any of
+them would become invalid. Fragments that are included by roles can't be
+deleted.
+`
+)
+
+func (b *backend) pathLDIFTemplates() []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: strings.TrimSuffix(ldifTemplatePath, "/") + "/" + framework.GenericNameRegex("name"),
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: operationPrefixLDAP,
+				OperationSuffix: "ldif-template",
+			},
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeLowerCaseString,
+					Description: "Name of the LDIF fragment.",
+					Required:    true,
+				},
+				"template": {
+					Type:        framework.TypeString,
+					Description: "The LDIF fragment. This LDIF can be templated and can be base64 encoded.",
+				},
+			},
+			ExistenceCheck: b.pathLDIFTemplateExistenceCheck,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: b.pathLDIFTemplateWrite,
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathLDIFTemplateWrite,
+				},
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathLDIFTemplateRead,
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.pathLDIFTemplateDelete,
+				},
+			},
+			HelpSynopsis:    ldifTemplateHelpSynopsis,
+			HelpDescription: ldifTemplateHelpDescription,
+		},
+		{
+			Pattern: strings.TrimSuffix(ldifTemplatePath, "/") + "/?$",
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: operationPrefixLDAP,
+				OperationVerb:   "list",
+				OperationSuffix: "ldif-templates",
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: b.pathLDIFTemplateList,
+				},
+			},
+			HelpSynopsis:    ldifTemplateHelpSynopsis,
+			HelpDescription: ldifTemplateHelpDescription,
+		},
+	}
+}
+
+func (b *backend) pathLDIFTemplateExistenceCheck(ctx context.Context, req *logical.Request, data *framework.FieldData) (bool, error) {
+	t, err := retrieveLDIFTemplate(ctx, req.Storage, data.Get("name").(string))
+	if err != nil {
+		return false, err
+	}
+	return t != nil, nil
+}
+
+func (b *backend) pathLDIFTemplateWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	rawTemplate := decodeBase64(data.Get("template").(string))
+	if rawTemplate == "" {
+		return logical.ErrorResponse("missing template"), nil
+	}
+
+	b.ldifTemplateLock.Lock()
+	defer b.ldifTemplateLock.Unlock()
+
+	fragments, err := listLDIFTemplates(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ldif templates: %w", err)
+	}
+	fragments[name] = rawTemplate
+
+	resolved, _, err := resolveLDIFTemplates(rawTemplate, fragments)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	_, err = template.NewTemplate(append(templateFunctions(templateEntity{}), template.Template(resolved))...)
+	if err != nil {
+		return logical.ErrorResponse("invalid template: %s", err), nil
+	}
+
+	roles, err := b.resolveRolesLDIF(ctx, req.Storage, fragments)
+	if err != nil {
+		return logical.ErrorResponse("roles would become invalid: %s", err), nil
+	}
+
+	if err := storeLDIFTemplate(ctx, req.Storage, &ldifTemplate{Name: name, Template: rawTemplate}); err != nil {
+		return nil, err
+	}
+	updated := make([]string, 0, len(roles))
+	for _, dRole := range roles {
+		if err := storeDynamicRole(ctx, req.Storage, dRole); err != nil {
+			return nil, fmt.Errorf("failed to update role %q: %w", dRole.Name, err)
+		}
+		updated = append(updated, dRole.Name)
+	}
+
+	b.ldapEvent(ctx, fmt.Sprintf("ldif-template-%s", req.Operation), req.Path, name, true)
+	if len(updated) == 0 {
+		return nil, nil
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"updated_roles": updated,
+		},
+	}, nil
+}
+
+func (b *backend) pathLDIFTemplateRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	t, err := retrieveLDIFTemplate(ctx, req.Storage, data.Get("name").(string))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ldif template: %w", err)
+	}
+	if t == nil {
+		return nil, nil
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"template": t.Template,
+		},
+	}, nil
+}
+
+func (b *backend) pathLDIFTemplateDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	b.ldifTemplateLock.Lock()
+	defer b.ldifTemplateLock.Unlock()
+
+	fragments, err := listLDIFTemplates(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ldif templates: %w", err)
+	}
+	delete(fragments, name)
+	for other, fragment := range fragments {
+		if _, _, err := resolveLDIFTemplates(fragment, fragments); err != nil {
+			return logical.ErrorResponse("ldif template %q is included by ldif template %q", name, other), nil
+		}
+	}
+	if _, err := b.resolveRolesLDIF(ctx, req.Storage, fragments); err != nil {
+		return logical.ErrorResponse("ldif template %q is included by roles: %s", name, err), nil
+	}
+
+	if err := req.Storage.Delete(ctx, path.Join(ldifTemplatePath, name)); err != nil {
+		return nil, fmt.Errorf("failed to delete ldif template: %w", err)
+	}
+	b.ldapEvent(ctx, "ldif-template-delete", req.Path, name, true)
+	return nil, nil
+}
+
+func (b *backend) pathLDIFTemplateList(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	names, err := req.Storage.List(ctx, ldifTemplatePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ldif templates: %w", err)
+	}
+	return logical.ListResponse(names), nil
+}
+
+// resolveRolesLDIF resolves the LDIF of the roles that include shared
+// fragments with the given fragments and validates them. It returns the roles
+// whose resolved LDIF changed, or an error naming the roles that became
+// invalid.
+func (b *backend) resolveRolesLDIF(ctx context.Context, s logical.Storage, fragments map[string]string) ([]*dynamicRole, error) {
+	roleNames, err := listDynamicRoleNames(ctx, s, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dynamic roles: %w", err)
+	}
+
+	var changed []*dynamicRole
+	var invalid []string
+	for _, roleName := range roleNames {
+		dRole, err := retrieveDynamicRole(ctx, s, roleName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read role %q: %w", roleName, err)
+		}
+		if dRole == nil || len(dRole.LDIFSources) == 0 {
+			continue
+		}
+
+		resolved := *dRole
+		resolved.LDIFSources = make(map[string]string, len(dRole.LDIFSources))
+		for field, source := range dRole.LDIFSources {
+			resolved.LDIFSources[field] = source
+		}
+		err = resolved.resolveLDIF(fragments)
+		if err == nil {
+			err = validateDynamicRole(&resolved)
+		}
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("%s: %s", roleName, err))
+			continue
+		}
+		if resolved.CreationLDIF != dRole.CreationLDIF || resolved.DeletionLDIF != dRole.DeletionLDIF ||
+			resolved.RollbackLDIF != dRole.RollbackLDIF || resolved.RenewalLDIF != dRole.RenewalLDIF ||
+			resolved.RotationLDIF != dRole.RotationLDIF {
+			changed = append(changed, &resolved)
+		}
+	}
+	if len(invalid) > 0 {
+		sort.Strings(invalid)
+		return nil, fmt.Errorf("%s", strings.Join(invalid, "; "))
+	}
+	return changed, nil
+}
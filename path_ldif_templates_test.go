@@ -0,0 +1,139 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLDIFTemplates(t *testing.T) {
+	ctx := context.Background()
+	b, storage := getBackend(false)
+	defer b.Cleanup(ctx)
+	configureOpenLDAPMount(t, b, storage)
+
+	writeFragment := func(name, template string) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      ldifTemplatePath + name,
+			Storage:   storage,
+			Data:      map[string]interface{}{"template": template},
+		})
+		require.NoError(t, err)
+		return resp
+	}
+	readRole := func(name string) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      dynamicRolePath + name,
+			Storage:   storage,
+		})
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := writeFragment("dev-groups", "memberOf: cn=dev,ou=groups,dc=hashicorp,dc=com")
+	require.False(t, resp != nil && resp.IsError(), "unexpected error response: %v", resp)
+
+	creationLDIF := `dn: cn={{.Username}},ou=users,dc=hashicorp,dc=com
+objectClass: person
+objectClass: top
+cn: learn
+sn: learn
+{{template "dev-groups" .}}
+userPassword: {{.Password}}`
+	for _, name := range []string{"dev", "org/dev"} {
+		data := getTestDynamicRoleConfig(name)
+		data["creation_ldif"] = creationLDIF
+		resp, err := createDynamicRoleWithData(t, b, storage, name, data)
+		require.NoError(t, err)
+		require.False(t, resp != nil && resp.IsError(), "unexpected error response: %v", resp)
+	}
+
+	t.Run("role read shows resolved LDIF", func(t *testing.T) {
+		resp := readRole("dev")
+		require.Contains(t, resp.Data["creation_ldif"], "memberOf: cn=dev,ou=groups,dc=hashicorp,dc=com")
+		require.NotContains(t, resp.Data["creation_ldif"], "{{template")
+		require.Equal(t, map[string]string{"creation_ldif": creationLDIF}, resp.Data["ldif_sources"])
+	})
+
+	t.Run("credentials use resolved LDIF", func(t *testing.T) {
+		recorder := &executeRecordingClient{}
+		b.client = recorder
+		defer func() { b.client = &fakeLdapClient{} }()
+
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      dynamicCredPath + "dev",
+			Storage:   storage,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError(), "unexpected error response: %v", resp)
+		entry := recorder.entries[0][0].Entry
+		require.Equal(t, "cn=dev,ou=groups,dc=hashicorp,dc=com", entry.GetAttributeValue("memberOf"))
+	})
+
+	t.Run("update re-resolves roles", func(t *testing.T) {
+		resp := writeFragment("dev-groups", "memberOf: cn=developers,ou=groups,dc=hashicorp,dc=com")
+		require.False(t, resp.IsError(), "unexpected error response: %v", resp)
+		require.ElementsMatch(t, []string{"dev", "org/dev"}, resp.Data["updated_roles"])
+
+		for _, name := range []string{"dev", "org/dev"} {
+			require.Contains(t, readRole(name).Data["creation_ldif"], "cn=developers,ou=groups")
+		}
+	})
+
+	t.Run("update that invalidates roles is rejected", func(t *testing.T) {
+		resp := writeFragment("dev-groups", "memberOf: {{.Unknown}}")
+		require.True(t, resp.IsError())
+		require.Contains(t, resp.Error().Error(), "org/dev")
+		require.Contains(t, readRole("dev").Data["creation_ldif"], "cn=developers,ou=groups")
+
+		resp = writeFragment("dev-groups", "{{template \"dev-groups\" .}}")
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("fragments in use can't be deleted", func(t *testing.T) {
+		deleteFragment := func() *logical.Response {
+			resp, err := b.HandleRequest(ctx, &logical.Request{
+				Operation: logical.DeleteOperation,
+				Path:      ldifTemplatePath + "dev-groups",
+				Storage:   storage,
+			})
+			require.NoError(t, err)
+			return resp
+		}
+		require.True(t, deleteFragment().IsError())
+
+		// Writing the LDIF without includes releases the fragment.
+		for _, name := range []string{"dev", "org/dev"} {
+			resp, err := createDynamicRoleWithData(t, b, storage, name, getTestDynamicRoleConfig(name))
+			require.NoError(t, err)
+			require.False(t, resp != nil && resp.IsError(), "unexpected error response: %v", resp)
+			require.NotContains(t, readRole(name).Data, "ldif_sources")
+		}
+		require.Nil(t, deleteFragment())
+
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ListOperation,
+			Path:      ldifTemplatePath,
+			Storage:   storage,
+		})
+		require.NoError(t, err)
+		require.Empty(t, resp.Data["keys"])
+	})
+
+	t.Run("role with unknown fragment", func(t *testing.T) {
+		data := getTestDynamicRoleConfig("invalid")
+		data["creation_ldif"] = creationLDIF
+		_, err := createDynamicRoleWithData(t, b, storage, "invalid", data)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,157 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func (b *backend) pathRevocationFailures() []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: strings.TrimSuffix(revocationFailuresPath, "/") + "/" + framework.GenericNameRegex("id") + "/retry$",
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: operationPrefixLDAP,
+				OperationVerb:   "retry",
+				OperationSuffix: "revocation-failure",
+			},
+			Fields: map[string]*framework.FieldSchema{
+				"id": {
+					Type:        framework.TypeString,
+					Description: "ID of the revocation failure.",
+					Required:    true,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback:                    b.pathRevocationFailureRetry,
+					ForwardPerformanceStandby:   true,
+					ForwardPerformanceSecondary: true,
+				},
+			},
+			HelpSynopsis:    "Retry the deletion of a revoked dynamic credential.",
+			HelpDescription: "Runs the deletion LDIF of the revocation failure again and removes the failure if it succeeds.",
+		},
+		{
+			Pattern: strings.TrimSuffix(revocationFailuresPath, "/") + "/" + framework.GenericNameRegex("id"),
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: operationPrefixLDAP,
+				OperationSuffix: "revocation-failure",
+			},
+			Fields: map[string]*framework.FieldSchema{
+				"id": {
+					Type:        framework.TypeString,
+					Description: "ID of the revocation failure.",
+					Required:    true,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathRevocationFailureRead,
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback:                    b.pathRevocationFailureDelete,
+					ForwardPerformanceStandby:   true,
+					ForwardPerformanceSecondary: true,
+					DisplayAttrs: &framework.DisplayAttributes{
+						OperationVerb: "purge",
+					},
+				},
+			},
+			HelpSynopsis: "Read or purge the deletion of a revoked dynamic credential that failed.",
+			HelpDescription: "Reading returns the role, username, and the DNs of the rendered deletion LDIF of the " +
+				"revocation failure. Deleting purges it without running the deletion LDIF.",
+		},
+		{
+			Pattern: strings.TrimSuffix(revocationFailuresPath, "/") + "/?$",
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: operationPrefixLDAP,
+				OperationVerb:   "list",
+				OperationSuffix: "revocation-failures",
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: b.pathRevocationFailuresList,
+				},
+			},
+			HelpSynopsis: "List the deletions of revoked dynamic credentials that failed.",
+			HelpDescription: "Deletions of revoked dynamic credentials that fail are retried by the plugin with a " +
+				"backoff. Deletions that still fail after " + fmt.Sprint(revocationMaxAttempts) + " attempts are " +
+				"listed here with the DNs of their rendered deletion LDIF, and can be retried or purged.",
+		},
+	}
+}
+
+func (b *backend) pathRevocationFailuresList(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	revocations, err := listFailedRevocations(ctx, req.Storage, revocationFailuresPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revocation failures: %w", err)
+	}
+
+	keys := make([]string, 0, len(revocations))
+	keyInfo := make(map[string]interface{}, len(revocations))
+	for _, r := range revocations {
+		keys = append(keys, r.ID)
+		keyInfo[r.ID] = r.toMap()
+	}
+	return logical.ListResponseWithInfo(keys, keyInfo), nil
+}
+
+func (b *backend) pathRevocationFailureRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	r, err := retrieveFailedRevocation(ctx, req.Storage, revocationFailuresPath, data.Get("id").(string))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read revocation failure: %w", err)
+	}
+	if r == nil {
+		return nil, nil
+	}
+	return &logical.Response{
+		Data: r.toMap(),
+	}, nil
+}
+
+func (b *backend) pathRevocationFailureDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.revocationLock.Lock()
+	defer b.revocationLock.Unlock()
+
+	id := data.Get("id").(string)
+	if err := req.Storage.Delete(ctx, path.Join(revocationFailuresPath, id)); err != nil {
+		return nil, fmt.Errorf("failed to purge revocation failure: %w", err)
+	}
+	return nil, nil
+}
+
+func (b *backend) pathRevocationFailureRetry(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.revocationLock.Lock()
+	defer b.revocationLock.Unlock()
+
+	id := data.Get("id").(string)
+	r, err := retrieveFailedRevocation(ctx, req.Storage, revocationFailuresPath, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read revocation failure: %w", err)
+	}
+	if r == nil {
+		return logical.ErrorResponse("revocation failure %q not found", id), nil
+	}
+
+	if err := b.retryRevocation(ctx, req.Storage, r); err != nil {
+		r.failed(time.Now(), err)
+		if err := storeFailedRevocation(ctx, req.Storage, revocationFailuresPath, r); err != nil {
+			return nil, err
+		}
+		return logical.ErrorResponse("failed to delete revoked credential: %s", err), nil
+	}
+
+	if err := req.Storage.Delete(ctx, path.Join(revocationFailuresPath, id)); err != nil {
+		return nil, fmt.Errorf("failed to remove revocation failure: %w", err)
+	}
+	return nil, nil
+}
@@ -0,0 +1,108 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevocationFailures(t *testing.T) {
+	ctx := context.Background()
+	b, storage := getBackend(false)
+	defer b.Cleanup(ctx)
+	configureOpenLDAPMount(t, b, storage)
+
+	recorder := &executeRecordingClient{}
+	b.client = recorder
+
+	for _, username := range []string{"v-alice", "v-bob"} {
+		r := &failedRevocation{
+			ID:                 username + "-id",
+			RoleName:           "hashicorp",
+			Username:           username,
+			LDIF:               "dn: cn=" + username + ",ou=users,dc=learn,dc=example\nchangetype: delete",
+			DistinguishedNames: []string{"cn=" + username + ",ou=users,dc=learn,dc=example"},
+		}
+		r.failed(time.Now(), errors.New("connection refused"))
+		require.NoError(t, storeFailedRevocation(ctx, storage, revocationFailuresPath, r))
+	}
+
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.ListOperation,
+		Path:      revocationFailuresPath,
+		Storage:   storage,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"v-alice-id", "v-bob-id"}, resp.Data["keys"])
+	info := resp.Data["key_info"].(map[string]interface{})["v-alice-id"].(map[string]interface{})
+	require.Equal(t, "v-alice", info["username"])
+	require.Equal(t, []string{"cn=v-alice,ou=users,dc=learn,dc=example"}, info["distinguished_names"])
+
+	t.Run("retry", func(t *testing.T) {
+		recorder.onExecute = func() error {
+			return errors.New("still down")
+		}
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      revocationFailuresPath + "v-alice-id/retry",
+			Storage:   storage,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      revocationFailuresPath + "v-alice-id",
+			Storage:   storage,
+		})
+		require.NoError(t, err)
+		require.Equal(t, 2, resp.Data["attempts"])
+		require.Equal(t, "failed to execute statements: still down", resp.Data["last_error"])
+
+		recorder.onExecute = nil
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      revocationFailuresPath + "v-alice-id/retry",
+			Storage:   storage,
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+		require.Equal(t, []string{"cn=v-alice,ou=users,dc=learn,dc=example"}, recorder.executed[len(recorder.executed)-1])
+
+		r, err := retrieveFailedRevocation(ctx, storage, revocationFailuresPath, "v-alice-id")
+		require.NoError(t, err)
+		require.Nil(t, r)
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		executed := len(recorder.executed)
+		_, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.DeleteOperation,
+			Path:      revocationFailuresPath + "v-bob-id",
+			Storage:   storage,
+		})
+		require.NoError(t, err)
+		require.Len(t, recorder.executed, executed)
+
+		failures, err := listFailedRevocations(ctx, storage, revocationFailuresPath)
+		require.NoError(t, err)
+		require.Empty(t, failures)
+	})
+
+	t.Run("retry unknown failure", func(t *testing.T) {
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      revocationFailuresPath + "unknown/retry",
+			Storage:   storage,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+}
@@ -10,6 +10,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault-plugin-secrets-openldap/client"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/helper/backoff"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -101,30 +103,34 @@ func (b *backend) rotateRootCredential(ctx context.Context, req *logical.Request
 	if err != nil {
 		return err
 	}
-	oldPassword := config.LDAP.BindPassword
 
 	// Take out the backend lock since we are swapping out the connection
 	b.Lock()
 	defer b.Unlock()
 
-	// Update the password remotely.
-	if err := b.client.UpdateDNPassword(config.LDAP, config.LDAP.BindDN, newPassword); err != nil {
+	// Resolve the credential used to reset the bind password. This is the bind
+	// account itself unless a separate rotation account is configured.
+	rotationConf, err := b.rotationBindConfig(ctx, req.Storage, config)
+	if err != nil {
 		return err
 	}
-	config.LDAP.BindPassword = newPassword
-	config.LDAP.LastBindPassword = oldPassword
-	config.LDAP.LastBindPasswordRotation = time.Now()
 
-	// Update the password locally.
-	if pwdStoringErr := storePassword(ctx, req.Storage, config); pwdStoringErr != nil {
-		// We were unable to store the new password locally. We can't continue in this state because we won't be able
-		// to roll any passwords, including our own to get back into a state of working. So, we need to roll back to
-		// the last password we successfully got into storage.
-		if rollbackErr := b.rollbackPassword(ctx, config, oldPassword); rollbackErr != nil {
-			return fmt.Errorf(`unable to store new password due to %s and unable to return to previous password
-due to %s, configure a new binddn and bindpass to restore ldap function`, pwdStoringErr, rollbackErr)
+	if err := b.rotateConfigCredential(ctx, req.Storage, config, rootCredentialBind, rotationConf, newPassword); err != nil {
+		return err
+	}
+
+	// The rotation account is rotated by the bind account only once the new
+	// bind password is stored, so that one of the two stored credentials can
+	// always reset the other. A rotation static role is instead rotated on its
+	// own schedule, which also binds as the bind account.
+	if config.RotationBindDN != "" {
+		newPassword, err := b.GeneratePassword(ctx, config)
+		if err != nil {
+			return err
+		}
+		if err := b.rotateConfigCredential(ctx, req.Storage, config, rootCredentialRotation, config.LDAP, newPassword); err != nil {
+			return fmt.Errorf("rotated the root credential but failed to rotate the rotation credential: %w", err)
 		}
-		return fmt.Errorf("unable to update password due to storage err: %s", pwdStoringErr)
 	}
 
 	// Respond with a 204.
@@ -172,7 +178,7 @@ func (b *backend) pathRotateRoleCredentialsUpdate(ctx context.Context, req *logi
 			item.Value = resp.WALID
 		}
 	} else {
-		item.Priority = resp.RotationTime.Add(role.StaticAccount.RotationPeriod).Unix()
+		item.Priority = role.StaticAccount.NextRotationTime().Unix()
 		// Clear any stored WAL ID as we must have successfully deleted our WAL to get here.
 		item.Value = ""
 	}
@@ -200,9 +206,199 @@ func (b *backend) pathRotateRoleCredentialsUpdate(ctx context.Context, req *logi
 	return nil, nil
 }
 
+// rotateConfigCredential sets a new password for the bind or rotation account
+// stored in the config, binding with bindConf. The new password is recorded
+// as a pending rotation in the seal wrapped config, and a WAL referencing it
+// is written before the directory is modified. Both are only removed once the
+// new password is verified and stored, so that walRollback can finish or undo
+// a rotation that was interrupted. It must be called with the backend lock
+// held.
+func (b *backend) rotateConfigCredential(ctx context.Context, s logical.Storage, config *config, target rootCredential, bindConf *client.Config, newPassword string) error {
+	dn, oldPassword := config.credential(target)
+	targetDN, err := b.passwordTargetDN(config, bindConf, dn)
+	if err != nil {
+		return err
+	}
+
+	rotationID, err := uuid.GenerateUUID()
+	if err != nil {
+		return err
+	}
+	if config.PendingRotations == nil {
+		config.PendingRotations = make(map[rootCredential]*pendingRotation)
+	}
+	config.PendingRotations[target] = &pendingRotation{
+		ID:          rotationID,
+		NewPassword: newPassword,
+		OldPassword: oldPassword,
+	}
+	if err := storePassword(ctx, s, config); err != nil {
+		return fmt.Errorf("unable to store pending rotation: %w", err)
+	}
+
+	walID, err := framework.PutWAL(ctx, s, rotateRootWALKey, &rotateRootCredentialsWAL{
+		Credential: target,
+		DN:         dn,
+		RotationID: rotationID,
+	})
+	if err != nil {
+		b.clearPendingRotation(ctx, s, target, rotationID)
+		return fmt.Errorf("error writing WAL entry: %w", err)
+	}
+
+	// Update the password remotely.
+	if err := b.client.UpdateDNPassword(bindConf, targetDN, newPassword); err != nil {
+		b.clearPendingRotation(ctx, s, target, rotationID)
+		if walErr := framework.DeleteWAL(ctx, s, walID); walErr != nil {
+			b.Logger().Warn("failed to delete WAL", "error", walErr, "WAL ID", walID)
+		}
+		return err
+	}
+
+	// Verify the new password before committing to it.
+	if verifyErr := b.client.VerifyBind(config.verifyConfig(target), dn, newPassword); verifyErr != nil {
+		if rollbackErr := b.rollbackDNPassword(ctx, bindConf, targetDN, oldPassword); rollbackErr != nil {
+			return fmt.Errorf(`unable to bind with new password due to %s and unable to return to previous password
+due to %s, configure a new %s to restore ldap function`, verifyErr, rollbackErr, target.fields())
+		}
+		b.clearPendingRotation(ctx, s, target, rotationID)
+		if walErr := framework.DeleteWAL(ctx, s, walID); walErr != nil {
+			b.Logger().Warn("failed to delete WAL", "error", walErr, "WAL ID", walID)
+		}
+		return fmt.Errorf("unable to bind with new password: %w", verifyErr)
+	}
+
+	// Update the password locally.
+	config.setCredential(target, newPassword, oldPassword)
+	delete(config.PendingRotations, target)
+	if pwdStoringErr := storePassword(ctx, s, config); pwdStoringErr != nil {
+		// We were unable to store the new password locally. We can't continue in this state because we won't be able
+		// to roll any passwords, including our own to get back into a state of working. So, we need to roll back to
+		// the last password we successfully got into storage. If that fails too, the WAL is left in place so that
+		// the rotation can be finished once storage is available.
+		if rollbackErr := b.rollbackDNPassword(ctx, bindConf, targetDN, oldPassword); rollbackErr != nil {
+			return fmt.Errorf(`unable to store new password due to %s and unable to return to previous password
+due to %s, configure a new %s to restore ldap function`, pwdStoringErr, rollbackErr, target.fields())
+		}
+		b.clearPendingRotation(ctx, s, target, rotationID)
+		if walErr := framework.DeleteWAL(ctx, s, walID); walErr != nil {
+			b.Logger().Warn("failed to delete WAL", "error", walErr, "WAL ID", walID)
+		}
+		return fmt.Errorf("unable to update password due to storage err: %s", pwdStoringErr)
+	}
+
+	if err := framework.DeleteWAL(ctx, s, walID); err != nil {
+		b.Logger().Warn("failed to delete WAL", "error", err, "WAL ID", walID)
+	}
+	return nil
+}
+
+// clearPendingRotation removes the pending rotation with the given ID from
+// the stored config once it no longer needs to be recovered. Failures are
+// logged, since a stale pending rotation is ignored by walRollback.
+func (b *backend) clearPendingRotation(ctx context.Context, s logical.Storage, target rootCredential, rotationID string) {
+	config, err := readConfig(ctx, s)
+	if err == nil && config != nil {
+		pending := config.PendingRotations[target]
+		if pending == nil || pending.ID != rotationID {
+			return
+		}
+		delete(config.PendingRotations, target)
+		err = storePassword(ctx, s, config)
+	}
+	if err != nil {
+		b.Logger().Warn("failed to clear pending rotation", "credential", target, "error", err)
+	}
+}
+
+// rotationBindConfig returns the client config used to reset the bind
+// password. It binds as the rotation_binddn account or the rotation static
+// role's account if either is configured, otherwise as the bind account. It
+// must be called with the backend lock held, since static role passwords are
+// only changed under that lock.
+func (b *backend) rotationBindConfig(ctx context.Context, s logical.Storage, config *config) (*client.Config, error) {
+	var bindDN, bindPassword, lastBindPassword string
+	var lastRotation time.Time
+	bindsByDN := true
+	switch {
+	case config.RotationBindDN != "":
+		bindDN = config.RotationBindDN
+		bindPassword = config.RotationBindPassword
+		lastBindPassword = config.LastRotationBindPassword
+		lastRotation = config.LastRotationBindPasswordRotation
+	case config.RotationStaticRole != "":
+		role, err := b.staticRole(ctx, s, config.RotationStaticRole)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			return nil, fmt.Errorf("rotation_static_role %q does not exist", config.RotationStaticRole)
+		}
+		bindDN = role.StaticAccount.DN
+		if bindDN == "" {
+			bindDN = role.StaticAccount.Username
+			bindsByDN = false
+		}
+		bindPassword = role.StaticAccount.Password
+		lastBindPassword = role.StaticAccount.LastPassword
+		lastRotation = role.StaticAccount.LastVaultRotation
+	default:
+		return config.LDAP, nil
+	}
+
+	bindConf := config.LDAP
+	if bindsByDN {
+		bindConf = dnBindConfig(config.LDAP)
+	}
+	entry := *bindConf.ConfigEntry
+	entry.BindDN = bindDN
+	entry.BindPassword = bindPassword
+	return &client.Config{
+		ConfigEntry:              &entry,
+		LastBindPassword:         lastBindPassword,
+		LastBindPasswordRotation: lastRotation,
+		Schema:                   config.LDAP.Schema,
+		CredentialType:           config.LDAP.CredentialType,
+	}, nil
+}
+
+// dnBindConfig returns a copy of conf that binds as its BindDN rather than as
+// a UPN built from it with the upndomain.
+func dnBindConfig(conf *client.Config) *client.Config {
+	if conf.UPNDomain == "" {
+		return conf
+	}
+	entry := *conf.ConfigEntry
+	entry.UPNDomain = ""
+	dnConf := *conf
+	dnConf.ConfigEntry = &entry
+	return &dnConf
+}
+
+// passwordTargetDN returns the name that bindConf finds the account of the
+// given config credential by. With a upndomain the bind account is a
+// username that is only found by its UPN, so it is resolved to its DN when
+// bindConf binds by DN without the upndomain.
+func (b *backend) passwordTargetDN(config *config, bindConf *client.Config, dn string) (string, error) {
+	if config.LDAP.UPNDomain == "" || bindConf.UPNDomain != "" {
+		return dn, nil
+	}
+	entry, err := b.client.SearchDN(config.LDAP, dn)
+	if err != nil {
+		return "", fmt.Errorf("unable to find %q: %w", dn, err)
+	}
+	return entry.DN, nil
+}
+
 // rollbackPassword uses exponential backoff to retry updating to an old password,
 // because LDAP may still be propagating the previous password change.
 func (b *backend) rollbackPassword(ctx context.Context, config *config, oldPassword string) error {
+	return b.rollbackDNPassword(ctx, config.LDAP, config.LDAP.BindDN, oldPassword)
+}
+
+// rollbackDNPassword is like rollbackPassword, but resets the password of the
+// given DN by binding with the given config.
+func (b *backend) rollbackDNPassword(ctx context.Context, conf *client.Config, dn string, oldPassword string) error {
 	expbackoff := backoff.NewBackoff(rollbackAttempts, minRollbackDuration, maxRollbackDuration)
 	var err error
 	for {
@@ -221,7 +417,7 @@ func (b *backend) rollbackPassword(ctx context.Context, config *config, oldPassw
 			// Outer environment is closing.
 			return fmt.Errorf("unable to rollback password because enclosing environment is shutting down")
 		}
-		err = b.client.UpdateDNPassword(config.LDAP, config.LDAP.BindDN, oldPassword)
+		err = b.client.UpdateDNPassword(conf, dn, oldPassword)
 		if err == nil {
 			return nil
 		}
@@ -9,7 +9,6 @@ import (
 	"testing"
 	"time"
 
-	"github.com/go-ldap/ldif"
 	"github.com/hashicorp/go-secure-stdlib/strutil"
 	"github.com/hashicorp/vault-plugin-secrets-openldap/client"
 	"github.com/hashicorp/vault/sdk/helper/ldaputil"
@@ -86,6 +85,187 @@ func TestManualRotateRoot(t *testing.T) {
 	})
 }
 
+// bindRecordingClient records the bind DN used for each password update, and
+// the upndomain it was bound with.
+type bindRecordingClient struct {
+	fakeLdapClient
+	updates    [][2]string
+	upnDomains []string
+}
+
+func (r *bindRecordingClient) UpdateDNPassword(conf *client.Config, dn string, newPassword string) error {
+	r.updates = append(r.updates, [2]string{conf.BindDN, dn})
+	r.upnDomains = append(r.upnDomains, conf.UPNDomain)
+	return r.fakeLdapClient.UpdateDNPassword(conf, dn, newPassword)
+}
+
+// SearchDN resolves usernames to DNs under ou=users, as an AD does for UPNs.
+func (r *bindRecordingClient) SearchDN(conf *client.Config, dn string) (*client.Entry, error) {
+	if conf.UPNDomain != "" && !isDN(dn) {
+		dn = "cn=" + dn + ",ou=users,dc=example,dc=com"
+	}
+	return r.fakeLdapClient.SearchDN(conf, dn)
+}
+
+func TestRotateRoot_RotationAccount(t *testing.T) {
+	t.Run("rotation binddn", func(t *testing.T) {
+		b, storage := getBackend(false)
+		defer b.Cleanup(context.Background())
+		recorder := &bindRecordingClient{}
+		b.client = recorder
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      configPath,
+			Storage:   storage,
+			Data: map[string]interface{}{
+				"binddn":            "tester",
+				"bindpass":          "pa$$w0rd",
+				"url":               "ldap://138.91.247.105",
+				"rotation_binddn":   "admin",
+				"rotation_bindpass": "adm1n",
+			},
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%s resp:%#v\n", err, resp)
+		}
+
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      rotateRootPath,
+			Storage:   storage,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%s resp:%#v\n", err, resp)
+		}
+
+		// The bind password is reset by the rotation account first, then the
+		// rotation password is reset by the bind account.
+		assert.Equal(t, [][2]string{{"admin", "tester"}, {"tester", "admin"}}, recorder.updates)
+
+		config, err := readConfig(context.Background(), storage)
+		assert.NoError(t, err)
+		assert.NotEqual(t, "pa$$w0rd", config.LDAP.BindPassword)
+		assert.Equal(t, "pa$$w0rd", config.LDAP.LastBindPassword)
+		assert.NotEqual(t, "adm1n", config.RotationBindPassword)
+		assert.Equal(t, "adm1n", config.LastRotationBindPassword)
+		assert.False(t, config.LastRotationBindPasswordRotation.IsZero())
+
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      configPath,
+			Storage:   storage,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "admin", resp.Data["rotation_binddn"])
+		assert.NotContains(t, resp.Data, "rotation_bindpass")
+	})
+
+	t.Run("rotation binddn with upndomain", func(t *testing.T) {
+		b, storage := getBackend(false)
+		defer b.Cleanup(context.Background())
+		recorder := &bindRecordingClient{}
+		b.client = recorder
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      configPath,
+			Storage:   storage,
+			Data: map[string]interface{}{
+				"binddn":            "tester",
+				"bindpass":          "pa$$w0rd",
+				"url":               "ldap://138.91.247.105",
+				"schema":            client.SchemaAD,
+				"upndomain":         "example.com",
+				"rotation_binddn":   "cn=admin,ou=users,dc=example,dc=com",
+				"rotation_bindpass": "adm1n",
+			},
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%s resp:%#v\n", err, resp)
+		}
+
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      rotateRootPath,
+			Storage:   storage,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%s resp:%#v\n", err, resp)
+		}
+
+		// The rotation account binds by its DN rather than as a UPN, so the
+		// bind account is resolved from its username to its DN first.
+		assert.Equal(t, [][2]string{
+			{"cn=admin,ou=users,dc=example,dc=com", "cn=tester,ou=users,dc=example,dc=com"},
+			{"tester", "cn=admin,ou=users,dc=example,dc=com"},
+		}, recorder.updates)
+		assert.Equal(t, []string{"", "example.com"}, recorder.upnDomains)
+	})
+
+	t.Run("rotation static role", func(t *testing.T) {
+		b, storage := getBackend(false)
+		defer b.Cleanup(context.Background())
+		configureOpenLDAPMount(t, b, storage)
+		createRole(t, b, storage, "rotator")
+
+		recorder := &bindRecordingClient{}
+		b.client = recorder
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      configPath,
+			Storage:   storage,
+			Data: map[string]interface{}{
+				"rotation_static_role": "rotator",
+			},
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%s resp:%#v\n", err, resp)
+		}
+
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      rotateRootPath,
+			Storage:   storage,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%s resp:%#v\n", err, resp)
+		}
+		assert.Equal(t, [][2]string{{"uid=hashicorp,ou=users,dc=hashicorp,dc=com", "tester"}}, recorder.updates)
+
+		// The referenced role can't be deleted while in use.
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.DeleteOperation,
+			Path:      staticRolePath + "rotator",
+			Storage:   storage,
+		})
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+	})
+
+	t.Run("invalid configurations", func(t *testing.T) {
+		b, storage := getBackend(false)
+		defer b.Cleanup(context.Background())
+		configureOpenLDAPMount(t, b, storage)
+
+		for name, data := range map[string]map[string]interface{}{
+			"missing rotation_bindpass": {"rotation_binddn": "admin"},
+			"both rotation accounts":    {"rotation_binddn": "admin", "rotation_bindpass": "x", "rotation_static_role": "rotator"},
+			"unknown static role":       {"rotation_static_role": "rotator"},
+		} {
+			resp, err := b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.UpdateOperation,
+				Path:      configPath,
+				Storage:   storage,
+				Data:      data,
+			})
+			assert.NoError(t, err, name)
+			assert.True(t, resp != nil && resp.IsError(), name)
+		}
+	})
+}
+
 func TestManualRotateRole(t *testing.T) {
 	t.Run("happy path rotate role", func(t *testing.T) {
 		b, storage := getBackend(false)
@@ -217,7 +397,43 @@ func (f *failingRollbackClient) UpdateUserPassword(conf *client.Config, user, ne
 	panic("nope")
 }
 
-func (f *failingRollbackClient) Execute(conf *client.Config, entries []*ldif.Entry, continueOnError bool) error {
+func (f *failingRollbackClient) SearchDN(conf *client.Config, dn string) (*client.Entry, error) {
+	panic("nope")
+}
+
+func (f *failingRollbackClient) SearchUser(conf *client.Config, username string) (*client.Entry, error) {
+	panic("nope")
+}
+
+func (f *failingRollbackClient) SearchSubtree(conf *client.Config, baseDN, filter string, attributes ...string) ([]*client.Entry, error) {
+	panic("nope")
+}
+
+func (f *failingRollbackClient) PasswordExpiration(conf *client.Config, dn string) (time.Time, error) {
+	panic("nope")
+}
+
+func (f *failingRollbackClient) VerifyBind(conf *client.Config, dn string, password string) error {
+	panic("nope")
+}
+
+func (f *failingRollbackClient) Subschema(conf *client.Config) (*client.Subschema, error) {
+	panic("nope")
+}
+
+func (f *failingRollbackClient) IsGroupMember(conf *client.Config, groupDN, attribute, value string) (bool, error) {
+	panic("nope")
+}
+
+func (f *failingRollbackClient) AddGroupMember(conf *client.Config, groupDN, attribute, value string) (bool, error) {
+	panic("nope")
+}
+
+func (f *failingRollbackClient) RemoveGroupMember(conf *client.Config, groupDN, attribute, value string) error {
+	panic("nope")
+}
+
+func (f *failingRollbackClient) Execute(conf *client.Config, entries []*client.LDIFEntry, continueOnError bool) ([]client.ExecuteResult, error) {
 	panic("nope")
 }
 
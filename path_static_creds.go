@@ -50,16 +50,39 @@ func (b *backend) pathStaticCredsRead(ctx context.Context, req *logical.Request,
 		return logical.ErrorResponse("unknown role: %s", name), nil
 	}
 
+	respData := map[string]interface{}{
+		"dn":                  role.StaticAccount.DN,
+		"username":            role.StaticAccount.Username,
+		"password":            role.StaticAccount.Password,
+		"last_password":       role.StaticAccount.LastPassword,
+		"ttl":                 role.StaticAccount.PasswordTTL().Seconds(),
+		"rotation_period":     role.StaticAccount.RotationPeriod.Seconds(),
+		"last_vault_rotation": role.StaticAccount.LastVaultRotation,
+	}
+
+	if len(role.StaticAccount.ResponseTemplate) > 0 {
+		entity, err := b.requestEntity(req)
+		if err != nil {
+			return nil, err
+		}
+		rendered, err := renderResponseTemplate(role.StaticAccount.ResponseTemplate, entity, staticTemplateData{
+			Username:     role.StaticAccount.Username,
+			Password:     role.StaticAccount.Password,
+			LastPassword: role.StaticAccount.LastPassword,
+			DN:           role.StaticAccount.DN,
+			RoleName:     name,
+			Entity:       entity,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for field, output := range rendered {
+			respData[field] = output
+		}
+	}
+
 	return &logical.Response{
-		Data: map[string]interface{}{
-			"dn":                  role.StaticAccount.DN,
-			"username":            role.StaticAccount.Username,
-			"password":            role.StaticAccount.Password,
-			"last_password":       role.StaticAccount.LastPassword,
-			"ttl":                 role.StaticAccount.PasswordTTL().Seconds(),
-			"rotation_period":     role.StaticAccount.RotationPeriod.Seconds(),
-			"last_vault_rotation": role.StaticAccount.LastVaultRotation,
-		},
+		Data: respData,
 	}, nil
 }
 
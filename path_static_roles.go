@@ -5,11 +5,15 @@ package openldap
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"path"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault-plugin-secrets-openldap/client"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/helper/locksutil"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -53,6 +57,22 @@ func (b *backend) pathListStaticRoles() []*framework.Path {
 					Type:        framework.TypeLowerCaseString,
 					Description: "Path of roles to list",
 				},
+				"detailed": {
+					Type:        framework.TypeBool,
+					Description: "Return details for each role. Implies a recursive listing of nested role paths.",
+				},
+				"due_within": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Only list roles whose next rotation is within the given duration. Implies a recursive listing of nested role paths.",
+				},
+				"never_rotated": {
+					Type:        framework.TypeBool,
+					Description: "Only list roles whose password has never been rotated by Vault. Implies a recursive listing of nested role paths.",
+				},
+				"username_glob": {
+					Type:        framework.TypeString,
+					Description: "Only list roles whose username matches the given shell-style glob pattern. Implies a recursive listing of nested role paths.",
+				},
 			},
 			HelpSynopsis:    staticRolesListHelpSynopsis,
 			HelpDescription: staticRolesListHelpDescription,
@@ -143,6 +163,18 @@ func staticFields() map[string]*framework.FieldSchema {
 			Type:        framework.TypeBool,
 			Description: "Skip the initial pasword rotation on import (has no effect on updates)",
 		},
+		"rotate_immediately": {
+			Type:        framework.TypeBool,
+			Description: "Rotate the password as part of the update, e.g. after changing the username or dn (has no effect on creates)",
+		},
+		"rotate_before_expiry": {
+			Type:        framework.TypeDurationSecond,
+			Description: "Rotate the password this long before it expires according to the directory's password policy, if that is earlier than the rotation period. Zero disables the check. OpenLDAP entries without a pwdPolicySubentry use the default_password_policy_dn of the config, and never expire if it is unset.",
+		},
+		"response_template": {
+			Type:        framework.TypeMap,
+			Description: "Named templates rendered with .Username, .Password, .LastPassword, .DN, .RoleName, and the requesting .Entity, whose outputs are added to the static-cred response under their names, e.g. upn={{.Username}}@example.com. Names cannot replace the fields of the response.",
+		},
 	}
 	return fields
 }
@@ -173,6 +205,14 @@ func (b *backend) pathStaticRoleDelete(ctx context.Context, req *logical.Request
 		return nil, nil
 	}
 
+	config, err := readConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config != nil && config.RotationStaticRole == name {
+		return logical.ErrorResponse("cannot delete static role %q while it is configured as the rotation_static_role", name), nil
+	}
+
 	// Remove the item from the queue
 	_, err = b.popFromRotationQueueByKey(name)
 	if err != nil {
@@ -233,6 +273,15 @@ func (b *backend) pathStaticRoleRead(ctx context.Context, req *logical.Request,
 	if !role.StaticAccount.LastVaultRotation.IsZero() {
 		data["last_vault_rotation"] = role.StaticAccount.LastVaultRotation
 	}
+	if role.StaticAccount.RotateBeforeExpiry > 0 {
+		data["rotate_before_expiry"] = role.StaticAccount.RotateBeforeExpiry.Seconds()
+		if !role.StaticAccount.DirectoryPasswordExpiresAt.IsZero() {
+			data["directory_password_expires_at"] = role.StaticAccount.DirectoryPasswordExpiresAt
+		}
+	}
+	if len(role.StaticAccount.ResponseTemplate) > 0 {
+		data["response_template"] = role.StaticAccount.ResponseTemplate
+	}
 
 	return &logical.Response{Data: data}, nil
 }
@@ -262,6 +311,9 @@ func (b *backend) pathStaticRoleCreateUpdate(ctx context.Context, req *logical.R
 	b.managedUserLock.Lock()
 	defer b.managedUserLock.Unlock()
 
+	prevUsername := role.StaticAccount.Username
+	prevDN := role.StaticAccount.DN
+
 	usernameRaw, ok := data.GetOk("username")
 	if !ok && isCreate {
 		return logical.ErrorResponse("username is a required field to manage a static account"), nil
@@ -271,26 +323,31 @@ func (b *backend) pathStaticRoleCreateUpdate(ctx context.Context, req *logical.R
 		if username == "" {
 			return logical.ErrorResponse("username must not be empty"), nil
 		}
-		if _, exists := b.managedUsers[username]; exists && isCreate {
+		if _, exists := b.managedUsers[username]; exists && (isCreate || username != prevUsername) {
 			return logical.ErrorResponse("%q is already managed by the secrets engine", username), nil
 		}
-		if !isCreate && username != role.StaticAccount.Username {
-			return logical.ErrorResponse("cannot update static account username"), nil
-		}
 
 		role.StaticAccount.Username = username
 	}
 
-	// DN is optional. Unless it is unset via providing the empty string, it
-	// cannot be modified after creation. If given, it will take precedence
-	// over username for LDAP search during password rotation.
+	// DN is optional. If given, it will take precedence over username for
+	// LDAP search during password rotation.
 	if dnRaw, ok := data.GetOk("dn"); ok {
-		dn := dnRaw.(string)
-		if !isCreate && dn != "" && dn != role.StaticAccount.DN {
-			return logical.ErrorResponse("cannot update static account distinguished name (dn)"), nil
-		}
+		role.StaticAccount.DN = dnRaw.(string)
+	}
 
-		role.StaticAccount.DN = dn
+	// A change to the username or a change to a non-empty DN repoints the role
+	// at a different LDAP entry. Unsetting the DN falls back to the username,
+	// which already identifies the managed entry.
+	identityChanged := !isCreate && (role.StaticAccount.Username != prevUsername ||
+		(role.StaticAccount.DN != "" && role.StaticAccount.DN != prevDN))
+
+	rotateImmediately := false
+	if rotateRaw, ok := data.GetOk("rotate_immediately"); ok {
+		if isCreate {
+			return logical.ErrorResponse("rotate_immediately has no effect on creates"), nil
+		}
+		rotateImmediately = rotateRaw.(bool)
 	}
 
 	rotationPeriodSecondsRaw, ok := data.GetOk("rotation_period")
@@ -308,6 +365,29 @@ func (b *backend) pathStaticRoleCreateUpdate(ctx context.Context, req *logical.R
 		role.StaticAccount.RotationPeriod = time.Duration(rotationPeriodSeconds) * time.Second
 	}
 
+	if rotateBeforeExpiryRaw, ok := data.GetOk("rotate_before_expiry"); ok {
+		rotateBeforeExpiry := time.Duration(rotateBeforeExpiryRaw.(int)) * time.Second
+		if rotateBeforeExpiry < 0 {
+			return logical.ErrorResponse("rotate_before_expiry must not be negative"), nil
+		}
+		role.StaticAccount.RotateBeforeExpiry = rotateBeforeExpiry
+	}
+
+	if responseTemplateRaw, ok := data.GetOk("response_template"); ok {
+		responseTemplate := make(map[string]string)
+		for name, value := range responseTemplateRaw.(map[string]interface{}) {
+			str, ok := value.(string)
+			if !ok {
+				return logical.ErrorResponse("response_template %q must be a string", name), nil
+			}
+			responseTemplate[name] = str
+		}
+		if err := validateResponseTemplate(responseTemplate, staticResponseFields, sampleStaticTemplateData); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		role.StaticAccount.ResponseTemplate = responseTemplate
+	}
+
 	skipRotation := false
 	skipRotationRaw, ok := data.GetOk("skip_import_rotation")
 	if ok {
@@ -351,6 +431,9 @@ func (b *backend) pathStaticRoleCreateUpdate(ctx context.Context, req *logical.R
 			// cases because, when import rotation is skipped, LastVaultRotation
 			// is set to a zero value in storage.
 			role.StaticAccount.SetNextVaultRotation(lastVaultRotation)
+			if err := b.refreshPasswordExpiration(ctx, req.Storage, role.StaticAccount); err != nil {
+				return nil, err
+			}
 
 			// we were told not to rotate, just add the entry
 			entry, err := logical.StorageEntryJSON(staticRolePath+name, role)
@@ -393,6 +476,51 @@ func (b *backend) pathStaticRoleCreateUpdate(ctx context.Context, req *logical.R
 			}
 		}
 	case logical.UpdateOperation:
+		if identityChanged {
+			// Verify the new identity refers to exactly one entry in the
+			// directory before the role is repointed at it.
+			if err := b.verifyStaticAccount(ctx, req.Storage, role.StaticAccount); err != nil {
+				return logical.ErrorResponse("unable to verify new static account identity: %s", err), nil
+			}
+		}
+
+		if rotateImmediately {
+			// The existing item could be tracking a WAL ID for this role, so
+			// it's important to reuse it for the rotation.
+			item, err = b.popFromRotationQueueByKey(name)
+			if err != nil {
+				return nil, err
+			}
+
+			input := &setStaticAccountInput{
+				RoleName: name,
+				Role:     role,
+			}
+			if walID, ok := item.Value.(string); ok {
+				input.WALID = walID
+			}
+
+			// setStaticAccountPassword calls Storage.Put and saves the role to storage
+			resp, err := b.setStaticAccountPassword(ctx, req.Storage, input)
+			if err != nil {
+				// The role was not updated in storage, so re-queue the existing
+				// item and preserve the WAL ID if one was returned.
+				if resp != nil && resp.WALID != "" {
+					item.Value = resp.WALID
+				}
+				if pushErr := b.pushItem(item); pushErr != nil {
+					return nil, pushErr
+				}
+				b.ldapEvent(ctx, "rotate-fail", req.Path, name, false)
+				return nil, fmt.Errorf("unable to rotate credentials for updated static role: %w", err)
+			}
+
+			// Clear any stored WAL ID as we must have successfully deleted our WAL to get here.
+			item.Value = ""
+			b.ldapEvent(ctx, "rotate", req.Path, name, true)
+			break
+		}
+
 		// if lastVaultRotation is zero, the role had `skip_import_rotation` set
 		if lastVaultRotation.IsZero() {
 			lastVaultRotation = time.Now()
@@ -400,6 +528,9 @@ func (b *backend) pathStaticRoleCreateUpdate(ctx context.Context, req *logical.R
 
 		// Ensure that NextVaultRotation is recalculated in case the rotation period changed
 		role.StaticAccount.SetNextVaultRotation(lastVaultRotation)
+		if err := b.refreshPasswordExpiration(ctx, req.Storage, role.StaticAccount); err != nil {
+			return nil, err
+		}
 
 		// store updated Role
 		entry, err := logical.StorageEntryJSON(staticRolePath+name, role)
@@ -419,13 +550,16 @@ func (b *backend) pathStaticRoleCreateUpdate(ctx context.Context, req *logical.R
 			return nil, err
 		}
 	}
-	item.Priority = role.StaticAccount.NextVaultRotation.Unix()
+	item.Priority = role.StaticAccount.NextRotationTime().Unix()
 
 	// Add their rotation to the queue
 	if err := b.pushItem(item); err != nil {
 		return nil, err
 	}
 
+	if prevUsername != role.StaticAccount.Username {
+		delete(b.managedUsers, prevUsername)
+	}
 	b.managedUsers[role.StaticAccount.Username] = struct{}{}
 
 	// Send event notification for static role create/update
@@ -466,11 +600,35 @@ type staticAccount struct {
 	// "time to live". This value is compared to the LastVaultRotation to
 	// determine if a password needs to be rotated
 	RotationPeriod time.Duration `json:"rotation_period"`
+
+	// RotateBeforeExpiry is the margin before the directory-side password
+	// expiration at which the password is rotated, if that is earlier than
+	// NextVaultRotation. A zero value disables rotation based on expiration.
+	RotateBeforeExpiry time.Duration `json:"rotate_before_expiry,omitempty"`
+
+	// DirectoryPasswordExpiresAt is the time at which the password expires
+	// according to the directory's password policy, as of the last time it was
+	// read. A zero value indicates the password does not expire or that the
+	// expiration is unknown.
+	DirectoryPasswordExpiresAt time.Time `json:"directory_password_expires_at"`
+
+	// ResponseTemplate holds named templates whose outputs are added to the
+	// response of credential requests.
+	ResponseTemplate map[string]string `json:"response_template,omitempty"`
 }
 
-// NextRotationTime calculates the next rotation by adding the Rotation Period
-// to the last known vault rotation
+// NextRotationTime returns the next rotation time. This is NextVaultRotation,
+// unless the directory-side password expiration minus RotateBeforeExpiry is
+// earlier. An expiration-based time at or before the last rotation is ignored
+// so that a margin longer than the directory's maximum password age cannot
+// cause continuous rotations.
 func (s *staticAccount) NextRotationTime() time.Time {
+	if s.RotateBeforeExpiry > 0 && !s.DirectoryPasswordExpiresAt.IsZero() {
+		beforeExpiry := s.DirectoryPasswordExpiresAt.Add(-s.RotateBeforeExpiry)
+		if beforeExpiry.Before(s.NextVaultRotation) && beforeExpiry.After(s.LastVaultRotation) {
+			return beforeExpiry
+		}
+	}
 	return s.NextVaultRotation
 }
 
@@ -499,11 +657,152 @@ func (s *staticAccount) PasswordTTL() time.Duration {
 
 func (b *backend) pathStaticRoleList(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	rolePath := data.Get("path").(string)
-	roles, err := req.Storage.List(ctx, staticRolePath+rolePath)
-	if err != nil {
+	detailed := data.Get("detailed").(bool)
+	_, filterDue := data.GetOk("due_within")
+	neverRotated := data.Get("never_rotated").(bool)
+	usernameGlob := data.Get("username_glob").(string)
+
+	if !detailed && !filterDue && !neverRotated && usernameGlob == "" {
+		roles, err := req.Storage.List(ctx, staticRolePath+rolePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list roles: %w", err)
+		}
+		return logical.ListResponse(roles), nil
+	}
+
+	if usernameGlob != "" {
+		if _, err := path.Match(usernameGlob, ""); err != nil {
+			return logical.ErrorResponse("invalid username_glob: %s", err), nil
+		}
+	}
+	dueBy := time.Now().Add(time.Duration(data.Get("due_within").(int)) * time.Second)
+
+	// Detailed and filtered listings walk the nested role paths so that the
+	// filters apply to roles rather than to path segments.
+	roles := map[string]*roleEntry{}
+	roleFunc := func(key string) (bool, error) {
+		roleName := rolePath + key
+		role, err := b.staticRole(ctx, req.Storage, roleName)
+		if err != nil {
+			return false, err
+		}
+		entryExists := role != nil && role.StaticAccount != nil && !strings.HasSuffix(key, "/")
+		if entryExists {
+			roles[roleName] = role
+		}
+		return entryExists, nil
+	}
+	if err := walkStoragePath(ctx, req.Storage, staticRolePath+rolePath, roleFunc); err != nil {
 		return nil, fmt.Errorf("failed to list roles: %w", err)
 	}
-	return logical.ListResponse(roles), nil
+
+	keys := make([]string, 0, len(roles))
+	keyInfo := make(map[string]interface{}, len(roles))
+	for roleName, role := range roles {
+		account := role.StaticAccount
+		if filterDue && account.NextRotationTime().After(dueBy) {
+			continue
+		}
+		if neverRotated && !account.LastVaultRotation.IsZero() {
+			continue
+		}
+		if usernameGlob != "" {
+			if matched, _ := path.Match(usernameGlob, account.Username); !matched {
+				continue
+			}
+		}
+
+		key := strings.TrimPrefix(roleName, rolePath)
+		keys = append(keys, key)
+		if detailed {
+			info := map[string]interface{}{
+				"username":            account.Username,
+				"dn":                  account.DN,
+				"rotation_period":     account.RotationPeriod.Seconds(),
+				"next_vault_rotation": account.NextRotationTime(),
+				"ttl":                 account.PasswordTTL().Seconds(),
+			}
+			if !account.LastVaultRotation.IsZero() {
+				info["last_vault_rotation"] = account.LastVaultRotation
+			}
+			keyInfo[key] = info
+		}
+	}
+	sort.Strings(keys)
+
+	if detailed {
+		return logical.ListResponseWithInfo(keys, keyInfo), nil
+	}
+	return logical.ListResponse(keys), nil
+}
+
+// verifyStaticAccount ensures the static account's DN, or its username if no DN
+// is set, resolves to exactly one entry in the directory.
+func (b *backend) verifyStaticAccount(ctx context.Context, s logical.Storage, account *staticAccount) error {
+	config, err := readConfig(ctx, s)
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		return errors.New("the config is currently unset")
+	}
+
+	if account.DN != "" {
+		_, err = b.client.SearchDN(config.LDAP, account.DN)
+	} else {
+		_, err = b.client.SearchUser(config.LDAP, account.Username)
+	}
+	return err
+}
+
+// refreshPasswordExpiration reads the static account's password expiration
+// time from the directory if RotateBeforeExpiry is set, and clears it
+// otherwise.
+func (b *backend) refreshPasswordExpiration(ctx context.Context, s logical.Storage, account *staticAccount) error {
+	if account.RotateBeforeExpiry <= 0 {
+		account.DirectoryPasswordExpiresAt = time.Time{}
+		return nil
+	}
+
+	config, err := readConfig(ctx, s)
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		return errors.New("the config is currently unset")
+	}
+
+	b.setPasswordExpiration(config.LDAP, account)
+	return nil
+}
+
+// setPasswordExpiration sets the static account's DirectoryPasswordExpiresAt
+// from the directory. Failures are logged and leave the expiration unset so
+// that rotations fall back to the rotation period.
+func (b *backend) setPasswordExpiration(conf *client.Config, account *staticAccount) {
+	account.DirectoryPasswordExpiresAt = time.Time{}
+	if account.RotateBeforeExpiry <= 0 {
+		return
+	}
+
+	var entry *client.Entry
+	var err error
+	if account.DN != "" {
+		entry, err = b.client.SearchDN(conf, account.DN)
+	} else {
+		entry, err = b.client.SearchUser(conf, account.Username)
+	}
+	if err != nil {
+		b.Logger().Warn("unable to find entry to read password expiration", "username", account.Username, "error", err)
+		return
+	}
+
+	expiresAt, err := b.client.PasswordExpiration(conf, entry.DN)
+	if err != nil {
+		b.Logger().Warn("unable to read password expiration", "username", account.Username, "error", err)
+		return
+	}
+	account.DirectoryPasswordExpiresAt = expiresAt
 }
 
 func (b *backend) staticRole(ctx context.Context, s logical.Storage, roleName string) (*roleEntry, error) {
@@ -543,12 +842,33 @@ The "dn" parameter is optional and configures the distinguished name to use
 when managing the existing entry. If the "dn" parameter is set, it will take 
 precedence over the "username" when LDAP searches are performed.
 
+The "username" and "dn" parameters may be changed on update. The new entry is
+verified by searching the directory before the role is updated. Set
+"rotate_immediately" to rotate the password as part of the update so that the
+stored password matches the new entry.
+
 The "rotation_period' parameter is required and configures how often, in seconds, 
 the credentials should be automatically rotated by Vault.  The minimum is 5 seconds (5s).
+
+The "rotate_before_expiry" parameter is optional and configures how long before
+the password expires according to the directory's password policy it should be
+rotated, if that is earlier than the rotation period. For Active Directory, the
+policy is read from the account's fine-grained password settings object or the
+domain's maxPwdAge. For OpenLDAP, the policy is read from the ppolicy referenced
+by the account's pwdPolicySubentry. The overlay's default policy cannot be
+discovered over LDAP, so accounts without a pwdPolicySubentry use the
+"default_password_policy_dn" of the config, and are treated as never expiring
+if it is unset.
 `
 
 const staticRolesListHelpDescription = `
 List all the static roles being managed by Vault.
+
+Set "detailed" to return the username, dn, rotation period, last and next
+rotation times, and password TTL of each role. The "due_within",
+"never_rotated", and "username_glob" parameters filter the listed roles. When
+any of these parameters are given, nested role paths are listed recursively and
+each key is the full role path relative to the listed path.
 `
 
 const staticRolesListHelpSynopsis = `
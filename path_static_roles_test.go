@@ -7,6 +7,7 @@ import (
 	"context"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/stretchr/testify/require"
@@ -58,7 +59,7 @@ func Test_backend_pathStaticRoleLifecycle(t *testing.T) {
 			wantCreateErr: true,
 		},
 		{
-			name: "modified username results in update error",
+			name: "successful static role update with modified username",
 			createData: map[string]interface{}{
 				"username":        "bob",
 				"rotation_period": float64(5),
@@ -66,7 +67,6 @@ func Test_backend_pathStaticRoleLifecycle(t *testing.T) {
 			updateData: map[string]interface{}{
 				"username": "alice",
 			},
-			wantUpdateErr: true,
 		},
 		{
 			name: "including skip_import_rotation is an update error",
@@ -81,7 +81,7 @@ func Test_backend_pathStaticRoleLifecycle(t *testing.T) {
 			wantUpdateErr: true,
 		},
 		{
-			name: "modified dn results in update error",
+			name: "successful static role update with modified dn",
 			createData: map[string]interface{}{
 				"username":        "bob",
 				"dn":              "uid=bob,ou=users,dc=hashicorp,dc=com",
@@ -91,7 +91,15 @@ func Test_backend_pathStaticRoleLifecycle(t *testing.T) {
 				"username": "bob",
 				"dn":       "uid=alice,ou=users,dc=hashicorp,dc=com",
 			},
-			wantUpdateErr: true,
+		},
+		{
+			name: "including rotate_immediately is a create error",
+			createData: map[string]interface{}{
+				"username":           "bob",
+				"rotation_period":    float64(5),
+				"rotate_immediately": true,
+			},
+			wantCreateErr: true,
 		},
 		{
 			name: "successful static role update with only username",
@@ -623,6 +631,228 @@ func TestListRoles(t *testing.T) {
 	})
 }
 
+func TestRoles_IdentityUpdate(t *testing.T) {
+	t.Run("username already managed", func(t *testing.T) {
+		b, storage := getBackend(false)
+		defer b.Cleanup(context.Background())
+		configureOpenLDAPMount(t, b, storage)
+
+		createRole(t, b, storage, "hashicorp")
+		createRole(t, b, storage, "vault")
+
+		resp, err := updateStaticRoleWithData(t, b, storage, "hashicorp", map[string]interface{}{
+			"username": "vault",
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+		require.Contains(t, resp.Error().Error(), "already managed")
+	})
+
+	t.Run("username change updates managed users", func(t *testing.T) {
+		b, storage := getBackend(false)
+		defer b.Cleanup(context.Background())
+		configureOpenLDAPMount(t, b, storage)
+
+		createRole(t, b, storage, "hashicorp")
+
+		resp, err := updateStaticRoleWithData(t, b, storage, "hashicorp", map[string]interface{}{
+			"username": "alice",
+			"dn":       "uid=alice,ou=users,dc=hashicorp,dc=com",
+		})
+		assertNoError(t, resp, err)
+
+		require.Contains(t, b.managedUsers, "alice")
+		require.NotContains(t, b.managedUsers, "hashicorp")
+
+		// the previous username can now be managed by another role
+		resp, err = createStaticRoleWithData(t, b, storage, "other", map[string]interface{}{
+			"username":        "hashicorp",
+			"rotation_period": "86400s",
+		})
+		assertNoError(t, resp, err)
+	})
+
+	t.Run("verification failure leaves role unchanged", func(t *testing.T) {
+		b, storage := getBackend(true)
+		defer b.Cleanup(context.Background())
+		configureOpenLDAPMount(t, b, storage)
+
+		resp, err := createStaticRoleWithData(t, b, storage, "hashicorp", map[string]interface{}{
+			"username":             "hashicorp",
+			"dn":                   "uid=hashicorp,ou=users,dc=hashicorp,dc=com",
+			"rotation_period":      "86400s",
+			"skip_import_rotation": true,
+		})
+		assertNoError(t, resp, err)
+
+		resp, err = updateStaticRoleWithData(t, b, storage, "hashicorp", map[string]interface{}{
+			"username": "alice",
+			"dn":       "uid=alice,ou=users,dc=hashicorp,dc=com",
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+		require.Contains(t, resp.Error().Error(), "unable to verify new static account identity")
+
+		role, err := b.staticRole(context.Background(), storage, "hashicorp")
+		require.NoError(t, err)
+		require.Equal(t, "hashicorp", role.StaticAccount.Username)
+		require.Equal(t, "uid=hashicorp,ou=users,dc=hashicorp,dc=com", role.StaticAccount.DN)
+		require.Contains(t, b.managedUsers, "hashicorp")
+		require.NotContains(t, b.managedUsers, "alice")
+	})
+
+	t.Run("rotate immediately", func(t *testing.T) {
+		b, storage := getBackend(false)
+		defer b.Cleanup(context.Background())
+		configureOpenLDAPMount(t, b, storage)
+
+		createRole(t, b, storage, "hashicorp")
+		before, err := b.staticRole(context.Background(), storage, "hashicorp")
+		require.NoError(t, err)
+
+		resp, err := updateStaticRoleWithData(t, b, storage, "hashicorp", map[string]interface{}{
+			"username":           "alice",
+			"dn":                 "uid=alice,ou=users,dc=hashicorp,dc=com",
+			"rotate_immediately": true,
+		})
+		assertNoError(t, resp, err)
+
+		after, err := b.staticRole(context.Background(), storage, "hashicorp")
+		require.NoError(t, err)
+		require.Equal(t, "alice", after.StaticAccount.Username)
+		require.NotEqual(t, before.StaticAccount.Password, after.StaticAccount.Password)
+		require.Equal(t, before.StaticAccount.Password, after.StaticAccount.LastPassword)
+		require.True(t, after.StaticAccount.LastVaultRotation.After(before.StaticAccount.LastVaultRotation))
+	})
+}
+
+func TestListRoles_DetailedAndFiltered(t *testing.T) {
+	b, storage := getBackend(false)
+	defer b.Cleanup(context.Background())
+	configureOpenLDAPMount(t, b, storage)
+
+	roles := map[string]map[string]interface{}{
+		"org/secure": {
+			"username":        "secure",
+			"rotation_period": "60s",
+		},
+		"org/platform/dev": {
+			"username":        "svc-dev",
+			"rotation_period": "86400s",
+		},
+		"org/platform/support": {
+			"username":             "svc-support",
+			"rotation_period":      "86400s",
+			"skip_import_rotation": true,
+		},
+	}
+	for name, data := range roles {
+		resp, err := createStaticRoleWithData(t, b, storage, name, data)
+		assertNoError(t, resp, err)
+	}
+
+	list := func(t *testing.T, rolePath string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ListOperation,
+			Path:      staticRolePath + rolePath,
+			Storage:   storage,
+			Data:      data,
+		})
+		assertNoError(t, resp, err)
+		return resp
+	}
+
+	t.Run("detailed", func(t *testing.T) {
+		resp := list(t, "org/", map[string]interface{}{"detailed": true})
+		require.Equal(t, []string{"platform/dev", "platform/support", "secure"}, resp.Data["keys"])
+
+		keyInfo := resp.Data["key_info"].(map[string]interface{})
+		require.Len(t, keyInfo, 3)
+		info := keyInfo["platform/dev"].(map[string]interface{})
+		require.Equal(t, "svc-dev", info["username"])
+		require.Equal(t, float64(86400), info["rotation_period"])
+		require.NotNil(t, info["last_vault_rotation"])
+		require.NotNil(t, info["next_vault_rotation"])
+		require.NotNil(t, info["ttl"])
+		require.NotContains(t, keyInfo["platform/support"], "last_vault_rotation")
+	})
+
+	t.Run("due within", func(t *testing.T) {
+		resp := list(t, "", map[string]interface{}{"due_within": "1h"})
+		require.Equal(t, []string{"org/secure"}, resp.Data["keys"])
+		require.NotContains(t, resp.Data, "key_info")
+	})
+
+	t.Run("never rotated", func(t *testing.T) {
+		resp := list(t, "", map[string]interface{}{"never_rotated": true})
+		require.Equal(t, []string{"org/platform/support"}, resp.Data["keys"])
+	})
+
+	t.Run("username glob", func(t *testing.T) {
+		resp := list(t, "org/platform/", map[string]interface{}{"username_glob": "svc-*"})
+		require.Equal(t, []string{"dev", "support"}, resp.Data["keys"])
+	})
+
+	t.Run("invalid username glob", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ListOperation,
+			Path:      staticRolePath,
+			Storage:   storage,
+			Data:      map[string]interface{}{"username_glob": "["},
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+}
+
+func TestRoles_RotateBeforeExpiry(t *testing.T) {
+	b, storage := getBackend(false)
+	defer b.Cleanup(context.Background())
+	configureOpenLDAPMount(t, b, storage)
+
+	expiresAt := time.Now().Add(2 * time.Hour).Truncate(time.Second)
+	b.client.(*fakeLdapClient).passwordExpiration = expiresAt
+
+	resp, err := createStaticRoleWithData(t, b, storage, "hashicorp", map[string]interface{}{
+		"username":             "hashicorp",
+		"dn":                   "uid=hashicorp,ou=users,dc=hashicorp,dc=com",
+		"rotation_period":      "86400s",
+		"rotate_before_expiry": "1h",
+	})
+	assertNoError(t, resp, err)
+
+	resp, err = readStaticRole(t, b, storage, "hashicorp")
+	assertNoError(t, resp, err)
+	require.Equal(t, float64(3600), resp.Data["rotate_before_expiry"])
+	require.True(t, expiresAt.Equal(resp.Data["directory_password_expires_at"].(time.Time)))
+
+	// the queue item is scheduled a margin before expiry rather than after
+	// the rotation period
+	item, err := b.popFromRotationQueueByKey("hashicorp")
+	require.NoError(t, err)
+	require.Equal(t, expiresAt.Add(-time.Hour).Unix(), item.Priority)
+	require.NoError(t, b.pushItem(item))
+
+	// a margin longer than the remaining password age is ignored
+	resp, err = updateStaticRoleWithData(t, b, storage, "hashicorp", map[string]interface{}{
+		"rotate_before_expiry": "48h",
+	})
+	assertNoError(t, resp, err)
+	role, err := b.staticRole(context.Background(), storage, "hashicorp")
+	require.NoError(t, err)
+	require.Equal(t, role.StaticAccount.NextVaultRotation, role.StaticAccount.NextRotationTime())
+
+	// disabling the option clears the expiration
+	resp, err = updateStaticRoleWithData(t, b, storage, "hashicorp", map[string]interface{}{
+		"rotate_before_expiry": 0,
+	})
+	assertNoError(t, resp, err)
+	resp, err = readStaticRole(t, b, storage, "hashicorp")
+	assertNoError(t, resp, err)
+	require.NotContains(t, resp.Data, "directory_password_expires_at")
+}
+
 func TestWALsStillTrackedAfterUpdate(t *testing.T) {
 	ctx := context.Background()
 	b, storage := getBackend(false)
@@ -0,0 +1,111 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/hashicorp/vault/sdk/helper/template"
+)
+
+// responseTemplateNameRegex matches the names of response templates, which
+// are the keys of their output in the response data.
+var responseTemplateNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// dynamicResponseFields are the response data keys of dynamic credentials,
+// which response templates of dynamic roles cannot replace.
+var dynamicResponseFields = []string{
+	"username", "password", "distinguished_names", "key_type", "public_key", "private_key",
+}
+
+// staticResponseFields are the response data keys of static credentials,
+// which response templates of static roles cannot replace.
+var staticResponseFields = []string{
+	"dn", "username", "password", "last_password", "ttl", "rotation_period", "last_vault_rotation",
+}
+
+// staticTemplateData is the data that response templates of static roles are
+// rendered with.
+type staticTemplateData struct {
+	Username     string
+	Password     string
+	LastPassword string
+	DN           string
+	RoleName     string
+
+	// Entity is the identity of the requester.
+	Entity templateEntity
+}
+
+// sampleStaticTemplateData is rendered in place of the credentials when the
+// response templates of a static role are validated.
+var sampleStaticTemplateData = staticTemplateData{
+	Username:     "testuser",
+	Password:     "testpass",
+	LastPassword: "testlastpass",
+	DN:           "cn=testuser,dc=example,dc=com",
+	RoleName:     "testrolename",
+}
+
+// validateResponseTemplate checks that each response template has a name that
+// does not replace one of the reserved response fields, and that it renders
+// with the sample data.
+func validateResponseTemplate(templates map[string]string, reserved []string, data interface{}) error {
+	for name := range templates {
+		if !responseTemplateNameRegex.MatchString(name) {
+			return fmt.Errorf("invalid response_template name %q, must only contain letters, digits and underscores", name)
+		}
+		for _, field := range reserved {
+			if name == field {
+				return fmt.Errorf("response_template %q would replace the %s response field", name, field)
+			}
+		}
+	}
+	if _, err := renderResponseTemplate(templates, templateEntity{}, data); err != nil {
+		return err
+	}
+	return nil
+}
+
+// renderResponseTemplate renders each response template with the given data
+// and the template functions of the requesting entity, and returns the
+// outputs by template name.
+func renderResponseTemplate(templates map[string]string, entity templateEntity, data interface{}) (map[string]string, error) {
+	if len(templates) == 0 {
+		return nil, nil
+	}
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rendered := make(map[string]string, len(templates))
+	for _, name := range names {
+		output, err := renderTemplate(templates[name], entity, data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid response_template %q: %w", name, err)
+		}
+		rendered[name] = output
+	}
+	return rendered, nil
+}
+
+// renderTemplate renders a template with the given data and the template
+// functions of the entity.
+func renderTemplate(rawTemplate string, entity templateEntity, data interface{}) (string, error) {
+	tmpl, err := template.NewTemplate(
+		append(templateFunctions(entity), template.Template(rawTemplate))...,
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template: %w", err)
+	}
+	str, err := tmpl.Generate(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to execute template: %w", err)
+	}
+	return str, nil
+}
@@ -0,0 +1,176 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateResponseTemplate(t *testing.T) {
+	testCases := map[string]struct {
+		templates   map[string]string
+		expectedErr string
+	}{
+		"valid": {
+			templates: map[string]string{
+				"upn":        "{{.Username}}@example.com",
+				"down_level": `EXAMPLE\{{.Username}}`,
+				"bind_url":   "ldap://dc1.example.com/{{.DN | dn_escape}}",
+			},
+		},
+		"invalid name": {
+			templates:   map[string]string{"bind-url": "ldap://dc1.example.com"},
+			expectedErr: `invalid response_template name "bind-url"`,
+		},
+		"reserved name": {
+			templates:   map[string]string{"password": "{{.Password}}"},
+			expectedErr: `response_template "password" would replace the password response field`,
+		},
+		"invalid template": {
+			templates:   map[string]string{"upn": "{{.Username"},
+			expectedErr: `invalid response_template "upn"`,
+		},
+		"unknown field": {
+			templates:   map[string]string{"upn": "{{.UserPrincipalName}}"},
+			expectedErr: `invalid response_template "upn"`,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := validateResponseTemplate(tc.templates, staticResponseFields, sampleStaticTemplateData)
+			if tc.expectedErr != "" {
+				require.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestRenderResponseTemplate(t *testing.T) {
+	rendered, err := renderResponseTemplate(map[string]string{
+		"upn":        "{{.Username}}@example.com",
+		"down_level": `EXAMPLE\{{.Username}}`,
+		"department": `{{entity_meta "department"}}`,
+	}, templateEntity{Metadata: map[string]string{"department": "engineering"}}, sampleStaticTemplateData)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"upn":        "testuser@example.com",
+		"down_level": `EXAMPLE\testuser`,
+		"department": "engineering",
+	}, rendered)
+
+	rendered, err = renderResponseTemplate(nil, templateEntity{}, sampleStaticTemplateData)
+	require.NoError(t, err)
+	require.Nil(t, rendered)
+}
+
+func TestDynamicCredsRead_responseTemplate(t *testing.T) {
+	ctx := context.Background()
+	b, storage := getBackend(false)
+	defer b.Cleanup(ctx)
+	configureOpenLDAPMount(t, b, storage)
+
+	data := getTestDynamicRoleConfig("hashicorp")
+	data["response_template"] = map[string]interface{}{"password": "{{.Password}}"}
+	_, err := createDynamicRoleWithData(t, b, storage, "hashicorp", data)
+	require.ErrorContains(t, err, "would replace the password response field")
+
+	data["response_template"] = map[string]interface{}{
+		"upn":        "{{.Username}}@example.com",
+		"down_level": `EXAMPLE\{{.Username}}`,
+	}
+	resp, err := createDynamicRoleWithData(t, b, storage, "hashicorp", data)
+	require.NoError(t, err)
+	require.False(t, resp != nil && resp.IsError(), "unexpected error response: %v", resp)
+
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      dynamicRolePath + "hashicorp",
+		Storage:   storage,
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"upn":        "{{.Username}}@example.com",
+		"down_level": `EXAMPLE\{{.Username}}`,
+	}, resp.Data["response_template"])
+
+	b.client = &executeRecordingClient{}
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      dynamicCredPath + "hashicorp",
+		Storage:   storage,
+	})
+	require.NoError(t, err)
+	require.False(t, resp.IsError(), "unexpected error response: %v", resp)
+	username := resp.Data["username"].(string)
+	require.Equal(t, username+"@example.com", resp.Data["upn"])
+	require.Equal(t, `EXAMPLE\`+username, resp.Data["down_level"])
+
+	// An update without response_template keeps it, and an empty one clears it.
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      dynamicRolePath + "hashicorp",
+		Storage:   storage,
+		Data:      map[string]interface{}{"default_ttl": "20s"},
+	})
+	require.NoError(t, err)
+	require.False(t, resp != nil && resp.IsError(), "unexpected error response: %v", resp)
+	dRole, err := retrieveDynamicRole(ctx, storage, "hashicorp")
+	require.NoError(t, err)
+	require.Len(t, dRole.ResponseTemplate, 2)
+
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      dynamicRolePath + "hashicorp",
+		Storage:   storage,
+		Data:      map[string]interface{}{"response_template": map[string]interface{}{"upn": "{{.Username}}@example.org"}},
+	})
+	require.NoError(t, err)
+	require.False(t, resp != nil && resp.IsError(), "unexpected error response: %v", resp)
+	dRole, err = retrieveDynamicRole(ctx, storage, "hashicorp")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"upn": "{{.Username}}@example.org"}, dRole.ResponseTemplate)
+}
+
+func TestStaticCredsRead_responseTemplate(t *testing.T) {
+	b, storage := getBackend(false)
+	defer b.Cleanup(context.Background())
+	configureOpenLDAPMount(t, b, storage)
+
+	data := getTestStaticRoleConfig("hashicorp")
+	data["response_template"] = map[string]interface{}{"ttl": "{{.Username}}"}
+	resp, err := createStaticRoleWithData(t, b, storage, "hashicorp", data)
+	require.NoError(t, err)
+	require.True(t, resp.IsError())
+	require.ErrorContains(t, resp.Error(), "would replace the ttl response field")
+
+	data["response_template"] = map[string]interface{}{
+		"upn":        "{{.Username}}@example.com",
+		"down_level": `EXAMPLE\{{.Username}}`,
+		"bind_dn":    "{{.DN}}",
+	}
+	resp, err = createStaticRoleWithData(t, b, storage, "hashicorp", data)
+	assertNoError(t, resp, err)
+
+	resp, err = readStaticRole(t, b, storage, "hashicorp")
+	assertNoError(t, resp, err)
+	require.Len(t, resp.Data["response_template"], 3)
+
+	resp = readStaticCred(t, b, storage, "hashicorp")
+	require.Equal(t, data["username"].(string)+"@example.com", resp.Data["upn"])
+	require.Equal(t, `EXAMPLE\`+data["username"].(string), resp.Data["down_level"])
+	require.Equal(t, resp.Data["dn"], resp.Data["bind_dn"])
+
+	resp, err = updateStaticRoleWithData(t, b, storage, "hashicorp", map[string]interface{}{
+		"response_template": map[string]interface{}{},
+	})
+	assertNoError(t, resp, err)
+	resp = readStaticCred(t, b, storage, "hashicorp")
+	require.NotContains(t, resp.Data, "upn")
+}
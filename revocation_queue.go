@@ -0,0 +1,280 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault-plugin-secrets-openldap/client"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	// revocationQueuePath is the storage prefix of the deletions of revoked
+	// dynamic credentials that failed and are retried by the plugin.
+	revocationQueuePath = "revocation-queue/"
+	// revocationFailuresPath is the storage prefix of the deletions that were
+	// given up on after revocationMaxAttempts. They are only retried on request.
+	revocationFailuresPath = "revocation-failures/"
+
+	// Interval to check the revocation queue for deletions to retry
+	revocationQueueTickInterval = 30 * time.Second
+
+	revocationRetryBaseDelay = 30 * time.Second
+	revocationRetryMaxDelay  = time.Hour
+	revocationMaxAttempts    = 10
+)
+
+// failedRevocation is a deletion of a revoked dynamic credential that failed.
+type failedRevocation struct {
+	ID       string `json:"id"`
+	RoleName string `json:"role_name"`
+	Username string `json:"username"`
+
+	// LDIF is the rendered deletion_ldif of the credential.
+	LDIF               string   `json:"ldif"`
+	DistinguishedNames []string `json:"distinguished_names"`
+
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"last_error"`
+	FirstFailed time.Time `json:"first_failed"`
+	LastFailed  time.Time `json:"last_failed"`
+	NextAttempt time.Time `json:"next_attempt"`
+}
+
+func (r *failedRevocation) toMap() map[string]interface{} {
+	return map[string]interface{}{
+		"role_name":           r.RoleName,
+		"username":            r.Username,
+		"distinguished_names": r.DistinguishedNames,
+		"attempts":            r.Attempts,
+		"last_error":          r.LastError,
+		"first_failed":        r.FirstFailed,
+		"last_failed":         r.LastFailed,
+	}
+}
+
+// failed records a failed attempt and schedules the next one with an
+// exponential backoff.
+func (r *failedRevocation) failed(now time.Time, err error) {
+	r.Attempts++
+	r.LastError = err.Error()
+	r.LastFailed = now
+	if r.FirstFailed.IsZero() {
+		r.FirstFailed = now
+	}
+
+	delay := revocationRetryBaseDelay
+	for i := 1; i < r.Attempts && delay < revocationRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > revocationRetryMaxDelay {
+		delay = revocationRetryMaxDelay
+	}
+	r.NextAttempt = now.Add(delay)
+}
+
+func storeFailedRevocation(ctx context.Context, s logical.Storage, prefix string, r *failedRevocation) error {
+	entry, err := logical.StorageEntryJSON(path.Join(prefix, r.ID), r)
+	if err != nil {
+		return fmt.Errorf("unable to marshal storage entry: %w", err)
+	}
+	if err := s.Put(ctx, entry); err != nil {
+		return fmt.Errorf("failed to store failed revocation: %w", err)
+	}
+	return nil
+}
+
+func retrieveFailedRevocation(ctx context.Context, s logical.Storage, prefix, id string) (*failedRevocation, error) {
+	entry, err := s.Get(ctx, path.Join(prefix, id))
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	r := new(failedRevocation)
+	if err := entry.DecodeJSON(r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// listFailedRevocations returns the failed revocations under the given prefix,
+// oldest first.
+func listFailedRevocations(ctx context.Context, s logical.Storage, prefix string) ([]*failedRevocation, error) {
+	ids, err := s.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var revocations []*failedRevocation
+	for _, id := range ids {
+		r, err := retrieveFailedRevocation(ctx, s, prefix, id)
+		if err != nil {
+			return nil, err
+		}
+		if r != nil {
+			revocations = append(revocations, r)
+		}
+	}
+	sort.Slice(revocations, func(i, j int) bool {
+		return revocations[i].FirstFailed.Before(revocations[j].FirstFailed)
+	})
+	return revocations, nil
+}
+
+// hasFailedRevocation returns whether a deletion of the credential of the
+// role failed, and is queued or was given up on.
+func hasFailedRevocation(ctx context.Context, s logical.Storage, roleName, username string) (bool, error) {
+	for _, prefix := range []string{revocationQueuePath, revocationFailuresPath} {
+		revocations, err := listFailedRevocations(ctx, s, prefix)
+		if err != nil {
+			return false, err
+		}
+		for _, r := range revocations {
+			if r.RoleName == roleName && r.Username == username {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// queueRevocation records a failed deletion of a dynamic credential in the
+// revocation queue with its rendered deletion LDIF.
+func queueRevocation(ctx context.Context, s logical.Storage, roleName, deletionTemplate string, templateData dynamicTemplateData, cause error) error {
+	rawLDIF, err := applyTemplate(deletionTemplate, templateData)
+	if err != nil {
+		return fmt.Errorf("failed to apply template: %w", err)
+	}
+	entries, err := client.ParseLDIF(rawLDIF)
+	if err != nil {
+		return fmt.Errorf("failed to parse generated LDIF: %w", err)
+	}
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return err
+	}
+
+	r := &failedRevocation{
+		ID:                 id,
+		RoleName:           roleName,
+		Username:           templateData.Username,
+		LDIF:               rawLDIF,
+		DistinguishedNames: getDNs(entries),
+	}
+	r.failed(time.Now(), cause)
+	return storeFailedRevocation(ctx, s, revocationQueuePath, r)
+}
+
+// retryRevocation runs the deletion LDIF of a failed revocation again. Entries
+// and values that no longer exist were removed by an earlier attempt, so those
+// errors are ignored.
+func (b *backend) retryRevocation(ctx context.Context, s logical.Storage, r *failedRevocation) error {
+	config, err := readConfig(ctx, s)
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		return fmt.Errorf("missing LDAP configuration")
+	}
+
+	if _, err := b.executeRenderedLDIF(config.LDAP, r.LDIF, true); err != nil && !alreadyDeleted(err) {
+		return err
+	}
+	b.removeIssuedCredential(ctx, s, r.RoleName, r.Username)
+	return nil
+}
+
+// alreadyDeleted returns whether all errors of a deletion are for entries or
+// values that don't exist.
+func alreadyDeleted(err error) bool {
+	var merr *multierror.Error
+	if !errors.As(err, &merr) {
+		return ldap.IsErrorAnyOf(err, ldap.LDAPResultNoSuchObject, ldap.LDAPResultNoSuchAttribute)
+	}
+	for _, err := range merr.Errors {
+		if !ldap.IsErrorAnyOf(err, ldap.LDAPResultNoSuchObject, ldap.LDAPResultNoSuchAttribute) {
+			return false
+		}
+	}
+	return true
+}
+
+// runRevocationQueue periodically retries the deletions in the revocation
+// queue until the context is canceled.
+func (b *backend) runRevocationQueue(ctx context.Context, s logical.Storage) {
+	tick := time.NewTicker(revocationQueueTickInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			b.processRevocationQueue(ctx, s)
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// processRevocationQueue retries the queued deletions that are due. Deletions
+// that fail revocationMaxAttempts times are moved to the revocation failures.
+func (b *backend) processRevocationQueue(ctx context.Context, s logical.Storage) {
+	b.revocationLock.Lock()
+	defer b.revocationLock.Unlock()
+
+	revocations, err := listFailedRevocations(ctx, s, revocationQueuePath)
+	if err != nil {
+		b.Logger().Error("failed to list revocation queue", "error", err)
+		return
+	}
+
+	now := time.Now()
+	for _, r := range revocations {
+		if ctx.Err() != nil {
+			return
+		}
+		if r.NextAttempt.After(now) {
+			continue
+		}
+
+		err := b.retryRevocation(ctx, s, r)
+		if err == nil {
+			b.Logger().Info("deleted revoked dynamic credential", "role", r.RoleName, "username", r.Username, "attempts", r.Attempts+1)
+			if err := s.Delete(ctx, path.Join(revocationQueuePath, r.ID)); err != nil {
+				b.Logger().Error("failed to remove revocation from queue", "id", r.ID, "error", err)
+			}
+			continue
+		}
+
+		r.failed(time.Now(), err)
+		if r.Attempts < revocationMaxAttempts {
+			if err := storeFailedRevocation(ctx, s, revocationQueuePath, r); err != nil {
+				b.Logger().Error("failed to update revocation queue", "id", r.ID, "error", err)
+			}
+			continue
+		}
+
+		b.Logger().Error("giving up on deleting revoked dynamic credential", "role", r.RoleName, "username", r.Username, "attempts", r.Attempts, "error", err)
+		if err := storeFailedRevocation(ctx, s, revocationFailuresPath, r); err != nil {
+			b.Logger().Error("failed to store revocation failure", "id", r.ID, "error", err)
+			continue
+		}
+		if err := s.Delete(ctx, path.Join(revocationQueuePath, r.ID)); err != nil {
+			b.Logger().Error("failed to remove revocation from queue", "id", r.ID, "error", err)
+		}
+		b.ldapEvent(ctx, "revocation-failure", revocationFailuresPath+r.ID, r.RoleName, false, "attempts", strconv.Itoa(r.Attempts))
+	}
+}
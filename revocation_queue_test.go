@@ -0,0 +1,151 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevocationQueue(t *testing.T) {
+	ctx := context.Background()
+	b, storage := getBackend(false)
+	defer b.Cleanup(ctx)
+	configureOpenLDAPMount(t, b, storage)
+
+	resp, err := createDynamicRoleWithData(t, b, storage, "hashicorp", getTestDynamicRoleConfig("hashicorp"))
+	require.NoError(t, err)
+	require.False(t, resp != nil && resp.IsError())
+
+	recorder := &executeRecordingClient{}
+	b.client = recorder
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      dynamicCredPath + "hashicorp",
+		Storage:   storage,
+	})
+	require.NoError(t, err)
+	username := resp.Data["username"].(string)
+
+	// The directory is down when the lease is revoked.
+	recorder.onExecute = func() error {
+		return errors.New("connection refused")
+	}
+	_, err = b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   storage,
+		Secret:    resp.Secret,
+	})
+	require.NoError(t, err)
+
+	queued, err := listFailedRevocations(ctx, storage, revocationQueuePath)
+	require.NoError(t, err)
+	require.Len(t, queued, 1)
+	require.Equal(t, "hashicorp", queued[0].RoleName)
+	require.Equal(t, username, queued[0].Username)
+	require.Equal(t, []string{"cn=" + username + ",ou=users,dc=learn,dc=example"}, queued[0].DistinguishedNames)
+	require.Equal(t, 1, queued[0].Attempts)
+	require.Equal(t, "failed to execute statements: connection refused", queued[0].LastError)
+
+	// Deletions are not retried before they are due.
+	b.processRevocationQueue(ctx, storage)
+	require.Len(t, recorder.executed, 2)
+
+	queued[0].NextAttempt = time.Now().Add(-time.Second)
+	require.NoError(t, storeFailedRevocation(ctx, storage, revocationQueuePath, queued[0]))
+	b.processRevocationQueue(ctx, storage)
+	require.Len(t, recorder.executed, 3)
+
+	queued, err = listFailedRevocations(ctx, storage, revocationQueuePath)
+	require.NoError(t, err)
+	require.Len(t, queued, 1)
+	require.Equal(t, 2, queued[0].Attempts)
+
+	// The entry was removed by another attempt that appeared to fail.
+	recorder.onExecute = func() error {
+		return ldap.NewError(ldap.LDAPResultNoSuchObject, errors.New("no such object"))
+	}
+	queued[0].NextAttempt = time.Now().Add(-time.Second)
+	require.NoError(t, storeFailedRevocation(ctx, storage, revocationQueuePath, queued[0]))
+	b.processRevocationQueue(ctx, storage)
+
+	queued, err = listFailedRevocations(ctx, storage, revocationQueuePath)
+	require.NoError(t, err)
+	require.Empty(t, queued)
+	cred, err := retrieveIssuedCredential(ctx, storage, "hashicorp", username)
+	require.NoError(t, err)
+	require.Nil(t, cred)
+}
+
+func TestRevocationQueue_deadLetter(t *testing.T) {
+	ctx := context.Background()
+	b, storage := getBackend(false)
+	defer b.Cleanup(ctx)
+	configureOpenLDAPMount(t, b, storage)
+
+	b.client = &executeRecordingClient{
+		onExecute: func() error {
+			return errors.New("connection refused")
+		},
+	}
+
+	r := &failedRevocation{
+		ID:       "c2a1c4a0-7d4b-4f4c-a1b5-7e0e2b6b9f10",
+		RoleName: "hashicorp",
+		Username: "v-alice",
+		LDIF:     "dn: cn=v-alice,ou=users,dc=learn,dc=example\nchangetype: delete",
+	}
+	for i := 0; i < revocationMaxAttempts-1; i++ {
+		r.failed(time.Now(), errors.New("connection refused"))
+	}
+	r.NextAttempt = time.Now().Add(-time.Second)
+	require.NoError(t, storeFailedRevocation(ctx, storage, revocationQueuePath, r))
+
+	b.processRevocationQueue(ctx, storage)
+
+	queued, err := listFailedRevocations(ctx, storage, revocationQueuePath)
+	require.NoError(t, err)
+	require.Empty(t, queued)
+
+	failures, err := listFailedRevocations(ctx, storage, revocationFailuresPath)
+	require.NoError(t, err)
+	require.Len(t, failures, 1)
+	require.Equal(t, revocationMaxAttempts, failures[0].Attempts)
+}
+
+func TestFailedRevocation_failed(t *testing.T) {
+	now := time.Now()
+	r := &failedRevocation{}
+
+	r.failed(now, errors.New("first"))
+	require.Equal(t, now, r.FirstFailed)
+	require.Equal(t, now.Add(revocationRetryBaseDelay), r.NextAttempt)
+
+	r.failed(now.Add(time.Minute), errors.New("second"))
+	require.Equal(t, now, r.FirstFailed)
+	require.Equal(t, "second", r.LastError)
+	require.Equal(t, now.Add(time.Minute+2*revocationRetryBaseDelay), r.NextAttempt)
+
+	for i := 0; i < 100; i++ {
+		r.failed(now, errors.New("again"))
+	}
+	require.Equal(t, now.Add(revocationRetryMaxDelay), r.NextAttempt)
+}
+
+func TestAlreadyDeleted(t *testing.T) {
+	noSuchObject := ldap.NewError(ldap.LDAPResultNoSuchObject, errors.New("no such object"))
+	noSuchAttribute := ldap.NewError(ldap.LDAPResultNoSuchAttribute, errors.New("no such attribute"))
+
+	require.True(t, alreadyDeleted(noSuchObject))
+	require.True(t, alreadyDeleted(multierror.Append(noSuchObject, noSuchAttribute)))
+	require.False(t, alreadyDeleted(errors.New("connection refused")))
+	require.False(t, alreadyDeleted(multierror.Append(noSuchObject, errors.New("connection refused"))))
+}
@@ -0,0 +1,348 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault-plugin-secrets-openldap/client"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	// rotateRootWALKey is the WAL kind written before the password of an
+	// account stored in the config is changed in the directory.
+	rotateRootWALKey = "rotateRootWALKey"
+
+	// dynamicCredsWALKey is the WAL kind written before the creation_ldif of
+	// a dynamic role is executed.
+	dynamicCredsWALKey = "dynamicCredsWALKey"
+
+	// membershipWALKey is the WAL kind written before a membership role adds
+	// a user to groups.
+	membershipWALKey = "membershipWALKey"
+
+	// walRollbackMinAge is how old a WAL must be before it is rolled back. It
+	// is far longer than a request, so that the WAL of a request that is
+	// still running is never rolled back.
+	walRollbackMinAge = 10 * time.Minute
+)
+
+// rootCredential identifies an account whose credential is stored in the
+// config and rotated by rotate-root.
+type rootCredential string
+
+const (
+	rootCredentialBind     rootCredential = "bind"
+	rootCredentialRotation rootCredential = "rotation"
+)
+
+// fields returns the config fields holding the credential, for use in
+// error messages.
+func (c rootCredential) fields() string {
+	if c == rootCredentialRotation {
+		return "rotation_binddn and rotation_bindpass"
+	}
+	return "binddn and bindpass"
+}
+
+// rotateRootCredentialsWAL is used to store information in a WAL that can
+// finish or roll back an interrupted rotation of a credential stored in the
+// config. The passwords are kept in the pending rotation of the config it
+// references, since the WAL is not seal wrapped.
+type rotateRootCredentialsWAL struct {
+	Credential rootCredential `json:"credential" mapstructure:"credential"`
+	DN         string         `json:"dn" mapstructure:"dn"`
+	RotationID string         `json:"rotation_id" mapstructure:"rotation_id"`
+}
+
+// pendingRotation holds the passwords of a rotation of a credential stored in
+// the config between changing it in the directory and storing it.
+type pendingRotation struct {
+	ID          string `json:"id"`
+	NewPassword string `json:"new_password"`
+	OldPassword string `json:"old_password"`
+}
+
+// credential returns the DN and current password of the given account.
+func (c *config) credential(target rootCredential) (string, string) {
+	if target == rootCredentialRotation {
+		return c.RotationBindDN, c.RotationBindPassword
+	}
+	return c.LDAP.BindDN, c.LDAP.BindPassword
+}
+
+// verifyConfig returns the client config that binds as the given account are
+// verified with. The bind account binds the same way the plugin binds as it,
+// while the rotation account is a DN that is bound as directly rather than as
+// a UPN built from it.
+func (c *config) verifyConfig(target rootCredential) *client.Config {
+	if target == rootCredentialRotation {
+		return dnBindConfig(c.LDAP)
+	}
+	return c.LDAP
+}
+
+// setCredential records a new password for the given account, keeping the
+// prior password so that binds can fall back to it while the change
+// propagates.
+func (c *config) setCredential(target rootCredential, newPassword, oldPassword string) {
+	if target == rootCredentialRotation {
+		c.RotationBindPassword = newPassword
+		c.LastRotationBindPassword = oldPassword
+		c.LastRotationBindPasswordRotation = time.Now()
+		return
+	}
+	c.LDAP.BindPassword = newPassword
+	c.LDAP.LastBindPassword = oldPassword
+	c.LDAP.LastBindPasswordRotation = time.Now()
+}
+
+// dynamicCredsWAL is used to store information in a WAL that can remove the
+// entries of a dynamic credential whose lease was never created. It holds the
+// deletion and rollback templates of the role rather than the LDIF rendered
+// from them, since they may reference the password and the WAL is not seal
+// wrapped. They are rendered at rollback without the password, which is not
+// needed to remove the entries.
+type dynamicCredsWAL struct {
+	RoleName     string              `json:"role_name" mapstructure:"role_name"`
+	DeletionLDIF string              `json:"deletion_ldif" mapstructure:"deletion_ldif"`
+	RollbackLDIF string              `json:"rollback_ldif" mapstructure:"rollback_ldif"`
+	TemplateData dynamicTemplateData `json:"template_data" mapstructure:"template_data"`
+}
+
+// membershipWAL is used to store information in a WAL that can release the
+// group memberships of a membership role credential whose lease was never
+// created.
+type membershipWAL struct {
+	RoleName string          `json:"role_name" mapstructure:"role_name"`
+	Grant    membershipGrant `json:"grant" mapstructure:"grant"`
+}
+
+// putDynamicCredsWAL writes a WAL with the deletion and rollback templates of
+// the given dynamic credential.
+func (b *backend) putDynamicCredsWAL(ctx context.Context, s logical.Storage, dRole *dynamicRole, templateData dynamicTemplateData) (string, error) {
+	// The password is not needed for cleanup and is kept out of the WAL,
+	// which unlike roles and the config is not seal wrapped.
+	walData := templateData
+	walData.Password = ""
+
+	// The templates are rendered before anything is created, so that a
+	// credential is never created that its WAL could not remove.
+	if dRole.DeletionLDIF != "" {
+		if _, err := applyTemplate(dRole.DeletionLDIF, walData); err != nil {
+			return "", fmt.Errorf("failed to apply deletion template: %w", err)
+		}
+	}
+	if dRole.RollbackLDIF != "" {
+		if _, err := applyTemplate(dRole.RollbackLDIF, walData); err != nil {
+			return "", fmt.Errorf("failed to apply rollback template: %w", err)
+		}
+	}
+
+	walID, err := framework.PutWAL(ctx, s, dynamicCredsWALKey, &dynamicCredsWAL{
+		RoleName:     dRole.Name,
+		DeletionLDIF: dRole.DeletionLDIF,
+		RollbackLDIF: dRole.RollbackLDIF,
+		TemplateData: walData,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error writing WAL entry: %w", err)
+	}
+	return walID, nil
+}
+
+// commitDynamicCredsWAL removes the WAL of a dynamic credential once its
+// response is complete, as the last step of the request. The lease is created
+// from the response only after the request returns, so if Vault stops in
+// between, the created entries are left without a WAL or a lease, and can
+// only be found by the orphan search of the role. The WAL is otherwise never
+// rolled back before walRollbackMinAge, long after the request ended.
+func (b *backend) commitDynamicCredsWAL(ctx context.Context, s logical.Storage, walID string) error {
+	// The WAL can only be removed if the request is still live, since its
+	// response would not result in a lease otherwise.
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("request ended after creating credentials, they will be removed by WAL rollback: %w", err)
+	}
+	if err := framework.DeleteWAL(ctx, s, walID); err != nil {
+		return fmt.Errorf("failed to commit WAL entry, the credentials will be removed by WAL rollback: %w", err)
+	}
+	return nil
+}
+
+func (b *backend) deleteDynamicCredsWAL(ctx context.Context, s logical.Storage, walID string) {
+	if err := framework.DeleteWAL(ctx, s, walID); err != nil {
+		b.Logger().Warn("failed to delete WAL", "error", err, "WAL ID", walID)
+	}
+}
+
+// walRollback handles WAL entries that have not been deleted by the operation
+// that wrote them. WAL entries that are rolled back without an error are
+// deleted.
+func (b *backend) walRollback(ctx context.Context, req *logical.Request, kind string, data interface{}) error {
+	switch kind {
+	case rotateRootWALKey:
+		return b.rollbackRootCredential(ctx, req.Storage, data)
+	case dynamicCredsWALKey:
+		return b.rollbackDynamicCreds(ctx, req.Storage, data)
+	case membershipWALKey:
+		return b.rollbackMembership(ctx, req.Storage, data)
+	case staticWALKey:
+		return b.rollbackStaticRole(ctx, req.Storage, data)
+	default:
+		// There is nothing to roll back for kinds this backend doesn't write.
+		return nil
+	}
+}
+
+// rollbackStaticRole removes static role WALs that the rotation queue no
+// longer needs because their role was deleted, changed or rotated since. The
+// WAL of a rotation that the queue is still retrying holds the password that
+// the retries reuse, so it is kept by returning an error until the rotation
+// succeeds.
+func (b *backend) rollbackStaticRole(ctx context.Context, s logical.Storage, data interface{}) error {
+	var wal setCredentialsWAL
+	d, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.StringToTimeHookFunc(time.RFC3339),
+		Result:     &wal,
+	})
+	if err != nil {
+		return err
+	}
+	if err := d.Decode(data); err != nil {
+		return err
+	}
+
+	role, err := b.staticRole(ctx, s, wal.RoleName)
+	if err != nil {
+		return err
+	}
+	if role == nil || role.StaticAccount == nil ||
+		role.StaticAccount.Username != wal.Username || role.StaticAccount.DN != wal.DN ||
+		!role.StaticAccount.LastVaultRotation.Equal(wal.LastVaultRotation) {
+		return nil
+	}
+	return fmt.Errorf("rotation of static role %q is still being retried", wal.RoleName)
+}
+
+// rollbackRootCredential finishes or rolls back a rotation of a credential
+// stored in the config that was interrupted between changing the password in
+// the directory and storing it. If the new password of the pending rotation
+// binds, it is stored. Otherwise the directory is reset to the stored
+// password.
+func (b *backend) rollbackRootCredential(ctx context.Context, s logical.Storage, data interface{}) error {
+	var wal rotateRootCredentialsWAL
+	if err := mapstructure.Decode(data, &wal); err != nil {
+		return err
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	config, err := readConfig(ctx, s)
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		// The config was deleted, so there is nothing left to recover.
+		return nil
+	}
+
+	pending := config.PendingRotations[wal.Credential]
+	if pending == nil || pending.ID != wal.RotationID {
+		// The rotation was finished or undone before the WAL could be deleted.
+		return nil
+	}
+	delete(config.PendingRotations, wal.Credential)
+
+	dn, storedPassword := config.credential(wal.Credential)
+	if dn != wal.DN || storedPassword != pending.OldPassword {
+		// The account or its password was reconfigured since the WAL was
+		// written.
+		return storePassword(ctx, s, config)
+	}
+
+	if err := b.client.VerifyBind(config.verifyConfig(wal.Credential), dn, pending.NewPassword); err == nil {
+		b.Logger().Info("finishing interrupted root credential rotation", "credential", wal.Credential)
+		config.setCredential(wal.Credential, pending.NewPassword, pending.OldPassword)
+		return storePassword(ctx, s, config)
+	}
+
+	if err := b.client.VerifyBind(config.verifyConfig(wal.Credential), dn, pending.OldPassword); err != nil {
+		b.Logger().Info("rolling back interrupted root credential rotation", "credential", wal.Credential)
+		bindConf := config.LDAP
+		if wal.Credential == rootCredentialBind {
+			bindConf, err = b.rotationBindConfig(ctx, s, config)
+			if err != nil {
+				return err
+			}
+		}
+		targetDN, err := b.passwordTargetDN(config, bindConf, dn)
+		if err != nil {
+			return err
+		}
+		if err := b.client.UpdateDNPassword(bindConf, targetDN, pending.OldPassword); err != nil {
+			return err
+		}
+	}
+
+	// The directory has the stored password again, or never accepted the new
+	// one.
+	return storePassword(ctx, s, config)
+}
+
+// rollbackDynamicCreds removes the entries of a dynamic credential that was
+// created without a lease. The rollback_ldif is preferred since creation may
+// have been partial, falling back to the deletion_ldif.
+func (b *backend) rollbackDynamicCreds(ctx context.Context, s logical.Storage, data interface{}) error {
+	var wal dynamicCredsWAL
+	if err := mapstructure.WeakDecode(data, &wal); err != nil {
+		return err
+	}
+
+	config, err := readConfig(ctx, s)
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		return errors.New("missing LDAP configuration")
+	}
+
+	cleanupLDIF := wal.RollbackLDIF
+	if cleanupLDIF == "" {
+		cleanupLDIF = wal.DeletionLDIF
+	}
+
+	b.Logger().Info("removing dynamic credential without a lease", "role", wal.RoleName, "username", wal.TemplateData.Username)
+	if _, err := b.executeLDIF(config.LDAP, cleanupLDIF, wal.TemplateData, true); err != nil {
+		return err
+	}
+	b.removeIssuedCredential(ctx, s, wal.RoleName, wal.TemplateData.Username)
+	return nil
+}
+
+// rollbackMembership releases the group memberships of a membership role
+// credential that was issued without a lease.
+func (b *backend) rollbackMembership(ctx context.Context, s logical.Storage, data interface{}) error {
+	var wal membershipWAL
+	if err := mapstructure.WeakDecode(data, &wal); err != nil {
+		return err
+	}
+
+	config, err := readConfig(ctx, s)
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		return errors.New("missing LDAP configuration")
+	}
+
+	b.Logger().Info("releasing group memberships without a lease", "role", wal.RoleName, "user_dn", wal.Grant.UserDN)
+	return b.releaseMemberships(ctx, s, config.LDAP, wal.Grant)
+}
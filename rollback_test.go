@@ -0,0 +1,501 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/hashicorp/vault-plugin-secrets-openldap/client"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+// directoryClient tracks the password of each DN so that binds can be
+// verified against password updates.
+type directoryClient struct {
+	fakeLdapClient
+	passwords map[string]string
+
+	// ignoreUpdates makes password updates succeed without taking effect.
+	ignoreUpdates bool
+
+	// onUpdate is called before each password update.
+	onUpdate func(dn, newPassword string)
+
+	// verifiedUPNDomains is the upndomain that binds as each DN were
+	// verified with.
+	verifiedUPNDomains map[string]string
+}
+
+func (d *directoryClient) UpdateDNPassword(_ *client.Config, dn string, newPassword string) error {
+	if d.onUpdate != nil {
+		d.onUpdate(dn, newPassword)
+	}
+	if !d.ignoreUpdates {
+		d.passwords[dn] = newPassword
+	}
+	return nil
+}
+
+func (d *directoryClient) VerifyBind(conf *client.Config, dn string, password string) error {
+	if d.verifiedUPNDomains == nil {
+		d.verifiedUPNDomains = make(map[string]string)
+	}
+	d.verifiedUPNDomains[dn] = conf.UPNDomain
+	if d.passwords[dn] != password {
+		return errors.New("invalid credentials")
+	}
+	return nil
+}
+
+func TestRotateRoot_VerifyBind(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("verified rotation leaves no WAL", func(t *testing.T) {
+		b, storage := getBackend(false)
+		defer b.Cleanup(ctx)
+		configureOpenLDAPMount(t, b, storage)
+		directory := &directoryClient{passwords: map[string]string{"tester": "pa$$w0rd"}}
+		b.client = directory
+
+		require.NoError(t, b.rotateRootCredential(ctx, &logical.Request{Storage: storage}))
+
+		config, err := readConfig(ctx, storage)
+		require.NoError(t, err)
+		require.Equal(t, directory.passwords["tester"], config.LDAP.BindPassword)
+
+		walIDs, err := framework.ListWAL(ctx, storage)
+		require.NoError(t, err)
+		require.Empty(t, walIDs)
+	})
+
+	t.Run("WAL holds no passwords", func(t *testing.T) {
+		b, storage := getBackend(false)
+		defer b.Cleanup(ctx)
+		configureOpenLDAPMount(t, b, storage)
+		directory := &directoryClient{passwords: map[string]string{"tester": "pa$$w0rd"}}
+		directory.onUpdate = func(dn, newPassword string) {
+			walIDs, err := framework.ListWAL(ctx, storage)
+			require.NoError(t, err)
+			require.Len(t, walIDs, 1)
+			wal, err := framework.GetWAL(ctx, storage, walIDs[0])
+			require.NoError(t, err)
+			encoded, err := json.Marshal(wal)
+			require.NoError(t, err)
+			require.NotContains(t, string(encoded), newPassword)
+			require.NotContains(t, string(encoded), "pa$$w0rd")
+
+			config, err := readConfig(ctx, storage)
+			require.NoError(t, err)
+			require.Equal(t, newPassword, config.PendingRotations[rootCredentialBind].NewPassword)
+		}
+		b.client = directory
+
+		require.NoError(t, b.rotateRootCredential(ctx, &logical.Request{Storage: storage}))
+
+		config, err := readConfig(ctx, storage)
+		require.NoError(t, err)
+		require.Empty(t, config.PendingRotations)
+	})
+
+	t.Run("rotation account is verified by DN", func(t *testing.T) {
+		b, storage := getBackend(false)
+		defer b.Cleanup(ctx)
+		configureOpenLDAPMount(t, b, storage)
+		adminDN := "cn=admin,ou=users,dc=example,dc=com"
+		directory := &directoryClient{passwords: map[string]string{"tester": "pa$$w0rd", adminDN: "adm1n"}}
+		b.client = directory
+
+		config, err := readConfig(ctx, storage)
+		require.NoError(t, err)
+		config.LDAP.UPNDomain = "example.com"
+		config.RotationBindDN = adminDN
+		config.RotationBindPassword = "adm1n"
+		require.NoError(t, storePassword(ctx, storage, config))
+
+		require.NoError(t, b.rotateRootCredential(ctx, &logical.Request{Storage: storage}))
+		require.Equal(t, map[string]string{"tester": "example.com", adminDN: ""}, directory.verifiedUPNDomains)
+
+		config, err = readConfig(ctx, storage)
+		require.NoError(t, err)
+		require.Equal(t, directory.passwords[adminDN], config.RotationBindPassword)
+	})
+
+	t.Run("unverified rotation is rolled back", func(t *testing.T) {
+		b, storage := getBackend(false)
+		defer b.Cleanup(ctx)
+		configureOpenLDAPMount(t, b, storage)
+		directory := &directoryClient{passwords: map[string]string{"tester": "pa$$w0rd"}, ignoreUpdates: true}
+		b.client = directory
+
+		require.Error(t, b.rotateRootCredential(ctx, &logical.Request{Storage: storage}))
+
+		config, err := readConfig(ctx, storage)
+		require.NoError(t, err)
+		require.Equal(t, "pa$$w0rd", config.LDAP.BindPassword)
+
+		walIDs, err := framework.ListWAL(ctx, storage)
+		require.NoError(t, err)
+		require.Empty(t, walIDs)
+	})
+}
+
+// putPendingRotation records a pending rotation of the given credential to
+// newPassword in the stored config, and writes its WAL.
+func putPendingRotation(t *testing.T, s logical.Storage, target rootCredential, walDN, rotationID, newPassword string) {
+	t.Helper()
+	ctx := context.Background()
+	config, err := readConfig(ctx, s)
+	require.NoError(t, err)
+	_, oldPassword := config.credential(target)
+	config.PendingRotations = map[rootCredential]*pendingRotation{
+		target: {ID: rotationID, NewPassword: newPassword, OldPassword: oldPassword},
+	}
+	require.NoError(t, storePassword(ctx, s, config))
+
+	_, err = framework.PutWAL(ctx, s, rotateRootWALKey, &rotateRootCredentialsWAL{
+		Credential: target,
+		DN:         walDN,
+		RotationID: rotationID,
+	})
+	require.NoError(t, err)
+}
+
+func TestWALRollback_RootCredential(t *testing.T) {
+	ctx := context.Background()
+
+	testCases := map[string]struct {
+		directoryPassword string
+		walNewPassword    string
+		walDN             string
+		expectedPassword  string
+		expectedDirectory string
+	}{
+		"new password binds is finished": {
+			directoryPassword: "new",
+			walNewPassword:    "new",
+			walDN:             "tester",
+			expectedPassword:  "new",
+			expectedDirectory: "new",
+		},
+		"old password binds is left alone": {
+			directoryPassword: "pa$$w0rd",
+			walNewPassword:    "new",
+			walDN:             "tester",
+			expectedPassword:  "pa$$w0rd",
+			expectedDirectory: "pa$$w0rd",
+		},
+		"neither password binds is rolled back": {
+			directoryPassword: "unknown",
+			walNewPassword:    "new",
+			walDN:             "tester",
+			expectedPassword:  "pa$$w0rd",
+			expectedDirectory: "pa$$w0rd",
+		},
+		"reconfigured account is ignored": {
+			directoryPassword: "new",
+			walNewPassword:    "new",
+			walDN:             "someone-else",
+			expectedPassword:  "pa$$w0rd",
+			expectedDirectory: "new",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			b, storage := getBackend(false)
+			defer b.Cleanup(ctx)
+			configureOpenLDAPMount(t, b, storage)
+			directory := &directoryClient{passwords: map[string]string{"tester": tc.directoryPassword}}
+			b.client = directory
+
+			putPendingRotation(t, storage, rootCredentialBind, tc.walDN, "rotation-id", tc.walNewPassword)
+
+			resp, err := b.HandleRequest(ctx, &logical.Request{
+				Operation: logical.RollbackOperation,
+				Storage:   storage,
+				Data:      map[string]interface{}{"immediate": true},
+			})
+			require.NoError(t, err)
+			require.False(t, resp != nil && resp.IsError(), "unexpected error response: %v", resp)
+
+			config, err := readConfig(ctx, storage)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedPassword, config.LDAP.BindPassword)
+			require.Equal(t, tc.expectedDirectory, directory.passwords["tester"])
+			require.Empty(t, config.PendingRotations)
+
+			walIDs, err := framework.ListWAL(ctx, storage)
+			require.NoError(t, err)
+			require.Empty(t, walIDs)
+		})
+	}
+
+	t.Run("WAL of another rotation is ignored", func(t *testing.T) {
+		b, storage := getBackend(false)
+		defer b.Cleanup(ctx)
+		configureOpenLDAPMount(t, b, storage)
+		directory := &directoryClient{passwords: map[string]string{"tester": "unknown"}}
+		b.client = directory
+
+		config, err := readConfig(ctx, storage)
+		require.NoError(t, err)
+		config.PendingRotations = map[rootCredential]*pendingRotation{
+			rootCredentialBind: {ID: "rotation-id", NewPassword: "new", OldPassword: "pa$$w0rd"},
+		}
+		require.NoError(t, storePassword(ctx, storage, config))
+		_, err = framework.PutWAL(ctx, storage, rotateRootWALKey, &rotateRootCredentialsWAL{
+			Credential: rootCredentialBind,
+			DN:         "tester",
+			RotationID: "another-rotation-id",
+		})
+		require.NoError(t, err)
+
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.RollbackOperation,
+			Storage:   storage,
+			Data:      map[string]interface{}{"immediate": true},
+		})
+		require.NoError(t, err)
+		require.False(t, resp != nil && resp.IsError(), "unexpected error response: %v", resp)
+		require.Equal(t, "unknown", directory.passwords["tester"])
+	})
+
+	t.Run("static role WALs are left in place while retried", func(t *testing.T) {
+		b, storage := getBackend(false)
+		defer b.Cleanup(ctx)
+		configureOpenLDAPMount(t, b, storage)
+		createRole(t, b, storage, "hashicorp")
+		role, err := b.staticRole(ctx, storage, "hashicorp")
+		require.NoError(t, err)
+
+		_, err = framework.PutWAL(ctx, storage, staticWALKey, &setCredentialsWAL{
+			RoleName:          "hashicorp",
+			Username:          role.StaticAccount.Username,
+			DN:                role.StaticAccount.DN,
+			LastVaultRotation: role.StaticAccount.LastVaultRotation,
+		})
+		require.NoError(t, err)
+		_, err = framework.PutWAL(ctx, storage, staticWALKey, &setCredentialsWAL{RoleName: "deleted"})
+		require.NoError(t, err)
+
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.RollbackOperation,
+			Storage:   storage,
+			Data:      map[string]interface{}{"immediate": true},
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+
+		// Only the WAL of the retried rotation is kept.
+		walIDs, err := framework.ListWAL(ctx, storage)
+		require.NoError(t, err)
+		require.Len(t, walIDs, 1)
+		wal, err := b.findStaticWAL(ctx, storage, walIDs[0])
+		require.NoError(t, err)
+		require.Equal(t, "hashicorp", wal.RoleName)
+	})
+
+	t.Run("WALs of unknown kinds are removed", func(t *testing.T) {
+		b, storage := getBackend(false)
+		defer b.Cleanup(ctx)
+
+		_, err := framework.PutWAL(ctx, storage, "unknownKind", map[string]interface{}{})
+		require.NoError(t, err)
+
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.RollbackOperation,
+			Storage:   storage,
+			Data:      map[string]interface{}{"immediate": true},
+		})
+		require.NoError(t, err)
+		require.False(t, resp != nil && resp.IsError(), "unexpected error response: %v", resp)
+
+		walIDs, err := framework.ListWAL(ctx, storage)
+		require.NoError(t, err)
+		require.Empty(t, walIDs)
+	})
+}
+
+// executeRecordingClient records the DNs of executed LDIF entries and runs
+// onExecute after each execution. Records for failDN fail, and records after
+// them are not run unless continueOnError is set.
+type executeRecordingClient struct {
+	fakeLdapClient
+	executed  [][]string
+	entries   [][]*client.LDIFEntry
+	onExecute func() error
+	failDN    string
+}
+
+func (e *executeRecordingClient) Execute(_ *client.Config, entries []*client.LDIFEntry, continueOnError bool) ([]client.ExecuteResult, error) {
+	e.executed = append(e.executed, getDNs(entries))
+	e.entries = append(e.entries, entries)
+
+	var results []client.ExecuteResult
+	var err error
+	for i, entry := range entries {
+		result := client.ExecuteResult{Index: i, DN: entry.DN(), Operation: entry.Operation(), Success: true}
+		if e.failDN != "" && entry.DN() == e.failDN {
+			result.Success = false
+			result.ResultCode = ldap.LDAPResultInsufficientAccessRights
+			result.Message = "access denied"
+			err = ldap.NewError(ldap.LDAPResultInsufficientAccessRights, errors.New("access denied"))
+		}
+		results = append(results, result)
+		if err != nil && !continueOnError {
+			break
+		}
+	}
+	if err != nil {
+		return results, err
+	}
+	if e.onExecute != nil {
+		return results, e.onExecute()
+	}
+	return results, nil
+}
+
+func TestWALRollback_DynamicCreds(t *testing.T) {
+	b, storage := getBackend(false)
+	defer b.Cleanup(context.Background())
+	configureOpenLDAPMount(t, b, storage)
+
+	resp, err := createDynamicRoleWithData(t, b, storage, "hashicorp", getTestDynamicRoleConfig("hashicorp"))
+	require.NoError(t, err)
+	require.False(t, resp != nil && resp.IsError())
+
+	recorder := &executeRecordingClient{}
+	b.client = recorder
+
+	t.Run("lease issued leaves no WAL", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      dynamicCredPath + "hashicorp",
+			Storage:   storage,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, resp.Secret)
+
+		walIDs, err := framework.ListWAL(context.Background(), storage)
+		require.NoError(t, err)
+		require.Empty(t, walIDs)
+	})
+
+	t.Run("cancelled request is cleaned up", func(t *testing.T) {
+		recorder.executed = nil
+		ctx, cancel := context.WithCancel(context.Background())
+		recorder.onExecute = func() error {
+			cancel()
+			return nil
+		}
+		defer func() { recorder.onExecute = nil }()
+
+		_, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      dynamicCredPath + "hashicorp",
+			Storage:   storage,
+		})
+		require.Error(t, err)
+		require.Len(t, recorder.executed, 1)
+		createdDN := recorder.executed[0][0]
+
+		walIDs, err := framework.ListWAL(context.Background(), storage)
+		require.NoError(t, err)
+		require.Len(t, walIDs, 1)
+
+		wal, err := framework.GetWAL(context.Background(), storage, walIDs[0])
+		require.NoError(t, err)
+		require.Equal(t, dynamicCredsWALKey, wal.Kind)
+		require.Empty(t, wal.Data.(map[string]interface{})["template_data"].(map[string]interface{})["Password"])
+
+		// The WAL is not rolled back before walRollbackMinAge.
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RollbackOperation,
+			Storage:   storage,
+		})
+		require.NoError(t, err)
+		require.Len(t, recorder.executed, 1)
+		walIDs, err = framework.ListWAL(context.Background(), storage)
+		require.NoError(t, err)
+		require.Len(t, walIDs, 1)
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RollbackOperation,
+			Storage:   storage,
+			Data:      map[string]interface{}{"immediate": true},
+		})
+		require.NoError(t, err)
+		require.False(t, resp != nil && resp.IsError(), "unexpected error response: %v", resp)
+
+		// The rendered rollback LDIF removes the created user.
+		require.Len(t, recorder.executed, 2)
+		username := strings.TrimPrefix(strings.Split(createdDN, ",")[0], "cn=")
+		require.Equal(t, []string{"cn=" + username + ",ou=users,dc=learn,dc=example"}, recorder.executed[1])
+
+		walIDs, err = framework.ListWAL(context.Background(), storage)
+		require.NoError(t, err)
+		require.Empty(t, walIDs)
+	})
+
+	t.Run("password is kept out of the WAL", func(t *testing.T) {
+		data := getTestDynamicRoleConfig("withpass")
+		data["rollback_ldif"] = `dn: cn={{.Username}},ou=users,dc=hashicorp,dc=com
+changetype: modify
+replace: description
+description: password {{.Password}}
+-`
+		resp, err := createDynamicRoleWithData(t, b, storage, "withpass", data)
+		require.NoError(t, err)
+		require.False(t, resp != nil && resp.IsError(), "unexpected error response: %v", resp)
+
+		recorder.executed = nil
+		recorder.entries = nil
+		ctx, cancel := context.WithCancel(context.Background())
+		recorder.onExecute = func() error {
+			cancel()
+			return nil
+		}
+		defer func() { recorder.onExecute = nil }()
+
+		_, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      dynamicCredPath + "withpass",
+			Storage:   storage,
+		})
+		require.Error(t, err)
+		require.Len(t, recorder.entries, 1)
+		password := recorder.entries[0][0].Entry.GetAttributeValue("userPassword")
+		require.NotEmpty(t, password)
+
+		walIDs, err := framework.ListWAL(context.Background(), storage)
+		require.NoError(t, err)
+		require.Len(t, walIDs, 1)
+		entry, err := storage.Get(context.Background(), framework.WALPrefix+walIDs[0])
+		require.NoError(t, err)
+		require.NotContains(t, string(entry.Value), password)
+
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RollbackOperation,
+			Storage:   storage,
+			Data:      map[string]interface{}{"immediate": true},
+		})
+		require.NoError(t, err)
+		require.False(t, resp != nil && resp.IsError(), "unexpected error response: %v", resp)
+
+		// The rollback_ldif is rendered at rollback, without the password.
+		require.Len(t, recorder.entries, 2)
+		require.Equal(t, []string{"password "}, recorder.entries[1][0].Modify.Changes[0].Modification.Vals)
+
+		walIDs, err = framework.ListWAL(context.Background(), storage)
+		require.NoError(t, err)
+		require.Empty(t, walIDs)
+	})
+}
@@ -249,14 +249,9 @@ func (b *backend) rotateCredential(ctx context.Context, s logical.Storage) bool
 	// Clear any stored WAL ID as we must have successfully deleted our WAL to get here.
 	item.Value = ""
 
-	lvr := resp.RotationTime
-	if lvr.IsZero() {
-		lvr = time.Now()
-	}
-
-	// Update priority and push updated Item to the queue
-	nextRotation := lvr.Add(role.StaticAccount.RotationPeriod)
-	item.Priority = nextRotation.Unix()
+	// Update priority and push updated Item to the queue. The role's next
+	// rotation time was updated by setStaticAccountPassword.
+	item.Priority = role.StaticAccount.NextRotationTime().Unix()
 	if err := b.pushItem(item); err != nil {
 		b.Logger().Warn("unable to push item on to queue", "error", err)
 	}
@@ -432,6 +427,7 @@ func (b *backend) setStaticAccountPassword(ctx context.Context, s logical.Storag
 	lvr := time.Now()
 	input.Role.StaticAccount.LastVaultRotation = lvr
 	input.Role.StaticAccount.SetNextVaultRotation(lvr)
+	b.setPasswordExpiration(config.LDAP, input.Role.StaticAccount)
 	input.Role.StaticAccount.LastPassword = input.Role.StaticAccount.Password
 	input.Role.StaticAccount.Password = newPassword
 	output.RotationTime = lvr
@@ -496,6 +492,12 @@ func (b *backend) initQueue(ctx context.Context, conf *logical.InitializationReq
 
 		// Launch ticker
 		go b.runTicker(ctx, conf.Storage)
+
+		// Retry failed deletions of revoked dynamic credentials
+		go b.runRevocationQueue(ctx, conf.Storage)
+
+		// Recount the active credentials that enforce role quotas
+		go b.runActiveCredentialsReconcile(ctx, conf.Storage)
 	}
 }
 
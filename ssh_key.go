@@ -0,0 +1,138 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// credentialTypePassword credentials are a username and generated
+	// password. This is the default.
+	credentialTypePassword = "password"
+	// credentialTypeSSHKey credentials are a username and a generated SSH key
+	// pair, whose public key the creation LDIF stores, e.g. as the
+	// sshPublicKey attribute of openssh-lpk.
+	credentialTypeSSHKey = "ssh_key"
+
+	sshKeyTypeEd25519 = "ed25519"
+	sshKeyTypeRSA     = "rsa"
+
+	defaultSSHKeyBits = 4096
+	minSSHKeyBits     = 2048
+
+	// sampleSSHPublicKey is rendered in place of a generated public key when
+	// LDIF is validated or previewed.
+	sampleSSHPublicKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIAKcNqz+6FplTIgJjswxxTO6jyXCMOBl+REmncqKyMwf"
+)
+
+// sshKeyPair is a generated SSH key pair. The public key is in the
+// authorized_keys format and the private key in the OpenSSH PEM format.
+type sshKeyPair struct {
+	KeyType    string
+	PublicKey  string
+	PrivateKey string
+}
+
+func (r *dynamicRole) credentialType() string {
+	if r.CredentialType == "" {
+		return credentialTypePassword
+	}
+	return r.CredentialType
+}
+
+func (r *dynamicRole) sshKeyType() string {
+	if r.SSHKeyType == "" {
+		return sshKeyTypeEd25519
+	}
+	return r.SSHKeyType
+}
+
+func (r *dynamicRole) sshKeyBits() int {
+	if r.SSHKeyBits == 0 {
+		return defaultSSHKeyBits
+	}
+	return r.SSHKeyBits
+}
+
+// generatesPassword reports whether credentials of the role have a password.
+// SSH key credentials only have one if the role sets generate_password.
+func (r *dynamicRole) generatesPassword() bool {
+	return r.credentialType() == credentialTypePassword || r.GeneratePassword
+}
+
+// validateCredentialType validates the credential type of an LDIF role and its
+// SSH key options.
+func (r *dynamicRole) validateCredentialType() error {
+	switch r.credentialType() {
+	case credentialTypePassword:
+		if r.SSHKeyType != "" || r.SSHKeyBits != 0 || r.GeneratePassword {
+			return fmt.Errorf("ssh_key_type, ssh_key_bits, and generate_password are only supported by the %s credential_type", credentialTypeSSHKey)
+		}
+		return nil
+	case credentialTypeSSHKey:
+	default:
+		return fmt.Errorf("invalid credential_type %q, must be one of %q or %q", r.CredentialType, credentialTypePassword, credentialTypeSSHKey)
+	}
+
+	switch r.sshKeyType() {
+	case sshKeyTypeEd25519:
+		if r.SSHKeyBits != 0 {
+			return fmt.Errorf("ssh_key_bits is only supported by %s keys", sshKeyTypeRSA)
+		}
+	case sshKeyTypeRSA:
+		if r.sshKeyBits() < minSSHKeyBits {
+			return fmt.Errorf("ssh_key_bits must be at least %d", minSSHKeyBits)
+		}
+	default:
+		return fmt.Errorf("invalid ssh_key_type %q, must be one of %q or %q", r.SSHKeyType, sshKeyTypeEd25519, sshKeyTypeRSA)
+	}
+
+	if r.VerifyCredentials && !r.GeneratePassword {
+		return fmt.Errorf("verify_credentials binds with the password, so it requires generate_password for the %s credential_type", credentialTypeSSHKey)
+	}
+	return nil
+}
+
+// generateSSHKey generates a key pair of the role's key type.
+func (r *dynamicRole) generateSSHKey() (*sshKeyPair, error) {
+	var public crypto.PublicKey
+	var private crypto.PrivateKey
+	switch r.sshKeyType() {
+	case sshKeyTypeRSA:
+		key, err := rsa.GenerateKey(rand.Reader, r.sshKeyBits())
+		if err != nil {
+			return nil, err
+		}
+		public, private = &key.PublicKey, key
+	default:
+		pub, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		public, private = pub, key
+	}
+
+	sshPublic, err := ssh.NewPublicKey(public)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode public key: %w", err)
+	}
+	block, err := ssh.MarshalPrivateKey(private, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode private key: %w", err)
+	}
+	return &sshKeyPair{
+		KeyType:    r.sshKeyType(),
+		PublicKey:  strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPublic))),
+		PrivateKey: string(pem.EncodeToMemory(block)),
+	}, nil
+}
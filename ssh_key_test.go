@@ -0,0 +1,211 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+const ldifSSHKeyCreationTemplate = `dn: cn={{.Username}},ou=users,dc=hashicorp,dc=com
+objectClass: person
+objectClass: ldapPublicKey
+cn: learn
+sn: learn
+sshPublicKey: {{.PublicKey}}`
+
+func TestValidateCredentialType(t *testing.T) {
+	testCases := map[string]struct {
+		role        *dynamicRole
+		expectedErr string
+	}{
+		"password": {
+			role: &dynamicRole{},
+		},
+		"ed25519": {
+			role: &dynamicRole{CredentialType: credentialTypeSSHKey},
+		},
+		"rsa": {
+			role: &dynamicRole{CredentialType: credentialTypeSSHKey, SSHKeyType: sshKeyTypeRSA, SSHKeyBits: 2048},
+		},
+		"verified with password": {
+			role: &dynamicRole{CredentialType: credentialTypeSSHKey, GeneratePassword: true, VerifyCredentials: true},
+		},
+		"ssh options on password role": {
+			role:        &dynamicRole{SSHKeyType: sshKeyTypeRSA},
+			expectedErr: "only supported by the ssh_key credential_type",
+		},
+		"invalid credential type": {
+			role:        &dynamicRole{CredentialType: "certificate"},
+			expectedErr: `invalid credential_type "certificate"`,
+		},
+		"invalid key type": {
+			role:        &dynamicRole{CredentialType: credentialTypeSSHKey, SSHKeyType: "dsa"},
+			expectedErr: `invalid ssh_key_type "dsa"`,
+		},
+		"bits of ed25519 key": {
+			role:        &dynamicRole{CredentialType: credentialTypeSSHKey, SSHKeyBits: 4096},
+			expectedErr: "ssh_key_bits is only supported by rsa keys",
+		},
+		"small rsa key": {
+			role:        &dynamicRole{CredentialType: credentialTypeSSHKey, SSHKeyType: sshKeyTypeRSA, SSHKeyBits: 1024},
+			expectedErr: "ssh_key_bits must be at least 2048",
+		},
+		"verified without password": {
+			role:        &dynamicRole{CredentialType: credentialTypeSSHKey, VerifyCredentials: true},
+			expectedErr: "requires generate_password",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.role.validateCredentialType()
+			if tc.expectedErr != "" {
+				require.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestGenerateSSHKey(t *testing.T) {
+	testCases := map[string]struct {
+		role           *dynamicRole
+		expectedPublic string
+	}{
+		"ed25519": {
+			role:           &dynamicRole{CredentialType: credentialTypeSSHKey},
+			expectedPublic: ssh.KeyAlgoED25519,
+		},
+		"rsa": {
+			role:           &dynamicRole{CredentialType: credentialTypeSSHKey, SSHKeyType: sshKeyTypeRSA, SSHKeyBits: 2048},
+			expectedPublic: ssh.KeyAlgoRSA,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			key, err := tc.role.generateSSHKey()
+			require.NoError(t, err)
+			require.Equal(t, name, key.KeyType)
+
+			public, _, _, rest, err := ssh.ParseAuthorizedKey([]byte(key.PublicKey))
+			require.NoError(t, err)
+			require.Empty(t, rest)
+			require.Equal(t, tc.expectedPublic, public.Type())
+
+			private, err := ssh.ParseRawPrivateKey([]byte(key.PrivateKey))
+			require.NoError(t, err)
+			signer, err := ssh.NewSignerFromKey(private)
+			require.NoError(t, err)
+			require.Equal(t, public.Marshal(), signer.PublicKey().Marshal())
+
+			switch k := private.(type) {
+			case *ed25519.PrivateKey:
+			case *rsa.PrivateKey:
+				require.Equal(t, 2048, k.N.BitLen())
+			default:
+				t.Fatalf("unexpected private key type %T", k)
+			}
+		})
+	}
+}
+
+func TestDynamicCredsRead_sshKey(t *testing.T) {
+	ctx := context.Background()
+
+	setup := func(t *testing.T, roleData map[string]interface{}) (*backend, logical.Storage, *executeRecordingClient) {
+		t.Helper()
+		b, storage := getBackend(false)
+		t.Cleanup(func() { b.Cleanup(ctx) })
+		configureOpenLDAPMount(t, b, storage)
+
+		data := getTestDynamicRoleConfig("hashicorp")
+		data["creation_ldif"] = ldifSSHKeyCreationTemplate
+		data["credential_type"] = credentialTypeSSHKey
+		for k, v := range roleData {
+			data[k] = v
+		}
+		resp, err := createDynamicRoleWithData(t, b, storage, "hashicorp", data)
+		require.NoError(t, err)
+		require.False(t, resp != nil && resp.IsError(), "unexpected error response: %v", resp)
+
+		recorder := &executeRecordingClient{}
+		b.client = recorder
+		return b, storage, recorder
+	}
+
+	t.Run("key pair", func(t *testing.T) {
+		b, storage, recorder := setup(t, map[string]interface{}{"rotation_ldif": ldifRotationTemplate})
+
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      dynamicCredPath + "hashicorp",
+			Storage:   storage,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError(), "unexpected error response: %v", resp)
+		require.NotContains(t, resp.Data, "password")
+		require.Equal(t, sshKeyTypeEd25519, resp.Data["key_type"])
+
+		// The creation LDIF stores the public key of the returned private key.
+		publicKey := resp.Data["public_key"].(string)
+		entry := recorder.entries[0][0].Entry
+		require.Equal(t, publicKey, entry.GetAttributeValue("sshPublicKey"))
+		signer, err := ssh.ParsePrivateKey([]byte(resp.Data["private_key"].(string)))
+		require.NoError(t, err)
+		require.Equal(t, publicKey, strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey()))))
+
+		// The private key is only returned, never stored.
+		keys, err := logical.CollectKeys(ctx, storage)
+		require.NoError(t, err)
+		for _, key := range keys {
+			entry, err := storage.Get(ctx, key)
+			require.NoError(t, err)
+			require.NotContains(t, string(entry.Value), "PRIVATE KEY", "private key stored at %s", key)
+		}
+
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      dynamicCredPath + "hashicorp/rotate",
+			Storage:   storage,
+			Data: map[string]interface{}{
+				"lease_id": "creds/hashicorp/8E2mbxoRJT1BdsFyLmCCpE0u",
+				"username": resp.Data["username"],
+			},
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+		require.ErrorContains(t, resp.Error(), "issues credentials without a password")
+	})
+
+	t.Run("key pair and password", func(t *testing.T) {
+		b, storage, recorder := setup(t, map[string]interface{}{
+			"creation_ldif":     ldifSSHKeyCreationTemplate + "\nuserPassword: {{.Password}}",
+			"ssh_key_type":      sshKeyTypeRSA,
+			"ssh_key_bits":      2048,
+			"generate_password": true,
+		})
+
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      dynamicCredPath + "hashicorp",
+			Storage:   storage,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError(), "unexpected error response: %v", resp)
+		require.Equal(t, sshKeyTypeRSA, resp.Data["key_type"])
+		require.NotEmpty(t, resp.Data["password"])
+
+		entry := recorder.entries[0][0].Entry
+		require.Equal(t, resp.Data["password"], entry.GetAttributeValue("userPassword"))
+		require.Equal(t, resp.Data["public_key"], entry.GetAttributeValue("sshPublicKey"))
+	})
+}
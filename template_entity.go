@@ -0,0 +1,126 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// templateEntity is the Vault identity of the requester of dynamic
+// credentials, available to username and LDIF templates as .Entity.
+//
+// Identity values are set by users and auth methods rather than operators, so
+// control characters, which could otherwise end an LDIF line and inject
+// attributes or changes, are removed. Values are not escaped otherwise, and
+// templates should use dn_escape or filter_escape where they build DNs or
+// filters, and b64enc for attribute values that may contain arbitrary
+// characters.
+type templateEntity struct {
+	ID   string
+	Name string
+
+	// Metadata keys that are not set render as "<no value>" if referenced
+	// as .Entity.Metadata.<key>, so entity_meta, which returns an empty
+	// string, suits keys that are not set for every entity.
+	Metadata map[string]string `json:",omitempty"`
+	Aliases  []templateAlias   `json:",omitempty"`
+
+	// Groups are the names of the groups the entity is a member of, directly
+	// or through a parent group, sorted.
+	Groups []string `json:",omitempty"`
+}
+
+// templateAlias is an alias of the requesting entity on an auth mount.
+type templateAlias struct {
+	Name          string
+	MountAccessor string
+	MountType     string
+	Metadata      map[string]string `json:",omitempty"`
+}
+
+// alias returns the name of the entity's alias on the auth mount with the
+// given accessor or type, or an empty string if it has none.
+func (e *templateEntity) alias(mount string) string {
+	for _, a := range e.Aliases {
+		if a.MountAccessor == mount || a.MountType == mount {
+			return a.Name
+		}
+	}
+	return ""
+}
+
+// requestEntity returns the entity that made the request and its aliases and
+// groups. Requests without an entity, such as those made with root tokens,
+// get an empty entity so that templates referencing it still render.
+func (b *backend) requestEntity(req *logical.Request) (templateEntity, error) {
+	if req.EntityID == "" || b.System() == nil {
+		return templateEntity{ID: req.EntityID}, nil
+	}
+	entity, err := b.System().EntityInfo(req.EntityID)
+	if err != nil {
+		return templateEntity{}, fmt.Errorf("failed to look up entity: %w", err)
+	}
+	if entity == nil {
+		return templateEntity{ID: req.EntityID}, nil
+	}
+	groups, err := b.System().GroupsForEntity(req.EntityID)
+	if err != nil {
+		return templateEntity{}, fmt.Errorf("failed to look up groups of entity: %w", err)
+	}
+	return newTemplateEntity(entity, groups), nil
+}
+
+func newTemplateEntity(entity *logical.Entity, groups []*logical.Group) templateEntity {
+	te := templateEntity{
+		ID:       entity.ID,
+		Name:     stripControl(entity.Name),
+		Metadata: stripControlMap(entity.Metadata),
+	}
+	for _, a := range entity.Aliases {
+		if a == nil {
+			continue
+		}
+		te.Aliases = append(te.Aliases, templateAlias{
+			Name:          stripControl(a.Name),
+			MountAccessor: a.MountAccessor,
+			MountType:     a.MountType,
+			Metadata:      stripControlMap(a.Metadata),
+		})
+	}
+	for _, g := range groups {
+		if g == nil {
+			continue
+		}
+		te.Groups = append(te.Groups, stripControl(g.Name))
+	}
+	sort.Strings(te.Groups)
+	return te
+}
+
+// stripControl removes control characters, including line breaks, from
+// identity values.
+func stripControl(str string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, str)
+}
+
+func stripControlMap(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+	stripped := make(map[string]string, len(m))
+	for k, v := range m {
+		stripped[stripControl(k)] = stripControl(v)
+	}
+	return stripped
+}
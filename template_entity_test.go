@@ -0,0 +1,57 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTemplateEntity(t *testing.T) {
+	entity := &logical.Entity{
+		ID:   "entity-id",
+		Name: "jdoe\r\ndn: cn=admin",
+		Metadata: map[string]string{
+			"department": "Platform\nchangetype: delete",
+			"manager":    "Jane Smith, PhD",
+		},
+		Aliases: []*logical.Alias{
+			{Name: "jdoe\x00", MountAccessor: "auth_userpass_1234", MountType: "userpass", Metadata: map[string]string{"site": "eu\t1"}},
+			nil,
+		},
+	}
+	groups := []*logical.Group{{Name: "ops"}, nil, {Name: "dev\n"}}
+
+	require.Equal(t, templateEntity{
+		ID:   "entity-id",
+		Name: "jdoedn: cn=admin",
+		Metadata: map[string]string{
+			"department": "Platformchangetype: delete",
+			"manager":    "Jane Smith, PhD",
+		},
+		Aliases: []templateAlias{
+			{Name: "jdoe", MountAccessor: "auth_userpass_1234", MountType: "userpass", Metadata: map[string]string{"site": "eu1"}},
+		},
+		Groups: []string{"dev", "ops"},
+	}, newTemplateEntity(entity, groups))
+}
+
+func TestRequestEntity(t *testing.T) {
+	config := testBackendConfig()
+	sv := config.System.(testSystemView)
+	sv.EntityVal = &logical.Entity{ID: "entity-id", Name: "jdoe"}
+	sv.GroupsVal = []*logical.Group{{Name: "dev"}}
+	config.System = sv
+	b, _ := getBackendWithConfig(config, false)
+
+	entity, err := b.requestEntity(&logical.Request{EntityID: "entity-id"})
+	require.NoError(t, err)
+	require.Equal(t, templateEntity{ID: "entity-id", Name: "jdoe", Groups: []string{"dev"}}, entity)
+
+	entity, err = b.requestEntity(&logical.Request{})
+	require.NoError(t, err)
+	require.Equal(t, templateEntity{}, entity)
+}
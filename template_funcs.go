@@ -0,0 +1,94 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/hashicorp/vault-plugin-secrets-openldap/client"
+	"github.com/hashicorp/vault/sdk/helper/template"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// sshaSaltLength is the number of random bytes used to salt {SSHA} hashes.
+const sshaSaltLength = 8
+
+// templateFunctions returns the functions available to LDIF and username
+// templates in addition to the SDK's base functions, which include truncate
+// and uuid. entity is the entity that requested the credentials, whose
+// metadata is returned by entity_meta and alias names by entity_alias.
+func templateFunctions(entity templateEntity) []template.Opt {
+	return []template.Opt{
+		template.Function("utf16le", encodeUTF16LE),
+		template.Function("dn_escape", ldap.EscapeDN),
+		template.Function("filter_escape", ldap.EscapeFilter),
+		template.Function("b64enc", encodeB64),
+		template.Function("filetime", fileTime),
+		template.Function("ssha", hashSSHA),
+		template.Function("lower", strings.ToLower),
+		template.Function("entity_meta", func(key string) string {
+			return entity.Metadata[key]
+		}),
+		template.Function("entity_alias", entity.alias),
+	}
+}
+
+func encodeUTF16LE(str string) (string, error) {
+	enc := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewEncoder()
+	return enc.String(str)
+}
+
+func encodeB64(str string) string {
+	return base64.StdEncoding.EncodeToString([]byte(str))
+}
+
+// fileTime converts a time to an AD FILETIME value. The time can be given as
+// Unix seconds, such as .ExpirationTimeSeconds, or as an RFC 3339 string,
+// such as .ExpirationTime.
+func fileTime(value interface{}) (string, error) {
+	var t time.Time
+	switch v := value.(type) {
+	case int:
+		t = time.Unix(int64(v), 0)
+	case int64:
+		t = time.Unix(v, 0)
+	case float64:
+		t = time.Unix(int64(v), 0)
+	case time.Time:
+		t = v
+	case string:
+		if seconds, err := strconv.ParseInt(v, 10, 64); err == nil {
+			t = time.Unix(seconds, 0)
+			break
+		}
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return "", fmt.Errorf("invalid time %q: %w", v, err)
+		}
+		t = parsed
+	default:
+		return "", fmt.Errorf("unsupported time type %T", value)
+	}
+	return client.FormatFileTime(t), nil
+}
+
+// hashSSHA returns the salted SHA-1 hash of the given password in the
+// {SSHA} format accepted by userPassword.
+func hashSSHA(password string) (string, error) {
+	salt := make([]byte, sshaSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	h := sha1.New()
+	h.Write([]byte(password))
+	h.Write(salt)
+	return "{SSHA}" + base64.StdEncoding.EncodeToString(append(h.Sum(nil), salt...)), nil
+}
@@ -0,0 +1,161 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateFunctions(t *testing.T) {
+	type testCase struct {
+		template string
+		data     dynamicTemplateData
+
+		expected      string
+		expectedRegex string
+		expectErr     bool
+	}
+
+	exp := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := map[string]testCase{
+		"dn_escape": {
+			template: "{{.Username | dn_escape}}",
+			data:     dynamicTemplateData{Username: "Smith, John+1"},
+			expected: `Smith\, John\+1`,
+		},
+		"filter_escape": {
+			template: "{{.Username | filter_escape}}",
+			data:     dynamicTemplateData{Username: "a*(b)"},
+			expected: `a\2a\28b\29`,
+		},
+		"b64enc": {
+			template: "{{.Password | b64enc}}",
+			data:     dynamicTemplateData{Password: "secret"},
+			expected: "c2VjcmV0",
+		},
+		"filetime from seconds": {
+			template: "{{filetime .ExpirationTimeSeconds}}",
+			data:     dynamicTemplateData{ExpirationTimeSeconds: exp.Unix()},
+			expected: "133485408000000000",
+		},
+		"filetime from RFC 3339": {
+			template: "{{filetime .ExpirationTime}}",
+			data:     dynamicTemplateData{ExpirationTime: exp.Format(time.RFC3339)},
+			expected: "133485408000000000",
+		},
+		"filetime invalid": {
+			template:  "{{filetime .Username}}",
+			data:      dynamicTemplateData{Username: "tomorrow"},
+			expectErr: true,
+		},
+		"ssha": {
+			template:      "{{ssha .Password}}",
+			data:          dynamicTemplateData{Password: "secret"},
+			expectedRegex: `^\{SSHA\}[A-Za-z0-9+/]+=*$`,
+		},
+		"uuid": {
+			template:      "{{uuid}}",
+			expectedRegex: `^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`,
+		},
+		"lower": {
+			template: "{{.Username | lower}}",
+			data:     dynamicTemplateData{Username: "JDoe"},
+			expected: "jdoe",
+		},
+		"truncate": {
+			template: "{{.Username | truncate 4}}",
+			data:     dynamicTemplateData{Username: "testusername"},
+			expected: "test",
+		},
+		"entity_meta": {
+			template: `{{entity_meta "team"}}-{{entity_meta "missing"}}`,
+			data:     dynamicTemplateData{Entity: templateEntity{Metadata: map[string]string{"team": "platform"}}},
+			expected: "platform-",
+		},
+		"entity_meta of leases issued before entity": {
+			template: `{{entity_meta "team"}}`,
+			data:     dynamicTemplateData{EntityMetadata: map[string]string{"team": "platform"}},
+			expected: "platform",
+		},
+		"entity_alias": {
+			template: `{{entity_alias "userpass"}}-{{entity_alias "auth_ldap_5678"}}-{{entity_alias "oidc"}}`,
+			data: dynamicTemplateData{Entity: templateEntity{Aliases: []templateAlias{
+				{Name: "jdoe", MountAccessor: "auth_userpass_1234", MountType: "userpass"},
+				{Name: "john.doe", MountAccessor: "auth_ldap_5678", MountType: "ldap"},
+			}}},
+			expected: "jdoe-john.doe-",
+		},
+		"entity without requester": {
+			template: `{{.Entity.Name}}{{entity_meta "team"}}{{entity_alias "oidc"}}{{range .Entity.Groups}}{{.}}{{end}}`,
+			expected: "",
+		},
+		"unknown function": {
+			template:  "{{.Username | shout}}",
+			expectErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			actual, err := applyTemplate(test.template, test.data)
+			if test.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if test.expectedRegex != "" {
+				require.Regexp(t, test.expectedRegex, actual)
+			} else {
+				require.Equal(t, test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestHashSSHA(t *testing.T) {
+	hashed, err := hashSSHA("secret")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(hashed, "{SSHA}"))
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(hashed, "{SSHA}"))
+	require.NoError(t, err)
+	require.Len(t, raw, sha1.Size+sshaSaltLength)
+
+	digest, salt := raw[:sha1.Size], raw[sha1.Size:]
+	expected := sha1.Sum(append([]byte("secret"), salt...))
+	require.Equal(t, expected[:], digest)
+}
+
+func TestGenerateUsername_templateFunctions(t *testing.T) {
+	role := &dynamicRole{
+		Name:             "testrole",
+		UsernameTemplate: `v_{{entity_meta "team" | lower}}_{{.RoleName | truncate 4}}`,
+	}
+	username, err := generateUsername(&logical.Request{}, role, templateEntity{Metadata: map[string]string{"team": "Platform"}})
+	require.NoError(t, err)
+	require.Equal(t, "v_platform_test", username)
+}
+
+func TestAssertValidLDIFTemplate_templateFunctions(t *testing.T) {
+	valid := `dn: cn={{.Username | dn_escape}},ou=users,dc=hashicorp,dc=com
+changetype: add
+objectClass: user
+accountExpires: {{filetime .ExpirationTimeSeconds}}
+userPassword: {{ssha .Password}}
+description: {{entity_meta "team"}}`
+	require.NoError(t, assertValidLDIFTemplate(valid, nil))
+
+	invalid := `dn: cn={{.Username}},ou=users,dc=hashicorp,dc=com
+changetype: add
+accountExpires: {{filetime .Username}}`
+	require.Error(t, assertValidLDIFTemplate(invalid, nil))
+}
@@ -0,0 +1,212 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/hashicorp/go-secure-stdlib/strutil"
+)
+
+// parameterNameRegex matches parameter names that can be used as
+// {{.Params.<name>}} in templates.
+var parameterNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// allowedParameter is a template parameter that callers of a dynamic role can
+// supply when requesting credentials. Values are DN escaped and available to
+// the role's LDIF templates as .Params.<name>.
+type allowedParameter struct {
+	Name string `json:"name" mapstructure:"name"`
+
+	// Values must fully match Pattern and be one of AllowedValues, if set.
+	// At least one of the two is required.
+	Pattern       string   `json:"pattern,omitempty"        mapstructure:"pattern"`
+	AllowedValues []string `json:"allowed_values,omitempty" mapstructure:"allowed_values"`
+
+	// Default is used if the caller does not supply the parameter. Parameters
+	// without a default are required.
+	Default []string `json:"default,omitempty" mapstructure:"default"`
+
+	// Multiple parameters take a comma separated list of values and are a
+	// list in templates.
+	Multiple bool `json:"multiple,omitempty" mapstructure:"multiple"`
+}
+
+func (p *allowedParameter) validate() error {
+	if !parameterNameRegex.MatchString(p.Name) {
+		return fmt.Errorf("invalid name %q, must start with a letter or underscore and contain only letters, digits, and underscores", p.Name)
+	}
+	if p.Pattern == "" && len(p.AllowedValues) == 0 {
+		return fmt.Errorf("parameter %q must have a pattern or allowed_values", p.Name)
+	}
+	if _, err := p.regexp(); err != nil {
+		return fmt.Errorf("invalid pattern for parameter %q: %w", p.Name, err)
+	}
+	for _, value := range p.AllowedValues {
+		if containsControl(value) {
+			return fmt.Errorf("allowed value %q of parameter %q contains control characters", value, p.Name)
+		}
+	}
+	if !p.Multiple && len(p.Default) > 1 {
+		return fmt.Errorf("parameter %q takes a single value but has %d defaults", p.Name, len(p.Default))
+	}
+	for _, value := range p.Default {
+		if err := p.check(value); err != nil {
+			return fmt.Errorf("invalid default: %w", err)
+		}
+	}
+	return nil
+}
+
+// regexp returns the pattern anchored to match whole values.
+func (p *allowedParameter) regexp() (*regexp.Regexp, error) {
+	if p.Pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile(`^(?:` + p.Pattern + `)$`)
+}
+
+// check returns an error if the value is not allowed for the parameter.
+// Control characters are never allowed, since values are rendered into LDIF
+// where a line break starts a new attribute or entry.
+func (p *allowedParameter) check(value string) error {
+	if containsControl(value) {
+		return fmt.Errorf("value %q of parameter %q contains control characters", value, p.Name)
+	}
+	re, err := p.regexp()
+	if err != nil {
+		return err
+	}
+	if re != nil && !re.MatchString(value) {
+		return fmt.Errorf("value %q of parameter %q does not match %q", value, p.Name, p.Pattern)
+	}
+	if len(p.AllowedValues) > 0 && !strutil.StrListContains(p.AllowedValues, value) {
+		return fmt.Errorf("value %q of parameter %q is not one of %q", value, p.Name, p.AllowedValues)
+	}
+	return nil
+}
+
+func containsControl(value string) bool {
+	return strings.IndexFunc(value, unicode.IsControl) >= 0
+}
+
+// templateValue returns the DN escaped values of the parameter as they are
+// passed to templates.
+func (p *allowedParameter) templateValue(values []string) interface{} {
+	escaped := make([]string, 0, len(values))
+	for _, value := range values {
+		escaped = append(escaped, ldap.EscapeDN(value))
+	}
+	if p.Multiple {
+		return escaped
+	}
+	return escaped[0]
+}
+
+func (p *allowedParameter) toMap() map[string]interface{} {
+	m := map[string]interface{}{
+		"name":     p.Name,
+		"pattern":  p.Pattern,
+		"multiple": p.Multiple,
+	}
+	if len(p.AllowedValues) > 0 {
+		m["allowed_values"] = p.AllowedValues
+	}
+	if len(p.Default) > 0 {
+		if p.Multiple {
+			m["default"] = p.Default
+		} else {
+			m["default"] = p.Default[0]
+		}
+	}
+	return m
+}
+
+func validateAllowedParameters(params []allowedParameter) error {
+	seen := make(map[string]bool, len(params))
+	for i := range params {
+		if err := params[i].validate(); err != nil {
+			return err
+		}
+		if seen[params[i].Name] {
+			return fmt.Errorf("duplicate parameter %q", params[i].Name)
+		}
+		seen[params[i].Name] = true
+	}
+	return nil
+}
+
+// resolveParams validates the parameters supplied by a caller against the
+// allowed parameters of a role, and returns the template parameters with
+// defaults for the parameters that were not supplied.
+func resolveParams(allowed []allowedParameter, supplied map[string]string) (map[string]interface{}, error) {
+	known := make(map[string]bool, len(allowed))
+	for _, p := range allowed {
+		known[p.Name] = true
+	}
+	var unknown []string
+	for name := range supplied {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("parameters not allowed by the role: %q", unknown)
+	}
+
+	if len(allowed) == 0 {
+		return nil, nil
+	}
+	params := make(map[string]interface{}, len(allowed))
+	for i := range allowed {
+		p := &allowed[i]
+		raw, ok := supplied[p.Name]
+		var values []string
+		switch {
+		case ok && p.Multiple:
+			values = strutil.ParseStringSlice(raw, ",")
+		case ok:
+			values = []string{raw}
+		case len(p.Default) > 0:
+			values = p.Default
+		default:
+			return nil, fmt.Errorf("missing parameter %q", p.Name)
+		}
+
+		for _, value := range values {
+			if err := p.check(value); err != nil {
+				return nil, err
+			}
+		}
+		params[p.Name] = p.templateValue(values)
+	}
+	return params, nil
+}
+
+// sampleParams returns template parameters used to check that templates
+// render, using the defaults or allowed values of the parameters.
+func sampleParams(allowed []allowedParameter) map[string]interface{} {
+	if len(allowed) == 0 {
+		return nil
+	}
+	params := make(map[string]interface{}, len(allowed))
+	for i := range allowed {
+		p := &allowed[i]
+		sample := "testparam"
+		switch {
+		case len(p.Default) > 0:
+			sample = p.Default[0]
+		case len(p.AllowedValues) > 0:
+			sample = p.AllowedValues[0]
+		}
+		params[p.Name] = p.templateValue([]string{sample})
+	}
+	return params
+}
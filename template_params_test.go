@@ -0,0 +1,137 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateAllowedParameters(t *testing.T) {
+	testCases := map[string]struct {
+		params    []allowedParameter
+		expectErr bool
+	}{
+		"pattern": {
+			params: []allowedParameter{{Name: "team", Pattern: "[a-z]+"}},
+		},
+		"allowed values with default": {
+			params: []allowedParameter{{Name: "groups", AllowedValues: []string{"dev", "ops"}, Default: []string{"dev", "ops"}, Multiple: true}},
+		},
+		"invalid name": {
+			params:    []allowedParameter{{Name: "team-name", Pattern: "[a-z]+"}},
+			expectErr: true,
+		},
+		"unrestricted": {
+			params:    []allowedParameter{{Name: "team"}},
+			expectErr: true,
+		},
+		"invalid pattern": {
+			params:    []allowedParameter{{Name: "team", Pattern: "[a-z"}},
+			expectErr: true,
+		},
+		"invalid default": {
+			params:    []allowedParameter{{Name: "team", Pattern: "[a-z]+", Default: []string{"Platform"}}},
+			expectErr: true,
+		},
+		"several defaults for a single value": {
+			params:    []allowedParameter{{Name: "team", Pattern: "[a-z]+", Default: []string{"dev", "ops"}}},
+			expectErr: true,
+		},
+		"control characters in allowed values": {
+			params:    []allowedParameter{{Name: "team", AllowedValues: []string{"dev\nchangetype: add"}}},
+			expectErr: true,
+		},
+		"duplicate": {
+			params:    []allowedParameter{{Name: "team", Pattern: "[a-z]+"}, {Name: "team", Pattern: "[a-z]+"}},
+			expectErr: true,
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := validateAllowedParameters(test.params)
+			if test.expectErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestResolveParams(t *testing.T) {
+	allowed := []allowedParameter{
+		{Name: "team", Pattern: `[a-z ]+`, Default: []string{"platform"}},
+		{Name: "groups", AllowedValues: []string{"dev", "ops", "r&d, eu"}, Multiple: true},
+	}
+
+	testCases := map[string]struct {
+		supplied  map[string]string
+		expected  map[string]interface{}
+		expectErr bool
+	}{
+		"defaults": {
+			supplied: map[string]string{"groups": "dev"},
+			expected: map[string]interface{}{"team": "platform", "groups": []string{"dev"}},
+		},
+		"supplied values are escaped": {
+			supplied: map[string]string{"team": "site reliability", "groups": "dev,ops"},
+			expected: map[string]interface{}{"team": "site reliability", "groups": []string{"dev", "ops"}},
+		},
+		"missing required parameter": {
+			supplied:  map[string]string{},
+			expectErr: true,
+		},
+		"value not matching pattern": {
+			supplied:  map[string]string{"team": "platform,ou=admins", "groups": "dev"},
+			expectErr: true,
+		},
+		"value not allowed": {
+			supplied:  map[string]string{"groups": "dev,admins"},
+			expectErr: true,
+		},
+		"unknown parameter": {
+			supplied:  map[string]string{"groups": "dev", "ou": "admins"},
+			expectErr: true,
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			params, err := resolveParams(allowed, test.supplied)
+			if test.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, params)
+		})
+	}
+
+	t.Run("DN escaping", func(t *testing.T) {
+		params, err := resolveParams([]allowedParameter{{Name: "team", AllowedValues: []string{"r&d, eu"}}}, map[string]string{"team": "r&d, eu"})
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{"team": `r&d\, eu`}, params)
+	})
+
+	t.Run("control characters", func(t *testing.T) {
+		// The pattern itself would match line breaks.
+		lenient := []allowedParameter{{Name: "team", Pattern: `[^,=]+`, Multiple: true}}
+		for _, value := range []string{"dev\nchangetype: add", "dev\rops", "ops,dev\x00"} {
+			_, err := resolveParams(lenient, map[string]string{"team": value})
+			require.ErrorContains(t, err, "contains control characters")
+		}
+	})
+
+	t.Run("no allowed parameters", func(t *testing.T) {
+		params, err := resolveParams(nil, nil)
+		require.NoError(t, err)
+		require.Nil(t, params)
+
+		_, err = resolveParams(nil, map[string]string{"team": "platform"})
+		require.Error(t, err)
+	})
+}
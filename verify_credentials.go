@@ -0,0 +1,120 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault-plugin-secrets-openldap/client"
+	"github.com/hashicorp/vault/sdk/helper/backoff"
+)
+
+const (
+	// verifyBindAsDN binds as the first entry added by the creation LDIF.
+	verifyBindAsDN = "dn"
+	// verifyBindAsUPN binds as the username at the configured upndomain.
+	verifyBindAsUPN = "upn"
+
+	defaultVerifyTimeout = 30 * time.Second
+)
+
+// Bounds of the backoff between binds that verify a new credential.
+var (
+	minVerifyBackoff = 250 * time.Millisecond
+	maxVerifyBackoff = 5 * time.Second
+)
+
+func (r *dynamicRole) verifyBindAs() string {
+	if r.VerifyBindAs == "" {
+		return verifyBindAsDN
+	}
+	return r.VerifyBindAs
+}
+
+func (r *dynamicRole) verifyTimeout() time.Duration {
+	if r.VerifyTimeout == 0 {
+		return defaultVerifyTimeout
+	}
+	return r.VerifyTimeout
+}
+
+// verifyDynamicCredential binds as a newly created credential until the
+// directory accepts it, so that it is usable once it is returned. Directories
+// that replicate between servers may not accept a new account on every server
+// right away, so with verify_all_urls each configured URL is verified. Binds
+// are retried with backoff until the role's verify_timeout passes.
+func (b *backend) verifyDynamicCredential(ctx context.Context, conf *client.Config, dRole *dynamicRole, templateData dynamicTemplateData, report []client.ExecuteResult) error {
+	entry := *conf.ConfigEntry
+	var identity string
+	switch dRole.verifyBindAs() {
+	case verifyBindAsUPN:
+		if entry.UPNDomain == "" {
+			return errors.New("verify_bind_as is upn, but no upndomain is configured")
+		}
+		identity = templateData.Username
+	default:
+		for _, result := range report {
+			if result.Success && result.Operation == "add" {
+				identity = result.DN
+				break
+			}
+		}
+		if identity == "" {
+			return errors.New("creation_ldif did not add an entry to bind as")
+		}
+		// Bind as the DN rather than as a UPN built from it.
+		entry.UPNDomain = ""
+	}
+
+	urls := []string{entry.Url}
+	if dRole.VerifyAllURLs {
+		urls = strings.Split(entry.Url, ",")
+	}
+
+	deadline := time.Now().Add(dRole.verifyTimeout())
+	for _, url := range urls {
+		urlEntry := entry
+		urlEntry.Url = strings.TrimSpace(url)
+		urlConf := &client.Config{
+			ConfigEntry:    &urlEntry,
+			Schema:         conf.Schema,
+			CredentialType: conf.CredentialType,
+		}
+		if err := b.verifyBind(ctx, urlConf, identity, templateData.Password, deadline); err != nil {
+			return fmt.Errorf("failed to bind as %q against %s: %w", identity, urlEntry.Url, err)
+		}
+	}
+	return nil
+}
+
+// verifyBind binds with the given credentials until it succeeds, retrying
+// with backoff until the deadline.
+func (b *backend) verifyBind(ctx context.Context, conf *client.Config, identity, password string, deadline time.Time) error {
+	expbackoff := backoff.NewBackoff(math.MaxInt32, minVerifyBackoff, maxVerifyBackoff)
+	for {
+		err := b.client.VerifyBind(conf, identity, password)
+		if err == nil {
+			return nil
+		}
+
+		nextsleep, _ := expbackoff.Next()
+		if time.Now().Add(nextsleep).After(deadline) {
+			return fmt.Errorf("credential not accepted before verify_timeout: %w", err)
+		}
+		b.Logger().Debug("new credential not accepted yet, retrying", "identity", identity, "url", conf.Url, "error", err)
+
+		timer := time.NewTimer(nextsleep)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("request ended while verifying credential: %w", err)
+		}
+	}
+}
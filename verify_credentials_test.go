@@ -0,0 +1,217 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package openldap
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault-plugin-secrets-openldap/client"
+	"github.com/hashicorp/vault/sdk/helper/ldaputil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+// verifyBindCall is a bind made to verify a credential.
+type verifyBindCall struct {
+	url       string
+	upnDomain string
+	identity  string
+	password  string
+}
+
+// verifyingClient records binds made to verify credentials, and fails the
+// first failures[url] of them against each URL. URLs in rejected always fail.
+type verifyingClient struct {
+	executeRecordingClient
+	failures map[string]int
+	rejected map[string]bool
+	binds    []verifyBindCall
+}
+
+func (v *verifyingClient) VerifyBind(conf *client.Config, identity, password string) error {
+	v.binds = append(v.binds, verifyBindCall{
+		url:       conf.Url,
+		upnDomain: conf.UPNDomain,
+		identity:  identity,
+		password:  password,
+	})
+	if v.rejected[conf.Url] {
+		return errors.New("invalid credentials")
+	}
+	if v.failures[conf.Url] > 0 {
+		v.failures[conf.Url]--
+		return errors.New("invalid credentials")
+	}
+	return nil
+}
+
+func shortVerifyBackoff(t *testing.T) {
+	oldMin, oldMax := minVerifyBackoff, maxVerifyBackoff
+	t.Cleanup(func() {
+		minVerifyBackoff, maxVerifyBackoff = oldMin, oldMax
+	})
+	minVerifyBackoff, maxVerifyBackoff = time.Millisecond, 5*time.Millisecond
+}
+
+func TestVerifyDynamicCredential(t *testing.T) {
+	shortVerifyBackoff(t)
+
+	report := []client.ExecuteResult{
+		{Index: 0, DN: "cn=admins,ou=groups,dc=example,dc=com", Operation: "modify", Success: true},
+		{Index: 1, DN: "cn=alice,ou=users,dc=example,dc=com", Operation: "add", Success: true},
+	}
+	templateData := dynamicTemplateData{Username: "alice", Password: "s3cr3t"}
+	conf := &client.Config{ConfigEntry: &ldaputil.ConfigEntry{
+		Url:       "ldap://dc1.example.com, ldap://dc2.example.com",
+		UPNDomain: "example.com",
+	}}
+
+	testCases := map[string]struct {
+		role          *dynamicRole
+		conf          *client.Config
+		report        []client.ExecuteResult
+		failures      map[string]int
+		rejected      map[string]bool
+		expectedBinds []verifyBindCall
+		expectedErr   string
+	}{
+		"dn": {
+			role:     &dynamicRole{},
+			failures: map[string]int{"ldap://dc1.example.com, ldap://dc2.example.com": 2},
+			expectedBinds: []verifyBindCall{
+				{url: "ldap://dc1.example.com, ldap://dc2.example.com", identity: "cn=alice,ou=users,dc=example,dc=com", password: "s3cr3t"},
+				{url: "ldap://dc1.example.com, ldap://dc2.example.com", identity: "cn=alice,ou=users,dc=example,dc=com", password: "s3cr3t"},
+				{url: "ldap://dc1.example.com, ldap://dc2.example.com", identity: "cn=alice,ou=users,dc=example,dc=com", password: "s3cr3t"},
+			},
+		},
+		"upn against all urls": {
+			role:     &dynamicRole{VerifyBindAs: verifyBindAsUPN, VerifyAllURLs: true},
+			failures: map[string]int{"ldap://dc2.example.com": 1},
+			expectedBinds: []verifyBindCall{
+				{url: "ldap://dc1.example.com", upnDomain: "example.com", identity: "alice", password: "s3cr3t"},
+				{url: "ldap://dc2.example.com", upnDomain: "example.com", identity: "alice", password: "s3cr3t"},
+				{url: "ldap://dc2.example.com", upnDomain: "example.com", identity: "alice", password: "s3cr3t"},
+			},
+		},
+		"upn without upndomain": {
+			role:        &dynamicRole{VerifyBindAs: verifyBindAsUPN},
+			conf:        &client.Config{ConfigEntry: &ldaputil.ConfigEntry{Url: "ldap://dc1.example.com"}},
+			expectedErr: "no upndomain is configured",
+		},
+		"no entry added": {
+			role:        &dynamicRole{},
+			report:      report[:1],
+			expectedErr: "did not add an entry",
+		},
+		"timeout": {
+			role:        &dynamicRole{VerifyAllURLs: true, VerifyTimeout: 20 * time.Millisecond},
+			rejected:    map[string]bool{"ldap://dc2.example.com": true},
+			expectedErr: `failed to bind as "cn=alice,ou=users,dc=example,dc=com" against ldap://dc2.example.com: credential not accepted before verify_timeout`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			b, _ := getBackend(false)
+			defer b.Cleanup(context.Background())
+			ldapClient := &verifyingClient{failures: tc.failures, rejected: tc.rejected}
+			b.client = ldapClient
+
+			if tc.conf == nil {
+				tc.conf = conf
+			}
+			if tc.report == nil {
+				tc.report = report
+			}
+			err := b.verifyDynamicCredential(context.Background(), tc.conf, tc.role, templateData, tc.report)
+			if tc.expectedErr != "" {
+				require.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedBinds, ldapClient.binds)
+		})
+	}
+
+	t.Run("request ends", func(t *testing.T) {
+		b, _ := getBackend(false)
+		defer b.Cleanup(context.Background())
+		b.client = &verifyingClient{rejected: map[string]bool{"ldap://dc1.example.com": true}}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		role := &dynamicRole{}
+		err := b.verifyDynamicCredential(ctx, &client.Config{ConfigEntry: &ldaputil.ConfigEntry{Url: "ldap://dc1.example.com"}}, role, templateData, report)
+		require.ErrorContains(t, err, "request ended while verifying credential")
+	})
+}
+
+func TestDynamicCredsRead_verifyCredentials(t *testing.T) {
+	shortVerifyBackoff(t)
+	ctx := context.Background()
+
+	setup := func(t *testing.T, roleData map[string]interface{}, ldapClient *verifyingClient) (*backend, logical.Storage) {
+		t.Helper()
+		b, storage := getBackend(false)
+		t.Cleanup(func() { b.Cleanup(ctx) })
+		configureOpenLDAPMount(t, b, storage)
+
+		data := getTestDynamicRoleConfig("hashicorp")
+		data["verify_credentials"] = true
+		for k, v := range roleData {
+			data[k] = v
+		}
+		resp, err := createDynamicRoleWithData(t, b, storage, "hashicorp", data)
+		require.NoError(t, err)
+		require.False(t, resp != nil && resp.IsError(), "unexpected error response: %v", resp)
+		b.client = ldapClient
+		return b, storage
+	}
+
+	t.Run("verified", func(t *testing.T) {
+		ldapClient := &verifyingClient{failures: map[string]int{"ldap://138.91.247.105": 2}}
+		b, storage := setup(t, nil, ldapClient)
+
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      dynamicCredPath + "hashicorp",
+			Storage:   storage,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError(), "unexpected error response: %v", resp)
+		require.Len(t, ldapClient.binds, 3)
+		require.Equal(t, resp.Data["distinguished_names"].([]string)[0], ldapClient.binds[2].identity)
+		require.Equal(t, resp.Data["password"], ldapClient.binds[2].password)
+		require.Len(t, ldapClient.executed, 1)
+	})
+
+	t.Run("rolled back", func(t *testing.T) {
+		ldapClient := &verifyingClient{rejected: map[string]bool{"ldap://138.91.247.105": true}}
+		b, storage := setup(t, map[string]interface{}{"verify_timeout": "1s"}, ldapClient)
+
+		_, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      dynamicCredPath + "hashicorp",
+			Storage:   storage,
+		})
+		require.ErrorContains(t, err, "failed to verify credentials")
+
+		// The rollback LDIF removes the user.
+		require.Len(t, ldapClient.executed, 2)
+		username := strings.TrimPrefix(strings.Split(ldapClient.executed[0][0], ",")[0], "cn=")
+		require.True(t, strings.HasPrefix(ldapClient.executed[1][0], "cn="+username+","))
+		require.NotNil(t, ldapClient.entries[1][0].Del)
+
+		cred, err := retrieveIssuedCredential(ctx, storage, "hashicorp", username)
+		require.NoError(t, err)
+		require.Nil(t, cred)
+		walIDs, err := storage.List(ctx, "wal/")
+		require.NoError(t, err)
+		require.Empty(t, walIDs)
+	})
+}